@@ -0,0 +1,139 @@
+// Command chunktrace runs a WAV file through the audio chunker and writes its
+// frame decisions and chunk boundaries as CSV, JSON and an SVG timeline.
+//
+//	go run ./cmd/chunktrace -set energyThreshold=0.02 -set silenceToFinal=500ms meeting.wav
+//
+// The WAV file must be 16-bit PCM or 32-bit float at the chunker's sample rate
+// (16 kHz); convert other recordings with
+// `ffmpeg -i input -ac 1 -ar 16000 -c:a pcm_s16le output.wav`.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tuanta7/ekko/services/chunker"
+)
+
+// settings collects repeated -set name=value flags.
+type settings []string
+
+func (s *settings) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *settings) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func main() {
+	var overrides settings
+	flag.Var(&overrides, "set", "override a chunker config field, e.g. energyThreshold=0.02 (repeatable)")
+	out := flag.String("out", "", "output path prefix (default: input path without extension)")
+	formats := flag.String("formats", "csv,json,svg", "comma-separated trace formats to write")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: chunktrace [flags] input.wav\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *out, strings.Split(*formats, ","), overrides); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run traces one WAV file and writes the requested formats next to the prefix.
+func run(input, prefix string, formats []string, overrides []string) error {
+	audioChunker := chunker.NewAudioChunker()
+	for _, override := range overrides {
+		name, value, ok := strings.Cut(override, "=")
+		if !ok {
+			return fmt.Errorf("invalid -set %q: expected name=value", override)
+		}
+		if err := audioChunker.Config.Set(name, value); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	audio, err := readWAV(file)
+	_ = file.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+	if audio.SampleRate != audioChunker.Config.SampleRate() {
+		return fmt.Errorf("%s: sample rate is %d Hz, expected %d Hz", input, audio.SampleRate, audioChunker.Config.SampleRate())
+	}
+
+	trace := traceAudio(audioChunker, audio.Samples)
+
+	if prefix == "" {
+		prefix = strings.TrimSuffix(input, filepath.Ext(input))
+	}
+	for _, format := range formats {
+		if err := writeTrace(trace, strings.TrimSpace(format), prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// traceAudio feeds samples to the chunker frame by frame, as the recorder
+// would, and returns the recorded trace. Emitted chunks are only traced.
+func traceAudio(audioChunker *chunker.AudioChunker, samples []float32) *chunker.Trace {
+	audioChunker.Trace = chunker.NewTrace()
+	frameSamples := audioChunker.Config.FrameSamples()
+	for start := 0; start < len(samples); start += frameSamples {
+		for _, chunk := range audioChunker.AddFrame(samples[start:min(start+frameSamples, len(samples))]) {
+			chunk.Release()
+		}
+	}
+	for _, chunk := range audioChunker.Flush() {
+		chunk.Release()
+	}
+	return audioChunker.Trace
+}
+
+// writeTrace writes one trace format to prefix plus the format's extension.
+func writeTrace(trace *chunker.Trace, format, prefix string) error {
+	var write func(*os.File) error
+	switch format {
+	case "csv":
+		write = func(f *os.File) error { return trace.WriteCSV(f) }
+	case "json":
+		write = func(f *os.File) error { return trace.WriteJSON(f) }
+	case "svg":
+		write = func(f *os.File) error { return trace.WriteSVG(f) }
+	default:
+		return fmt.Errorf("unknown trace format %q", format)
+	}
+
+	path := prefix + ".trace." + format
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	log.Printf("wrote %s", path)
+	return nil
+}
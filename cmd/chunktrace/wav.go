@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// wavAudio is a decoded WAV file downmixed to mono.
+type wavAudio struct {
+	SampleRate int
+	Samples    []float32
+}
+
+// readWAV decodes 16-bit PCM or 32-bit float WAV data, averaging channels to mono.
+func readWAV(r io.Reader) (wavAudio, error) {
+	var header struct {
+		RIFF [4]byte
+		Size uint32
+		WAVE [4]byte
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return wavAudio{}, fmt.Errorf("read wav header: %w", err)
+	}
+	if string(header.RIFF[:]) != "RIFF" || string(header.WAVE[:]) != "WAVE" {
+		return wavAudio{}, errors.New("not a RIFF/WAVE file")
+	}
+
+	var format struct {
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+	}
+	haveFormat := false
+
+	for {
+		var chunk struct {
+			ID   [4]byte
+			Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &chunk); err != nil {
+			return wavAudio{}, fmt.Errorf("read wav chunk: %w", err)
+		}
+		// Chunks are padded to an even size.
+		body := io.LimitReader(r, int64(chunk.Size+chunk.Size%2))
+
+		switch string(chunk.ID[:]) {
+		case "fmt ":
+			if err := binary.Read(body, binary.LittleEndian, &format); err != nil {
+				return wavAudio{}, fmt.Errorf("read wav format: %w", err)
+			}
+			if format.AudioFormat == wavFormatExtensible {
+				// The extension ends with a GUID whose first two bytes are the real format.
+				extension := make([]byte, 10)
+				if _, err := io.ReadFull(body, extension); err != nil {
+					return wavAudio{}, fmt.Errorf("read wav format extension: %w", err)
+				}
+				format.AudioFormat = binary.LittleEndian.Uint16(extension[8:])
+			}
+			haveFormat = true
+
+		case "data":
+			if !haveFormat {
+				return wavAudio{}, errors.New("wav data chunk precedes its format chunk")
+			}
+			samples, err := decodeWAVData(body, format.AudioFormat, int(format.BitsPerSample), int(format.Channels))
+			if err != nil {
+				return wavAudio{}, err
+			}
+			return wavAudio{SampleRate: int(format.SampleRate), Samples: samples}, nil
+		}
+
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return wavAudio{}, fmt.Errorf("skip wav chunk: %w", err)
+		}
+	}
+}
+
+// decodeWAVData converts interleaved samples to normalized mono float32.
+func decodeWAVData(r io.Reader, audioFormat uint16, bits, channels int) ([]float32, error) {
+	if channels < 1 {
+		return nil, errors.New("wav file has no channels")
+	}
+
+	var decode func([]byte) float32
+	switch {
+	case audioFormat == wavFormatPCM && bits == 16:
+		decode = func(b []byte) float32 {
+			return float32(int16(binary.LittleEndian.Uint16(b))) / 32768
+		}
+	case audioFormat == wavFormatFloat && bits == 32:
+		decode = func(b []byte) float32 {
+			return math.Float32frombits(binary.LittleEndian.Uint32(b))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported wav encoding: format %d with %d bits; convert to 16-bit PCM or 32-bit float", audioFormat, bits)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read wav data: %w", err)
+	}
+
+	sampleBytes := bits / 8
+	frameBytes := sampleBytes * channels
+	samples := make([]float32, len(data)/frameBytes)
+	for i := range samples {
+		var sum float32
+		for channel := range channels {
+			offset := i*frameBytes + channel*sampleBytes
+			sum += decode(data[offset : offset+sampleBytes])
+		}
+		samples[i] = sum / float32(channels)
+	}
+	return samples, nil
+}
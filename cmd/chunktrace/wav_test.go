@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadWAVDownmixesPCM16(t *testing.T) {
+	// Two stereo frames: (0.5, -0.5) and (0.25, 0.25).
+	data := []int16{16384, -16384, 8192, 8192}
+	audio, err := readWAV(bytes.NewReader(testWAV(t, wavFormatPCM, 2, 16000, 16, data)))
+	if err != nil {
+		t.Fatalf("readWAV() error = %v", err)
+	}
+
+	if audio.SampleRate != 16000 {
+		t.Fatalf("expected 16000 Hz, got %d", audio.SampleRate)
+	}
+	if len(audio.Samples) != 2 || audio.Samples[0] != 0 || audio.Samples[1] != 0.25 {
+		t.Fatalf("expected downmixed samples [0 0.25], got %v", audio.Samples)
+	}
+}
+
+func TestReadWAVRejectsUnsupportedEncodings(t *testing.T) {
+	_, err := readWAV(bytes.NewReader(testWAV(t, wavFormatPCM, 1, 16000, 8, []uint8{128, 129})))
+	if err == nil {
+		t.Fatal("expected 8-bit PCM to be rejected")
+	}
+}
+
+func TestRunWritesTraceFiles(t *testing.T) {
+	samples := make([]float32, 16000*2)
+	for i := 4000; i < 20000; i++ {
+		samples[i] = float32(0.2 * math.Sin(float64(i)/5))
+	}
+	bits := make([]uint32, len(samples))
+	for i, sample := range samples {
+		bits[i] = math.Float32bits(sample)
+	}
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "speech.wav")
+	if err := os.WriteFile(input, testWAV(t, wavFormatFloat, 1, 16000, 32, bits), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(input, "", []string{"csv", "json", "svg"}, []string{"classifySound=false"}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	for _, name := range []string{"speech.trace.csv", "speech.trace.json", "speech.trace.svg"} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Size() == 0 {
+			t.Fatalf("expected %s to be written, got %v", name, err)
+		}
+	}
+
+	if err := run(input, "", []string{"csv"}, []string{"threshold=1"}); err == nil {
+		t.Fatal("expected an unknown config field to fail")
+	}
+}
+
+// testWAV encodes little-endian samples with a minimal RIFF/WAVE header.
+func testWAV(t *testing.T, format uint16, channels uint16, sampleRate uint32, bits uint16, samples any) []byte {
+	t.Helper()
+
+	var data bytes.Buffer
+	if err := binary.Write(&data, binary.LittleEndian, samples); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	blockAlign := channels * bits / 8
+	for _, value := range []any{
+		[]byte("RIFF"), uint32(36 + data.Len()), []byte("WAVE"),
+		[]byte("fmt "), uint32(16), format, channels, sampleRate, sampleRate * uint32(blockAlign), blockAlign, bits,
+		[]byte("data"), uint32(data.Len()),
+	} {
+		if err := binary.Write(&out, binary.LittleEndian, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out.Write(data.Bytes())
+	return out.Bytes()
+}
@@ -1,11 +1,23 @@
 import { useEffect, useRef, useState } from "react";
-import { Events } from "@wailsio/runtime";
+import { Clipboard, Events } from "@wailsio/runtime";
+import { X } from "lucide-react";
 import { TranscribeService } from "../bindings/github.com/tuanta7/ekko/services";
-import type { ErrorEvent, StateEvent, TranscriptEvent } from "@/bindings/github.com/tuanta7/ekko/services";
+import type {
+  ErrorEvent,
+  Glossary,
+  RedactionReport,
+  RefineProgressEvent,
+  StateEvent,
+  TranscriptEvent,
+} from "@/bindings/github.com/tuanta7/ekko/services";
 import AppHeader from "./components/AppHeader";
+import GlossaryPanel from "./components/GlossaryPanel";
+import ModelPanel from "./components/ModelPanel";
+import TextPanel from "./components/TextPanel";
 import TranscriptMain from "./components/TranscriptMain";
 import { useRecorder } from "./hooks/useRecorder";
 import { isActivePhase } from "./lib/state";
+import { lineKey, withRefinedLine } from "./lib/transcript";
 import type { TranscriptLine } from "./types/transcription";
 
 function App() {
@@ -13,10 +25,32 @@ function App() {
   const [sources, setSources] = useState<string[]>([]);
   const [partial, setPartial] = useState<TranscriptLine | null>(null);
   const [finalLines, setFinalLines] = useState<TranscriptLine[]>([]);
+  const [includeAnnotations, setIncludeAnnotations] = useState(true);
+  const [language, setLanguage] = useState("auto");
+  const [translate, setTranslate] = useState(false);
+  const [diarize, setDiarize] = useState(false);
+  const [speakerNames, setSpeakerNames] = useState<Record<number, string>>({});
+  const [glossaries, setGlossaries] = useState<Glossary[]>([]);
+  const [glossary, setGlossary] = useState("");
+  const [showGlossary, setShowGlossary] = useState(false);
+  const [showModels, setShowModels] = useState(false);
+  const [showText, setShowText] = useState(false);
+  const [partialModel, setPartialModel] = useState("");
+  const [finalModel, setFinalModel] = useState("");
+  const [refineModel, setRefineModel] = useState("");
+  const [refining, setRefining] = useState<RefineProgressEvent | null>(null);
+  const [redacted, setRedacted] = useState<RedactionReport | null>(null);
+  const [adaptation, setAdaptation] = useState("");
+  const [backends, setBackends] = useState<string[]>([]);
+  const [backend, setBackend] = useState("");
 
   const [recorder, dispatch] = useRecorder();
 
   const transcriptRef = useRef<HTMLDivElement>(null);
+  // Highest utterance whose final has been shown; partials at or below it are stale.
+  const finalizedUtteranceRef = useRef(0);
+  // Session whose transcript is exported; kept after the recorder forgets it on stop.
+  const exportSessionRef = useRef("");
 
   const isStopping = recorder.phase === "stopping";
   const isActive = isActivePhase(recorder.phase);
@@ -37,28 +71,68 @@ function App() {
       });
   };
 
+  const refreshGlossaries = () => {
+    TranscribeService.Glossaries()
+      .then((values: Glossary[]) => setGlossaries(values ?? []))
+      .catch((err: unknown) => reportError(String(err)));
+  };
+
+  const reportError = (message: string) => {
+    dispatch({ type: "error-received", event: { sessionID: "", message } });
+  };
+
   useEffect(() => {
     refreshSources();
+    refreshGlossaries();
+    TranscribeService.Backends()
+      .then((values: string[]) => setBackends(values ?? []))
+      .catch((err: unknown) => reportError(String(err)));
   }, []);
 
   useEffect(() => {
     const offState = Events.On("transcribe:state", (event: any) => {
       const data = event.data as StateEvent;
       dispatch({ type: "state-received", event: data });
+      if (data.adapted) {
+        setAdaptation(data.message);
+      }
       if (data.state === "stopped") {
         setPartial(null);
+        setAdaptation("");
+        TranscribeService.RedactionReport(data.sessionID)
+          .then((report: RedactionReport) => setRedacted(report.total > 0 ? report : null))
+          .catch(() => setRedacted(null));
       }
     });
 
     const offPartial = Events.On("transcribe:partial", (event: any) => {
       const data = event.data as TranscriptEvent;
-      setPartial(toLine(data));
+      if (data.utteranceID <= finalizedUtteranceRef.current) {
+        return;
+      }
+      setPartial((current) =>
+        current && current.utteranceID === data.utteranceID && current.revision > data.revision
+          ? current
+          : toLine(data),
+      );
     });
 
     const offFinal = Events.On("transcribe:final", (event: any) => {
       const data = event.data as TranscriptEvent;
-      setFinalLines((current) => [...current, toLine(data)]);
-      setPartial(null);
+      finalizedUtteranceRef.current = Math.max(finalizedUtteranceRef.current, data.utteranceID);
+      const line = toLine(data);
+      setFinalLines((current) => [...current.filter((existing) => lineKey(existing) !== lineKey(line)), line]);
+      setPartial((current) => (current && current.utteranceID > data.utteranceID ? current : null));
+    });
+
+    // A refined final replaces the live text of its utterance in place.
+    const offRefined = Events.On("transcribe:refined", (event: any) => {
+      setFinalLines((current) => withRefinedLine(current, toLine(event.data as TranscriptEvent)));
+    });
+
+    const offRefineProgress = Events.On("transcribe:refine-progress", (event: any) => {
+      const data = event.data as RefineProgressEvent;
+      setRefining(data.done ? null : data);
     });
 
     const offError = Events.On("transcribe:error", (event: any) => {
@@ -69,6 +143,8 @@ function App() {
       offState();
       offPartial();
       offFinal();
+      offRefined();
+      offRefineProgress();
       offError();
     };
   }, []);
@@ -84,12 +160,26 @@ function App() {
   }, [finalLines, partial, recorder.error]);
 
   const start = () => {
+    finalizedUtteranceRef.current = 0;
     setPartial(null);
     setFinalLines([]);
+    setSpeakerNames({});
+    setRedacted(null);
     dispatch({ type: "start-requested" });
 
-    TranscribeService.Start(source)
+    TranscribeService.Start(source, {
+      backend,
+      language,
+      translate,
+      glossary,
+      terms: [],
+      partialModel,
+      finalModel,
+      refineModel,
+      diarize,
+    })
       .then((sessionID: string) => {
+        exportSessionRef.current = sessionID;
         dispatch({ type: "start-resolved", sessionID });
       })
       .catch((err: unknown) => {
@@ -108,6 +198,26 @@ function App() {
     });
   };
 
+  const exportTranscript = () => {
+    if (!exportSessionRef.current) {
+      return;
+    }
+
+    TranscribeService.Export(exportSessionRef.current, { includeAnnotations })
+      .then((text: string) => Clipboard.SetText(text))
+      .catch((err: unknown) => reportError(String(err)));
+  };
+
+  const renameSpeaker = (speaker: number, name: string) => {
+    if (!exportSessionRef.current) {
+      return;
+    }
+
+    TranscribeService.RenameSpeaker(exportSessionRef.current, speaker, name)
+      .then(() => setSpeakerNames((current) => ({ ...current, [speaker]: name.trim() })))
+      .catch((err: unknown) => reportError(String(err)));
+  };
+
   const clearTranscript = () => {
     setFinalLines([]);
     setPartial(null);
@@ -126,15 +236,86 @@ function App() {
           source={source}
           sources={sources}
           hasTranscript={finalLines.length > 0 || Boolean(partial)}
+          includeAnnotations={includeAnnotations}
+          language={language}
+          translate={translate}
+          diarize={diarize}
+          backend={backend}
+          backends={backends}
+          showGlossary={showGlossary}
+          hasGlossary={Boolean(glossary)}
+          showModels={showModels}
+          showText={showText}
           onSourceChange={setSource}
+          onBackendChange={setBackend}
+          onLanguageChange={setLanguage}
+          onToggleTranslate={() => setTranslate((current) => !current)}
+          onToggleDiarize={() => setDiarize((current) => !current)}
+          onToggleGlossary={() => setShowGlossary((current) => !current)}
+          onToggleModels={() => setShowModels((current) => !current)}
+          onToggleText={() => setShowText((current) => !current)}
           onClear={clearTranscript}
+          onExport={exportTranscript}
+          onToggleAnnotations={() => setIncludeAnnotations((current) => !current)}
           onRefresh={refreshSources}
           onStart={start}
           onStop={stop}
         />
+        {showGlossary && (
+          <GlossaryPanel
+            glossaries={glossaries}
+            selected={glossary}
+            disabled={isActive}
+            onSelect={setGlossary}
+            onChanged={refreshGlossaries}
+            onError={reportError}
+          />
+        )}
+        {showModels && (
+          <ModelPanel
+            disabled={isActive}
+            partialModel={partialModel}
+            finalModel={finalModel}
+            refineModel={refineModel}
+            onPartialModelChange={setPartialModel}
+            onFinalModelChange={setFinalModel}
+            onRefineModelChange={setRefineModel}
+            onError={reportError}
+          />
+        )}
+        {showText && <TextPanel onError={reportError} />}
+        {refining && (
+          <div className="relative z-10 flex shrink-0 items-center gap-2 px-2.5 pb-1 text-xs text-white/50">
+            <span className="truncate">Refining {refining.refined} of {refining.total}</span>
+            <button
+              type="button"
+              onClick={() => TranscribeService.CancelRefinement(refining.sessionID).catch(() => setRefining(null))}
+              className="cursor-pointer mono-button grid h-5 w-5 place-items-center rounded-md"
+              title="Cancel refinement"
+              aria-label="Cancel refinement"
+            >
+              <X size={12} />
+            </button>
+          </div>
+        )}
+        {adaptation && (
+          <div className="relative z-10 shrink-0 truncate px-2.5 pb-1 text-xs text-white/50" title={adaptation}>
+            {adaptation}
+          </div>
+        )}
+        {redacted && (
+          <div className="relative z-10 shrink-0 truncate px-2.5 pb-1 text-xs text-white/50">
+            Redacted{" "}
+            {Object.entries(redacted.counts)
+              .map(([name, count]) => `${count} ${name}`)
+              .join(", ")}
+          </div>
+        )}
         <TranscriptMain
           finalLines={finalLines}
           liveLine={partial}
+          speakerNames={speakerNames}
+          onRenameSpeaker={renameSpeaker}
           displayText={liveText}
           active={isActive}
           error={recorder.error}
@@ -148,7 +329,15 @@ function App() {
 function toLine(event: TranscriptEvent): TranscriptLine {
   return {
     id: event.chunkID,
+    utteranceID: event.utteranceID,
+    part: event.part ?? 0,
+    speaker: event.speaker ?? 0,
+    revision: event.revision,
     text: event.text,
+    rawText: event.rawText ?? "",
+    annotation: event.annotation ?? "",
+    language: event.language ?? "",
+    words: event.words ?? [],
     startMs: event.startMs,
     endMs: event.endMs,
   };
@@ -1,7 +1,25 @@
 import type { CSSProperties } from "react";
-import { AlertCircle, Circle, GripVertical, LoaderCircle, Mic, Play, RefreshCw, Square, Trash2 } from "lucide-react";
+import {
+  AlertCircle,
+  BookA,
+  Box,
+  Circle,
+  ClipboardCopy,
+  GripVertical,
+  Languages,
+  LoaderCircle,
+  Mic,
+  Music,
+  Play,
+  RefreshCw,
+  Square,
+  Trash2,
+  Users,
+  WandSparkles,
+} from "lucide-react";
 
 import type { RecorderPhase, RecorderState } from "../types/transcription";
+import { languageOptions } from "../lib/languages";
 import {labelState} from "../lib/state.ts";
 
 type AppHeaderProps = {
@@ -9,8 +27,27 @@ type AppHeaderProps = {
   source: string;
   sources: string[];
   hasTranscript: boolean;
+  includeAnnotations: boolean;
+  language: string;
+  translate: boolean;
+  diarize: boolean;
+  backend: string;
+  backends: string[];
+  showGlossary: boolean;
+  hasGlossary: boolean;
+  showModels: boolean;
+  showText: boolean;
   onSourceChange: (source: string) => void;
+  onBackendChange: (backend: string) => void;
+  onLanguageChange: (language: string) => void;
+  onToggleTranslate: () => void;
+  onToggleDiarize: () => void;
+  onToggleGlossary: () => void;
+  onToggleModels: () => void;
+  onToggleText: () => void;
   onClear: () => void;
+  onExport: () => void;
+  onToggleAnnotations: () => void;
   onRefresh: () => void;
   onStart: () => void;
   onStop: () => void;
@@ -21,8 +58,27 @@ function AppHeader({
   source,
   sources,
   hasTranscript,
+  includeAnnotations,
+  language,
+  translate,
+  diarize,
+  backend,
+  backends,
+  showGlossary,
+  hasGlossary,
+  showModels,
+  showText,
   onSourceChange,
+  onBackendChange,
+  onLanguageChange,
+  onToggleTranslate,
+  onToggleDiarize,
+  onToggleGlossary,
+  onToggleModels,
+  onToggleText,
   onClear,
+  onExport,
+  onToggleAnnotations,
   onRefresh,
   onStart,
   onStop,
@@ -53,6 +109,32 @@ function AppHeader({
       </div>
 
       <div className="flex shrink-0 items-center gap-2">
+        <button
+          type="button"
+          onClick={onToggleAnnotations}
+          className={`cursor-pointer mono-button grid h-7 w-7 place-items-center rounded-md ${
+            includeAnnotations ? "text-blue-300" : "text-white/40"
+          }`}
+          title={
+            includeAnnotations ? "Exports include music and silence markers" : "Exports omit music and silence markers"
+          }
+          aria-label="Include annotations in exports"
+          aria-pressed={includeAnnotations}
+        >
+          <Music size={14} />
+        </button>
+
+        <button
+          type="button"
+          onClick={onExport}
+          disabled={!hasTranscript}
+          className="cursor-pointer mono-button grid h-7 w-7 place-items-center rounded-md disabled:cursor-not-allowed disabled:opacity-40"
+          title="Copy transcript"
+          aria-label="Copy transcript"
+        >
+          <ClipboardCopy size={14} />
+        </button>
+
         <button
           type="button"
           onClick={onClear}
@@ -75,6 +157,108 @@ function AppHeader({
           <RefreshCw size={14} />
         </button>
 
+        {backends.length > 1 && (
+          <select
+            value={backend}
+            onChange={(event) => onBackendChange(event.target.value)}
+            disabled={isActive}
+            className="cursor-pointer mono-select h-7 w-20 appearance-none rounded-md px-2 outline-none disabled:cursor-not-allowed disabled:opacity-50"
+            title="Transcription backend"
+            aria-label="Transcription backend"
+          >
+            <option value="">whisper</option>
+            {backends
+              .filter((name) => name !== "whisper")
+              .map((name) => (
+                <option key={name} value={name}>
+                  {name}
+                </option>
+              ))}
+          </select>
+        )}
+
+        <select
+          value={language}
+          onChange={(event) => onLanguageChange(event.target.value)}
+          disabled={isActive}
+          className="cursor-pointer mono-select h-7 w-14 appearance-none rounded-md px-2 outline-none disabled:cursor-not-allowed disabled:opacity-50"
+          title="Spoken language"
+          aria-label="Spoken language"
+        >
+          {languageOptions.map((option) => (
+            <option key={option.code} value={option.code}>
+              {option.code === "auto" ? option.label : option.code.toUpperCase()}
+            </option>
+          ))}
+        </select>
+
+        <button
+          type="button"
+          onClick={onToggleTranslate}
+          disabled={isActive}
+          className={`cursor-pointer mono-button grid h-7 w-7 place-items-center rounded-md disabled:cursor-not-allowed disabled:opacity-40 ${
+            translate ? "text-blue-300" : "text-white/40"
+          }`}
+          title={translate ? "Translating to English" : "Transcribing in the spoken language"}
+          aria-label="Translate to English"
+          aria-pressed={translate}
+        >
+          <Languages size={14} />
+        </button>
+
+        <button
+          type="button"
+          onClick={onToggleDiarize}
+          disabled={isActive}
+          className={`cursor-pointer mono-button grid h-7 w-7 place-items-center rounded-md disabled:cursor-not-allowed disabled:opacity-40 ${
+            diarize ? "text-blue-300" : "text-white/40"
+          }`}
+          title={diarize ? "Labelling speakers by voice" : "Not labelling speakers"}
+          aria-label="Label speakers"
+          aria-pressed={diarize}
+        >
+          <Users size={14} />
+        </button>
+
+        <button
+          type="button"
+          onClick={onToggleGlossary}
+          className={`cursor-pointer mono-button grid h-7 w-7 place-items-center rounded-md ${
+            hasGlossary ? "text-blue-300" : "text-white/40"
+          }`}
+          title={hasGlossary ? "A glossary guides spelling" : "No glossary"}
+          aria-label="Edit glossaries"
+          aria-pressed={showGlossary}
+        >
+          <BookA size={14} />
+        </button>
+
+        <button
+          type="button"
+          onClick={onToggleModels}
+          className={`cursor-pointer mono-button grid h-7 w-7 place-items-center rounded-md ${
+            showModels ? "text-blue-300" : "text-white/40"
+          }`}
+          title="Models"
+          aria-label="Manage models"
+          aria-pressed={showModels}
+        >
+          <Box size={14} />
+        </button>
+
+        <button
+          type="button"
+          onClick={onToggleText}
+          className={`cursor-pointer mono-button grid h-7 w-7 place-items-center rounded-md ${
+            showText ? "text-blue-300" : "text-white/40"
+          }`}
+          title="Text processing"
+          aria-label="Edit text processing"
+          aria-pressed={showText}
+        >
+          <WandSparkles size={14} />
+        </button>
+
         <div className="relative flex items-center">
           <Mic size={13} className="pointer-events-none absolute left-2 z-10 text-white/50" />
           <select
@@ -82,7 +266,7 @@ function AppHeader({
             value={source}
             onChange={(event) => onSourceChange(event.target.value)}
             disabled={isActive}
-            className="cursor-pointer mono-select h-7 w-32 appearance-none rounded-md pl-7 pr-2 outline-none disabled:cursor-not-allowed disabled:opacity-50"
+            className="cursor-pointer mono-select h-7 w-28 appearance-none rounded-md pl-7 pr-2 outline-none disabled:cursor-not-allowed disabled:opacity-50"
           >
             {sources.length === 0 && <option value="">No source found</option>}
             {sources.map((value) => (
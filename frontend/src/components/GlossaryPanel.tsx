@@ -0,0 +1,109 @@
+import { useEffect, useState } from "react";
+import { Save, Trash2 } from "lucide-react";
+import { TranscribeService } from "../../bindings/github.com/tuanta7/ekko/services";
+import type { Glossary } from "@/bindings/github.com/tuanta7/ekko/services";
+
+type GlossaryPanelProps = {
+  glossaries: Glossary[];
+  selected: string;
+  disabled: boolean;
+  onSelect: (name: string) => void;
+  onChanged: () => void;
+  onError: (message: string) => void;
+};
+
+// GlossaryPanel picks the glossary for the next session and edits saved glossaries.
+function GlossaryPanel({ glossaries, selected, disabled, onSelect, onChanged, onError }: GlossaryPanelProps) {
+  const current = glossaries.find((glossary) => glossary.name === selected);
+  const [name, setName] = useState(selected);
+  const [terms, setTerms] = useState("");
+
+  useEffect(() => {
+    setName(current?.name ?? "");
+    setTerms(current?.terms.join("\n") ?? "");
+  }, [current]);
+
+  const save = () => {
+    TranscribeService.SaveGlossary({ name, terms: splitTerms(terms) })
+      .then(() => {
+        onSelect(name.trim());
+        onChanged();
+      })
+      .catch((err: unknown) => onError(String(err)));
+  };
+
+  const remove = () => {
+    TranscribeService.DeleteGlossary(selected)
+      .then(() => {
+        onSelect("");
+        onChanged();
+      })
+      .catch((err: unknown) => onError(String(err)));
+  };
+
+  return (
+    <div className="relative z-10 flex shrink-0 flex-col gap-1.5 px-2.5 pb-2 text-xs">
+      <div className="flex items-center gap-2">
+        <select
+          value={selected}
+          onChange={(event) => onSelect(event.target.value)}
+          disabled={disabled}
+          className="cursor-pointer mono-select h-7 w-28 appearance-none rounded-md px-2 outline-none disabled:cursor-not-allowed disabled:opacity-50"
+          title="Glossary for the next session"
+          aria-label="Glossary"
+        >
+          <option value="">No glossary</option>
+          {glossaries.map((glossary) => (
+            <option key={glossary.name} value={glossary.name}>
+              {glossary.name}
+            </option>
+          ))}
+        </select>
+        <input
+          value={name}
+          onChange={(event) => setName(event.target.value)}
+          placeholder="Glossary name"
+          className="mono-select h-7 min-w-0 flex-1 rounded-md px-2 outline-none"
+          aria-label="Glossary name"
+        />
+        <button
+          type="button"
+          onClick={save}
+          disabled={!name.trim()}
+          className="cursor-pointer mono-button grid h-7 w-7 place-items-center rounded-md disabled:cursor-not-allowed disabled:opacity-40"
+          title="Save glossary"
+          aria-label="Save glossary"
+        >
+          <Save size={14} />
+        </button>
+        <button
+          type="button"
+          onClick={remove}
+          disabled={!current}
+          className="cursor-pointer mono-button grid h-7 w-7 place-items-center rounded-md disabled:cursor-not-allowed disabled:opacity-40"
+          title="Delete glossary"
+          aria-label="Delete glossary"
+        >
+          <Trash2 size={14} />
+        </button>
+      </div>
+      <textarea
+        value={terms}
+        onChange={(event) => setTerms(event.target.value)}
+        placeholder="Names, products and acronyms, one per line"
+        rows={3}
+        className="mono-select resize-none rounded-md px-2 py-1 outline-none"
+        aria-label="Glossary terms"
+      />
+    </div>
+  );
+}
+
+function splitTerms(text: string): string[] {
+  return text
+    .split(/[\n,]/)
+    .map((term) => term.trim())
+    .filter(Boolean);
+}
+
+export default GlossaryPanel;
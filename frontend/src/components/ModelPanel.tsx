@@ -0,0 +1,271 @@
+import { useEffect, useState } from "react";
+import { Events } from "@wailsio/runtime";
+import { Check, Download, ShieldCheck, X } from "lucide-react";
+import { ModelService } from "../../bindings/github.com/tuanta7/ekko/services";
+import type { Model, ModelProgressEvent } from "@/bindings/github.com/tuanta7/ekko/services";
+
+type ModelPanelProps = {
+  disabled: boolean;
+  partialModel: string;
+  finalModel: string;
+  refineModel: string;
+  onPartialModelChange: (model: string) => void;
+  onFinalModelChange: (model: string) => void;
+  onRefineModelChange: (model: string) => void;
+  onError: (message: string) => void;
+};
+
+// ModelPanel lists the installed whisper models, switches between them and installs new ones. Partials and
+// finals can also be transcribed with separate models, such as a small one for instant partials, and refined with a
+// larger one once the session ends.
+function ModelPanel({
+  disabled,
+  partialModel,
+  finalModel,
+  refineModel,
+  onPartialModelChange,
+  onFinalModelChange,
+  onRefineModelChange,
+  onError,
+}: ModelPanelProps) {
+  const [models, setModels] = useState<Model[]>([]);
+  const [selected, setSelected] = useState("");
+  const [source, setSource] = useState("");
+  const [checksum, setChecksum] = useState("");
+  const [progress, setProgress] = useState<ModelProgressEvent | null>(null);
+  const [status, setStatus] = useState("");
+
+  const refresh = () => {
+    ModelService.Models()
+      .then((values: Model[]) => {
+        setModels(values ?? []);
+        setSelected((current) => current || values?.find((model) => model.active)?.name || "");
+      })
+      .catch((err: unknown) => onError(String(err)));
+  };
+
+  useEffect(() => {
+    refresh();
+    return Events.On("model:progress", (event: any) => {
+      const data = event.data as ModelProgressEvent;
+      setProgress(data.done ? null : data);
+    });
+  }, []);
+
+  const current = models.find((model) => model.name === selected);
+
+  const use = () => {
+    ModelService.UseModel(selected)
+      .then(() => {
+        setStatus(`Next session uses ${selected}`);
+        refresh();
+      })
+      .catch((err: unknown) => onError(String(err)));
+  };
+
+  const verify = () => {
+    setStatus("Verifying…");
+    ModelService.VerifyModel(selected)
+      .then((check) => {
+        if (!check.expected) {
+          setStatus(`No known checksum; SHA-256 ${check.sha256.slice(0, 12)}…`);
+        } else if (check.verified && check.source === "import") {
+          setStatus("Unchanged since import, but never verified");
+        } else if (check.verified) {
+          setStatus(check.source === "mirror" ? "Checksum matches the mirror" : "Checksum matches the install");
+        } else {
+          setStatus("Checksum mismatch, reinstall the model");
+        }
+      })
+      .catch((err: unknown) => onError(String(err)));
+  };
+
+  // A path imports a local file; anything else names a model on the mirror. Without a checksum, the mirror's is
+  // expected, and a file the mirror has none for is imported unverified.
+  const install = () => {
+    const value = source.trim();
+    const sum = checksum.trim();
+    const request = value.includes("/") ? ModelService.ImportModel(value, sum) : ModelService.DownloadModel(value, sum);
+    setStatus("");
+    request
+      .then((model) => {
+        setSource("");
+        setChecksum("");
+        setSelected(model.name);
+        setStatus(model.unverified ? `Installed ${model.name}, checksum unverified` : `Installed ${model.name}`);
+        refresh();
+      })
+      .catch((err: unknown) => onError(String(err)))
+      .finally(() => setProgress(null));
+  };
+
+  return (
+    <div className="relative z-10 flex shrink-0 flex-col gap-1.5 px-2.5 pb-2 text-xs">
+      <div className="flex items-center gap-2">
+        <select
+          value={selected}
+          onChange={(event) => setSelected(event.target.value)}
+          className="cursor-pointer mono-select h-7 min-w-0 flex-1 appearance-none rounded-md px-2 outline-none"
+          title="Installed models"
+          aria-label="Model"
+        >
+          {models.length === 0 && <option value="">No models installed</option>}
+          {models.map((model) => (
+            <option key={model.name} value={model.name}>
+              {model.active ? "● " : ""}
+              {model.name}
+            </option>
+          ))}
+        </select>
+        <button
+          type="button"
+          onClick={use}
+          disabled={disabled || !current || current.active}
+          className="cursor-pointer mono-button grid h-7 w-7 place-items-center rounded-md disabled:cursor-not-allowed disabled:opacity-40"
+          title="Use for the next session"
+          aria-label="Use model"
+        >
+          <Check size={14} />
+        </button>
+        <button
+          type="button"
+          onClick={verify}
+          disabled={!current}
+          className="cursor-pointer mono-button grid h-7 w-7 place-items-center rounded-md disabled:cursor-not-allowed disabled:opacity-40"
+          title="Verify checksum"
+          aria-label="Verify model"
+        >
+          <ShieldCheck size={14} />
+        </button>
+      </div>
+      {current && (
+        <p className="truncate text-white/50">
+          {[
+            current.size,
+            current.multilingual ? "multilingual" : "English only",
+            current.quantization,
+            formatBytes(current.bytes),
+            current.unverified ? "unverified" : "",
+          ]
+            .filter(Boolean)
+            .join(" · ")}
+        </p>
+      )}
+      <div className="flex items-center gap-2">
+        <TierSelect
+          label="Partials"
+          value={partialModel}
+          models={models}
+          disabled={disabled}
+          onChange={onPartialModelChange}
+        />
+        <TierSelect
+          label="Finals"
+          value={finalModel}
+          models={models}
+          disabled={disabled}
+          onChange={onFinalModelChange}
+        />
+        <TierSelect
+          label="Refine"
+          emptyLabel="off"
+          value={refineModel}
+          models={models}
+          disabled={disabled}
+          onChange={onRefineModelChange}
+        />
+      </div>
+      <div className="flex items-center gap-2">
+        <input
+          value={source}
+          onChange={(event) => setSource(event.target.value)}
+          placeholder="Model name, such as base.en-q5_1, or a local file"
+          className="mono-select h-7 min-w-0 flex-1 rounded-md px-2 outline-none"
+          aria-label="Model to install"
+        />
+        <input
+          value={checksum}
+          onChange={(event) => setChecksum(event.target.value)}
+          placeholder="SHA-256"
+          className="mono-select h-7 w-20 min-w-0 rounded-md px-2 outline-none"
+          title="Expected SHA-256, needed when the mirror publishes none"
+          aria-label="Expected SHA-256"
+        />
+        <button
+          type="button"
+          onClick={install}
+          disabled={!source.trim() || Boolean(progress)}
+          className="cursor-pointer mono-button grid h-7 w-7 place-items-center rounded-md disabled:cursor-not-allowed disabled:opacity-40"
+          title="Install model"
+          aria-label="Install model"
+        >
+          <Download size={14} />
+        </button>
+      </div>
+      {(progress || status) && (
+        <div className="flex items-center gap-2">
+          <p className="min-w-0 flex-1 truncate text-white/50">
+            {progress ? `Installing ${progress.name}: ${formatBytes(progress.bytes)}${total(progress)}` : status}
+          </p>
+          {progress && (
+            <button
+              type="button"
+              onClick={() => ModelService.CancelInstall(progress.name).catch((err: unknown) => onError(String(err)))}
+              className="cursor-pointer mono-button grid h-5 w-5 place-items-center rounded-md"
+              title="Cancel install"
+              aria-label="Cancel install"
+            >
+              <X size={12} />
+            </button>
+          )}
+        </div>
+      )}
+    </div>
+  );
+}
+
+type TierSelectProps = {
+  label: string;
+  emptyLabel?: string;
+  value: string;
+  models: Model[];
+  disabled: boolean;
+  onChange: (model: string) => void;
+};
+
+// TierSelect picks the model for partials, finals or the refinement; empty uses the active model unless emptyLabel
+// says otherwise.
+function TierSelect({ label, emptyLabel = "active model", value, models, disabled, onChange }: TierSelectProps) {
+  return (
+    <select
+      value={value}
+      onChange={(event) => onChange(event.target.value)}
+      disabled={disabled}
+      className="cursor-pointer mono-select h-7 min-w-0 flex-1 appearance-none rounded-md px-2 outline-none disabled:cursor-not-allowed disabled:opacity-50"
+      title={`Model for ${label.toLowerCase()}`}
+      aria-label={`${label} model`}
+    >
+      <option value="">
+        {label}: {emptyLabel}
+      </option>
+      {models.map((model) => (
+        <option key={model.name} value={model.name}>
+          {label}: {model.name}
+        </option>
+      ))}
+    </select>
+  );
+}
+
+function total(progress: ModelProgressEvent): string {
+  return progress.total ? ` of ${formatBytes(progress.total)}` : "";
+}
+
+function formatBytes(bytes: number): string {
+  if (bytes >= 1 << 30) {
+    return `${(bytes / (1 << 30)).toFixed(1)} GB`;
+  }
+  return `${Math.round(bytes / (1 << 20))} MB`;
+}
+
+export default ModelPanel;
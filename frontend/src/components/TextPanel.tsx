@@ -0,0 +1,150 @@
+import { useEffect, useState } from "react";
+import { Save, ShieldCheck } from "lucide-react";
+import { TranscribeService } from "../../bindings/github.com/tuanta7/ekko/services";
+import type { Redaction, TextProcessing } from "@/bindings/github.com/tuanta7/ekko/services";
+
+type TextPanelProps = {
+  onError: (message: string) => void;
+};
+
+type Toggle = "numbers" | "profanity" | "fillers" | "casing";
+
+const toggles: { key: Toggle; label: string; title: string }[] = [
+  { key: "numbers", label: "123", title: "Write numbers and dates with digits" },
+  { key: "profanity", label: "Mask", title: "Mask profanity" },
+  { key: "fillers", label: "Um", title: "Drop fillers such as um and uh" },
+  { key: "casing", label: "Aa", title: "Capitalize sentences and I" },
+];
+
+// TextPanel edits how transcript text is rewritten: a find-and-replace dictionary, optional clean-up steps and the
+// redaction of personal data. The next session uses the saved settings.
+function TextPanel({ onError }: TextPanelProps) {
+  const [settings, setSettings] = useState<TextProcessing | null>(null);
+  const [replacements, setReplacements] = useState("");
+  const [redaction, setRedaction] = useState<Redaction | null>(null);
+  const [patterns, setPatterns] = useState("");
+  const [status, setStatus] = useState("");
+
+  useEffect(() => {
+    TranscribeService.TextProcessing()
+      .then((value: TextProcessing) => {
+        setSettings(value);
+        setReplacements((value.replacements ?? []).map((entry) => `${entry.find} => ${entry.replace}`).join("\n"));
+      })
+      .catch((err: unknown) => onError(String(err)));
+    TranscribeService.Redaction()
+      .then((value: Redaction) => {
+        setRedaction(value);
+        setPatterns((value.patterns ?? []).map((entry) => `${entry.name} = ${entry.pattern}`).join("\n"));
+      })
+      .catch((err: unknown) => onError(String(err)));
+  }, []);
+
+  if (!settings || !redaction) {
+    return null;
+  }
+
+  const save = () => {
+    Promise.all([
+      TranscribeService.SaveTextProcessing({ ...settings, replacements: parseReplacements(replacements) }),
+      TranscribeService.SaveRedaction({ ...redaction, patterns: parsePatterns(patterns) }),
+    ])
+      .then(() => setStatus("Next session uses these settings"))
+      .catch((err: unknown) => onError(String(err)));
+  };
+
+  return (
+    <div className="relative z-10 flex shrink-0 flex-col gap-1.5 px-2.5 pb-2 text-xs">
+      <div className="flex items-center gap-2">
+        {toggles.map((toggle) => (
+          <button
+            key={toggle.key}
+            type="button"
+            onClick={() => setSettings({ ...settings, [toggle.key]: !settings[toggle.key] })}
+            className={`cursor-pointer mono-button h-7 flex-1 rounded-md px-2 ${
+              settings[toggle.key] ? "text-blue-300" : "text-white/40"
+            }`}
+            title={toggle.title}
+            aria-label={toggle.title}
+            aria-pressed={settings[toggle.key]}
+          >
+            {toggle.label}
+          </button>
+        ))}
+        <button
+          type="button"
+          onClick={save}
+          className="cursor-pointer mono-button grid h-7 w-7 place-items-center rounded-md"
+          title="Save text processing"
+          aria-label="Save text processing"
+        >
+          <Save size={14} />
+        </button>
+      </div>
+      <textarea
+        value={replacements}
+        onChange={(event) => setReplacements(event.target.value)}
+        placeholder="Replacements, one per line: cube control => kubectl"
+        rows={3}
+        className="mono-select resize-none rounded-md px-2 py-1 outline-none"
+        aria-label="Replacements"
+      />
+      <div className="flex items-center gap-2">
+        <button
+          type="button"
+          onClick={() => setRedaction({ ...redaction, enabled: !redaction.enabled })}
+          className={`cursor-pointer mono-button grid h-7 w-7 place-items-center rounded-md ${
+            redaction.enabled ? "text-blue-300" : "text-white/40"
+          }`}
+          title={redaction.enabled ? "Redacting cards, emails, phones and IDs" : "Not redacting personal data"}
+          aria-label="Redact personal data"
+          aria-pressed={redaction.enabled}
+        >
+          <ShieldCheck size={14} />
+        </button>
+        <select
+          value={redaction.mode || "mask"}
+          onChange={(event) => setRedaction({ ...redaction, mode: event.target.value as Redaction["mode"] })}
+          disabled={!redaction.enabled}
+          className="cursor-pointer mono-select h-7 min-w-0 flex-1 appearance-none rounded-md px-2 outline-none disabled:cursor-not-allowed disabled:opacity-50"
+          title="How personal data is redacted"
+          aria-label="Redaction mode"
+        >
+          <option value="mask">Mask as [EMAIL]</option>
+          <option value="hash">Hash as [EMAIL:3f9a61c2]</option>
+          <option value="drop">Drop</option>
+        </select>
+      </div>
+      {redaction.enabled && (
+        <textarea
+          value={patterns}
+          onChange={(event) => setPatterns(event.target.value)}
+          placeholder="Extra patterns, one per line: account = ACC-\d{6}"
+          rows={2}
+          className="mono-select resize-none rounded-md px-2 py-1 outline-none"
+          aria-label="Redaction patterns"
+        />
+      )}
+      {status && <p className="truncate text-white/50">{status}</p>}
+    </div>
+  );
+}
+
+function parseReplacements(text: string): { find: string; replace: string }[] {
+  return text
+    .split("\n")
+    .map((line) => line.split("=>"))
+    .filter((parts) => parts.length === 2 && parts[0].trim())
+    .map(([find, replace]) => ({ find: find.trim(), replace: replace.trim() }));
+}
+
+// parsePatterns reads "name = expression" lines; the expression may itself contain "=".
+function parsePatterns(text: string): { name: string; pattern: string }[] {
+  return text
+    .split("\n")
+    .map((line) => [line.slice(0, line.indexOf("=")), line.slice(line.indexOf("=") + 1)])
+    .filter(([name, pattern]) => name.trim() && pattern.trim())
+    .map(([name, pattern]) => ({ name: name.trim(), pattern: pattern.trim() }));
+}
+
+export default TextPanel;
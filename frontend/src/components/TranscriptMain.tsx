@@ -1,23 +1,42 @@
+import { useState } from "react";
 import type { RefObject } from "react";
 
 import { formatTimeFromMilliseconds as formatTime } from "../lib/format";
-import type { TranscriptLine } from "../types/transcription";
+import { lineKey } from "../lib/transcript";
+import type { TranscriptLine, TranscriptWord } from "../types/transcription";
 
 type TranscriptMainProps = {
   finalLines: TranscriptLine[];
   liveLine: TranscriptLine | null;
+  // Names given to speakers by their number; unnamed speakers show as "Speaker N".
+  speakerNames: Record<number, string>;
+  onRenameSpeaker: (speaker: number, name: string) => void;
   displayText: string;
   active: boolean;
   error: string;
   scrollContainerRef: RefObject<HTMLDivElement | null>;
 };
 
-function TranscriptMain({ finalLines, liveLine, displayText, active, error, scrollContainerRef }: TranscriptMainProps) {
+function TranscriptMain({
+  finalLines,
+  liveLine,
+  speakerNames,
+  onRenameSpeaker,
+  displayText,
+  active,
+  error,
+  scrollContainerRef,
+}: TranscriptMainProps) {
   return (
     <div ref={scrollContainerRef} className="transcript-scroll relative z-10 min-h-0 flex-1 overflow-y-auto">
       <div className="grid gap-1.5 p-2">
         {finalLines.map((line) => (
-          <TranscriptSegment key={line.id} line={line} />
+          <TranscriptSegment
+            key={lineKey(line)}
+            line={line}
+            speakerName={speakerNames[line.speaker]}
+            onRenameSpeaker={onRenameSpeaker}
+          />
         ))}
         {(error || liveLine || active) && (
           <article
@@ -50,15 +69,96 @@ function TranscriptMain({ finalLines, liveLine, displayText, active, error, scro
   );
 }
 
-function TranscriptSegment({ line }: { line: TranscriptLine }) {
+type TranscriptSegmentProps = {
+  line: TranscriptLine;
+  speakerName?: string;
+  onRenameSpeaker: (speaker: number, name: string) => void;
+};
+
+function TranscriptSegment({ line, speakerName, onRenameSpeaker }: TranscriptSegmentProps) {
   return (
     <article className="grid gap-0.5 rounded-md bg-white/5 px-2.5 py-1.5">
       <time className="text-[9px] font-bold tabular-nums tracking-wide text-blue-400 uppercase">
         {formatTime(line.startMs)} – {formatTime(line.endMs)}
+        {line.speaker > 0 && <SpeakerLabel speaker={line.speaker} name={speakerName} onRename={onRenameSpeaker} />}
+        {line.language && <span className="ml-1.5 text-white/40">{line.language}</span>}
       </time>
-      <p className="text-[13px] leading-5 text-white/90">{line.text}</p>
+      <p
+        className={`text-[13px] leading-5 ${line.annotation ? "italic text-white/50" : "text-white/90"}`}
+        title={line.rawText ? `Recognized as: ${line.rawText}` : undefined}
+      >
+        {/* Timed words follow the recognized text, so rewritten lines show their text instead. */}
+        {line.words.length > 0 && !line.rawText ? <TranscriptWords words={line.words} /> : line.text}
+      </p>
     </article>
   );
 }
 
+type SpeakerLabelProps = {
+  speaker: number;
+  name?: string;
+  onRename: (speaker: number, name: string) => void;
+};
+
+// SpeakerLabel names the speaker of a line. Clicking it edits the name, which applies to every line of the speaker
+// and to exports; an empty name goes back to "Speaker N".
+function SpeakerLabel({ speaker, name, onRename }: SpeakerLabelProps) {
+  const [editing, setEditing] = useState(false);
+  const label = name || `Speaker ${speaker}`;
+
+  if (editing) {
+    const commit = (value: string) => {
+      setEditing(false);
+      if (value.trim() !== (name ?? "")) {
+        onRename(speaker, value);
+      }
+    };
+    return (
+      <input
+        autoFocus
+        defaultValue={name ?? ""}
+        placeholder={`Speaker ${speaker}`}
+        onBlur={(event) => commit(event.target.value)}
+        onKeyDown={(event) => {
+          if (event.key === "Enter") {
+            commit(event.currentTarget.value);
+          } else if (event.key === "Escape") {
+            setEditing(false);
+          }
+        }}
+        className="mono-select ml-1.5 h-4 w-24 rounded px-1 normal-case outline-none"
+        aria-label={`Name of speaker ${speaker}`}
+      />
+    );
+  }
+
+  return (
+    <button
+      type="button"
+      onClick={() => setEditing(true)}
+      className="ml-1.5 cursor-pointer text-white/60 uppercase hover:text-white/90"
+      title="Rename speaker"
+    >
+      {label}
+    </button>
+  );
+}
+
+// Words below this probability are underlined so they can be checked against the audio.
+const lowConfidence = 0.5;
+
+function TranscriptWords({ words }: { words: TranscriptWord[] }) {
+  return words.map((word, index) => (
+    <span key={`${word.startMs}-${index}`}>
+      {index > 0 && " "}
+      <span
+        className={word.probability < lowConfidence ? "underline decoration-amber-300/70 decoration-dotted" : undefined}
+        title={`${formatTime(word.startMs)} · ${Math.round(word.probability * 100)}%`}
+      >
+        {word.text}
+      </span>
+    </span>
+  ));
+}
+
 export default TranscriptMain;
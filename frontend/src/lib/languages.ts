@@ -0,0 +1,14 @@
+// Languages offered in the picker; whisper's multilingual models accept any ISO 639-1 code it knows.
+export const languageOptions: { code: string; label: string }[] = [
+  { code: "auto", label: "Auto" },
+  { code: "en", label: "English" },
+  { code: "vi", label: "Vietnamese" },
+  { code: "zh", label: "Chinese" },
+  { code: "ja", label: "Japanese" },
+  { code: "ko", label: "Korean" },
+  { code: "es", label: "Spanish" },
+  { code: "fr", label: "French" },
+  { code: "de", label: "German" },
+  { code: "pt", label: "Portuguese" },
+  { code: "ru", label: "Russian" },
+];
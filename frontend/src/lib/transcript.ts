@@ -0,0 +1,25 @@
+import type { TranscriptLine } from "@/src/types/transcription.ts";
+
+// lineKey identifies a final line: an utterance split at speaker turns has a line per part.
+export function lineKey(line: TranscriptLine): string {
+  return `${line.utteranceID}:${line.part}`;
+}
+
+// withRefinedLine puts a refined line in place of its utterance's live lines. Refined parts arrive in order, so the
+// first part replaces every live part and the others follow it.
+export function withRefinedLine(lines: TranscriptLine[], refined: TranscriptLine): TranscriptLine[] {
+  const first = lines.findIndex((line) => line.utteranceID === refined.utteranceID);
+  if (first < 0) {
+    return lines;
+  }
+  if (refined.part > 0) {
+    let last = first;
+    while (last + 1 < lines.length && lines[last + 1].utteranceID === refined.utteranceID) {
+      last++;
+    }
+    return [...lines.slice(0, last + 1), refined, ...lines.slice(last + 1)];
+  }
+
+  const rest = lines.slice(first + 1).filter((line) => line.utteranceID !== refined.utteranceID);
+  return [...lines.slice(0, first), refined, ...rest];
+}
@@ -9,7 +9,28 @@ export type RecorderState = {
 
 export type TranscriptLine = {
   id: number;
+  utteranceID: number;
+  // Index of the line among the parts its utterance is split into at speaker turns.
+  part: number;
+  // Speaker of the line, from 1, when the model detects speaker turns; 0 otherwise.
+  speaker: number;
+  revision: number;
   text: string;
+  // Text as recognized when text processing rewrote it; empty otherwise.
+  rawText: string;
+  // Sound class of a music, noise or silence marker; empty for transcribed speech.
+  annotation: string;
+  // Language the line was transcribed as; empty for annotations.
+  language: string;
+  // Timed words of a final line, in session time; empty for partials and annotations.
+  words: TranscriptWord[];
   startMs: number;
   endMs: number;
 };
+
+export type TranscriptWord = {
+  text: string;
+  startMs: number;
+  endMs: number;
+  probability: number;
+};
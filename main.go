@@ -28,6 +28,11 @@ func init() {
 	application.RegisterEvent[services.TranscriptEvent]("transcribe:partial")
 	application.RegisterEvent[services.TranscriptEvent]("transcribe:final")
 	application.RegisterEvent[services.ErrorEvent]("transcribe:error")
+	application.RegisterEvent[services.TranscriptEvent]("transcribe:refined")
+	application.RegisterEvent[services.RefineProgressEvent]("transcribe:refine-progress")
+
+	// Model events
+	application.RegisterEvent[services.ModelProgressEvent]("model:progress")
 }
 
 // The main function serves as the application's entry point. It initializes the application, creates a window,
@@ -39,11 +44,13 @@ func main() {
 	// 'Assets' configures the asset server with the 'FS' variable pointing to the frontend files.
 	// 'Bind' is a list of Go struct instances. The frontend has access to the methods of these instances.
 	// 'Mac' options tailor the application when running an macOS.
+	transcribeService := &services.TranscribeService{}
 	app := application.New(application.Options{
 		Name:        "Ekko",
 		Description: "Local audio transcriber",
 		Services: []application.Service{
-			application.NewService(&services.TranscribeService{}),
+			application.NewService(transcribeService),
+			application.NewService(services.NewModelService(transcribeService)),
 		},
 		Assets: application.AssetOptions{
 			Handler: application.AssetFileServerFS(assets),
@@ -0,0 +1,59 @@
+package ffmpeg
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// WAVFormat is how EncodeWAV stores samples.
+type WAVFormat int
+
+const (
+	// WAVPCM16 stores samples as 16-bit integers, clamped to [-1, 1], which
+	// halves the size of the file.
+	WAVPCM16 WAVFormat = iota
+	// WAVFloat32 stores samples as 32-bit floats, exactly as they were.
+	WAVFloat32
+)
+
+// EncodeWAV writes mono float samples as a WAV file.
+func EncodeWAV(samples []float32, sampleRate int, format WAVFormat) []byte {
+	const (
+		channels     = 1
+		headerSize   = 44
+		formatPCM    = 1
+		formatIEEE   = 3
+		fmtChunkSize = 16
+	)
+	code, bitsPerSample := uint16(formatPCM), 16
+	if format == WAVFloat32 {
+		code, bitsPerSample = formatIEEE, 32
+	}
+	blockAlign := channels * bitsPerSample / 8
+	dataSize := len(samples) * blockAlign
+
+	wav := make([]byte, headerSize+dataSize)
+	copy(wav[0:], "RIFF")
+	binary.LittleEndian.PutUint32(wav[4:], uint32(headerSize-8+dataSize))
+	copy(wav[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(wav[16:], fmtChunkSize)
+	binary.LittleEndian.PutUint16(wav[20:], code)
+	binary.LittleEndian.PutUint16(wav[22:], channels)
+	binary.LittleEndian.PutUint32(wav[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(wav[28:], uint32(sampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(wav[32:], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(wav[34:], uint16(bitsPerSample))
+	copy(wav[36:], "data")
+	binary.LittleEndian.PutUint32(wav[40:], uint32(dataSize))
+
+	data := wav[headerSize:]
+	for i, sample := range samples {
+		if format == WAVFloat32 {
+			binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(sample))
+			continue
+		}
+		clamped := max(-1, min(1, float64(sample)))
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(int16(math.Round(clamped*math.MaxInt16))))
+	}
+	return wav
+}
@@ -0,0 +1,36 @@
+package ffmpeg
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestEncodeWAVClampsSamples(t *testing.T) {
+	wav := EncodeWAV([]float32{0, 1, -2}, 16000, WAVPCM16)
+	if len(wav) != 44+6 {
+		t.Fatalf("expected a 44-byte header and 6 bytes of samples, got %d bytes", len(wav))
+	}
+	if rate := binary.LittleEndian.Uint32(wav[24:28]); rate != 16000 {
+		t.Fatalf("expected 16000 Hz, got %d", rate)
+	}
+	if got := int16(binary.LittleEndian.Uint16(wav[46:48])); got != 32767 {
+		t.Fatalf("expected full scale, got %d", got)
+	}
+	if got := int16(binary.LittleEndian.Uint16(wav[48:50])); got != -32767 {
+		t.Fatalf("expected a clamped negative sample, got %d", got)
+	}
+}
+
+func TestEncodeWAVKeepsFloatSamples(t *testing.T) {
+	wav := EncodeWAV([]float32{0.25, -2}, 16000, WAVFloat32)
+	if len(wav) != 44+8 || string(wav[:4]) != "RIFF" || string(wav[36:40]) != "data" {
+		t.Fatalf("expected a 44-byte header and 8 bytes of samples, got %d bytes", len(wav))
+	}
+	if format := binary.LittleEndian.Uint16(wav[20:22]); format != 3 {
+		t.Fatalf("expected the IEEE float format, got %d", format)
+	}
+	if got := math.Float32frombits(binary.LittleEndian.Uint32(wav[48:52])); got != -2 {
+		t.Fatalf("expected the sample unclamped, got %v", got)
+	}
+}
@@ -0,0 +1,327 @@
+// Package openai transcribes audio with a server implementing OpenAI's
+// /v1/audio/transcriptions API, such as faster-whisper-server or the
+// whisper.cpp server.
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/ffmpeg"
+	"github.com/tuanta7/ekko/services/adapter/speech"
+)
+
+const (
+	// DefaultModel is the model requested when Config.Model is empty.
+	DefaultModel = "whisper-1"
+	// DefaultTimeout bounds one request, including the upload.
+	DefaultTimeout = 30 * time.Second
+	// DefaultRetries is the number of retries after a failed request.
+	DefaultRetries = 2
+	// DefaultConcurrency is the number of requests sent at once.
+	DefaultConcurrency = 2
+
+	// sampleRate is the rate of the samples passed to Transcribe.
+	sampleRate = 16000
+	// retryBackoff is the wait before the first retry; it doubles after each.
+	retryBackoff = 250 * time.Millisecond
+)
+
+// Config points the client at a server.
+type Config struct {
+	// URL is the API base URL, such as http://gpu-box:8000/v1.
+	URL string
+	// Model is the model name sent with every request.
+	Model string
+	// APIKey is sent as a bearer token when set.
+	APIKey string
+	// Timeout bounds each attempt.
+	Timeout time.Duration
+	// Retries is the number of retries after a network error, a 429 or a 5xx.
+	// Zero means DefaultRetries and a negative value disables retries.
+	Retries int
+	// Concurrency is the number of chunks sent at once.
+	Concurrency int
+}
+
+// ConfigFromEnv reads EKKO_OPENAI_URL, EKKO_OPENAI_MODEL, EKKO_OPENAI_API_KEY,
+// EKKO_OPENAI_TIMEOUT and EKKO_OPENAI_CONCURRENCY. URL is empty when the
+// backend is not configured.
+func ConfigFromEnv() Config {
+	config := Config{
+		URL:    os.Getenv("EKKO_OPENAI_URL"),
+		Model:  os.Getenv("EKKO_OPENAI_MODEL"),
+		APIKey: os.Getenv("EKKO_OPENAI_API_KEY"),
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("EKKO_OPENAI_TIMEOUT")); err == nil {
+		config.Timeout = timeout
+	}
+	if concurrency, err := strconv.Atoi(os.Getenv("EKKO_OPENAI_CONCURRENCY")); err == nil {
+		config.Concurrency = concurrency
+	}
+	return config
+}
+
+// Client is a transcription backend backed by an HTTP server.
+type Client struct {
+	config Config
+	http   *http.Client
+}
+
+// NewClient checks the config and fills in defaults.
+func NewClient(config Config) (*Client, error) {
+	base, err := url.Parse(config.URL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid transcription server URL %q", config.URL)
+	}
+	config.URL = strings.TrimSuffix(config.URL, "/")
+	if config.Model == "" {
+		config.Model = DefaultModel
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.Retries < 0 {
+		config.Retries = 0
+	} else if config.Retries == 0 {
+		config.Retries = DefaultRetries
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = DefaultConcurrency
+	}
+	return &Client{config: config, http: &http.Client{}}, nil
+}
+
+// Capabilities reports what the client can ask of the server. The model behind
+// the server is unknown, so every language is accepted and the server rejects
+// what it cannot do.
+func (c *Client) Capabilities() speech.Capabilities {
+	return speech.Capabilities{
+		Multilingual:   true,
+		Translate:      true,
+		WordTimestamps: true,
+		Concurrency:    c.config.Concurrency,
+	}
+}
+
+// Close releases idle connections.
+func (c *Client) Close() error {
+	c.http.CloseIdleConnections()
+	return nil
+}
+
+// Transcribe uploads the samples as WAV and maps the verbose_json response to
+// segments. Only the temperature of options.Decode is sent; servers pick their
+// own decoding otherwise. Cancelling ctx cancels the request and any retry.
+func (c *Client) Transcribe(ctx context.Context, samples []float32, options speech.TranscribeOptions) (speech.Result, error) {
+	if len(samples) == 0 {
+		return speech.Result{}, nil
+	}
+
+	body, contentType, err := c.requestBody(samples, options)
+	if err != nil {
+		return speech.Result{}, err
+	}
+	speech.Started(ctx)
+	endpoint := c.config.URL + "/audio/transcriptions"
+	if options.Translate {
+		endpoint = c.config.URL + "/audio/translations"
+	}
+
+	var response verboseResponse
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		response, retry, err = c.post(ctx, endpoint, contentType, body)
+		if err == nil || !retry || attempt == c.config.Retries || ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff *= 2
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return speech.Result{}, ctxErr
+	}
+	if err != nil {
+		return speech.Result{}, err
+	}
+
+	return response.result(options), nil
+}
+
+// requestBody builds the multipart form of a request.
+func (c *Client) requestBody(samples []float32, options speech.TranscribeOptions) ([]byte, string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	file, err := form.CreateFormFile("file", "chunk.wav")
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := file.Write(ffmpeg.EncodeWAV(samples, sampleRate, ffmpeg.WAVPCM16)); err != nil {
+		return nil, "", err
+	}
+
+	fields := [][2]string{
+		{"model", c.config.Model},
+		{"response_format", "verbose_json"},
+		{"timestamp_granularities[]", "segment"},
+		{"temperature", strconv.FormatFloat(float64(options.Decode.Temperature), 'f', -1, 32)},
+	}
+	if options.TokenTimestamps {
+		fields = append(fields, [2]string{"timestamp_granularities[]", "word"})
+	}
+	if options.Language != "" && options.Language != speech.AutoLanguage && !options.Translate {
+		fields = append(fields, [2]string{"language", options.Language})
+	}
+	if options.InitialPrompt != "" {
+		fields = append(fields, [2]string{"prompt", options.InitialPrompt})
+	}
+	for _, field := range fields {
+		if err := form.WriteField(field[0], field[1]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := form.Close(); err != nil {
+		return nil, "", err
+	}
+	return body.Bytes(), form.FormDataContentType(), nil
+}
+
+// post sends one attempt and reports whether a failure is worth retrying.
+func (c *Client) post(ctx context.Context, endpoint, contentType string, body []byte) (verboseResponse, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return verboseResponse{}, false, err
+	}
+	request.Header.Set("Content-Type", contentType)
+	if c.config.APIKey != "" {
+		request.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return verboseResponse{}, true, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+		return verboseResponse{}, retry, fmt.Errorf("transcription server: %s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+
+	var decoded verboseResponse
+	if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+		return verboseResponse{}, false, fmt.Errorf("transcription server: decode response: %w", err)
+	}
+	return decoded, false, nil
+}
+
+// verboseResponse is the verbose_json response format.
+type verboseResponse struct {
+	Language string           `json:"language"`
+	Text     string           `json:"text"`
+	Segments []verboseSegment `json:"segments"`
+	Words    []verboseWord    `json:"words"`
+}
+
+type verboseSegment struct {
+	Start        float64 `json:"start"`
+	End          float64 `json:"end"`
+	Text         string  `json:"text"`
+	NoSpeechProb float32 `json:"no_speech_prob"`
+}
+
+type verboseWord struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	// Probability is an extension of faster-whisper based servers; OpenAI
+	// leaves it out.
+	Probability *float32 `json:"probability"`
+}
+
+// result maps the response to segments, giving each its words as tokens.
+func (r verboseResponse) result(options speech.TranscribeOptions) speech.Result {
+	result := speech.Result{Language: responseLanguage(r.Language, options)}
+
+	segments := r.Segments
+	if len(segments) == 0 && strings.TrimSpace(r.Text) != "" {
+		segments = []verboseSegment{{Text: r.Text}}
+	}
+
+	segments = slices.DeleteFunc(slices.Clone(segments), func(segment verboseSegment) bool {
+		return strings.TrimSpace(segment.Text) == ""
+	})
+	words := r.Words
+	for i, segment := range segments {
+		mapped := speech.Segment{
+			Start:               seconds(segment.Start),
+			End:                 seconds(segment.End),
+			Text:                strings.TrimSpace(segment.Text),
+			NoSpeechProbability: segment.NoSpeechProb,
+		}
+		// Words belong to the segment they start in; the last segment with
+		// text takes the rest.
+		last := i == len(segments)-1
+		for len(words) > 0 && (last || words[0].Start < segment.End) {
+			mapped.Tokens = append(mapped.Tokens, words[0].token())
+			words = words[1:]
+		}
+		result.Segments = append(result.Segments, mapped)
+	}
+	return result
+}
+
+// token converts a word to a token, which starts with a space when it
+// starts a word.
+func (w verboseWord) token() speech.Token {
+	probability := float32(1)
+	if w.Probability != nil {
+		probability = *w.Probability
+	}
+	return speech.Token{
+		Text:        " " + strings.TrimSpace(w.Word),
+		Start:       seconds(w.Start),
+		End:         seconds(w.End),
+		Probability: probability,
+	}
+}
+
+// responseLanguage returns the language code of a response. OpenAI reports the
+// language by name, such as "english"; those fall back to the requested code.
+func responseLanguage(language string, options speech.TranscribeOptions) string {
+	if len(language) == 2 {
+		return language
+	}
+	if options.Translate {
+		return "en"
+	}
+	if options.Language != speech.AutoLanguage {
+		return options.Language
+	}
+	return ""
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
@@ -0,0 +1,202 @@
+package openai
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/speech"
+)
+
+const verboseJSON = `{
+	"task": "transcribe",
+	"language": "english",
+	"duration": 3.2,
+	"text": "Good morning. Let's begin.",
+	"segments": [
+		{"id": 0, "start": 0.0, "end": 1.4, "text": " Good morning.", "avg_logprob": -0.2, "compression_ratio": 0.9, "no_speech_prob": 0.01},
+		{"id": 1, "start": 1.6, "end": 3.1, "text": " Let's begin.", "avg_logprob": -0.3, "compression_ratio": 0.9, "no_speech_prob": 0.02}
+	],
+	"words": [
+		{"word": "Good", "start": 0.0, "end": 0.5, "probability": 0.9},
+		{"word": "morning.", "start": 0.5, "end": 1.4, "probability": 0.8},
+		{"word": "Let's", "start": 1.6, "end": 2.1},
+		{"word": "begin.", "start": 2.1, "end": 3.1}
+	]
+}`
+
+func TestClientTranscribesVerboseJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			t.Errorf("expected the transcriptions endpoint, got %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("expected the API key as bearer token, got %q", got)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		form := r.MultipartForm.Value
+		if form["model"][0] != "large-v3" || form["response_format"][0] != "verbose_json" || form["language"][0] != "en" {
+			t.Errorf("unexpected form fields %v", form)
+		}
+		if !slices.Equal(form["timestamp_granularities[]"], []string{"segment", "word"}) {
+			t.Errorf("expected segment and word timestamps, got %v", form["timestamp_granularities[]"])
+		}
+		if form["prompt"][0] != "Ekko." {
+			t.Errorf("expected the initial prompt, got %v", form["prompt"])
+		}
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("read file: %v", err)
+		} else {
+			wav, _ := io.ReadAll(file)
+			if string(wav[:4]) != "RIFF" || binary.LittleEndian.Uint32(wav[40:44]) != 2*16000 {
+				t.Errorf("expected a WAV file with one second of 16-bit audio, got %d bytes", len(wav))
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, verboseJSON)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{URL: server.URL + "/v1/", Model: "large-v3", APIKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := client.Transcribe(context.Background(), make([]float32, 16000), speech.TranscribeOptions{
+		TokenTimestamps: true,
+		Language:        "en",
+		InitialPrompt:   "Ekko.",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Language != "en" {
+		t.Fatalf("expected the requested language for a named one, got %q", result.Language)
+	}
+	if len(result.Segments) != 2 {
+		t.Fatalf("expected 2 segments, got %d", len(result.Segments))
+	}
+	first, second := result.Segments[0], result.Segments[1]
+	if first.Text != "Good morning." || first.End != 1400*time.Millisecond || first.NoSpeechProbability != 0.01 {
+		t.Fatalf("unexpected first segment %+v", first)
+	}
+	words := first.Words()
+	if len(words) != 2 || words[1].Text != "morning." || words[1].Probability != 0.8 {
+		t.Fatalf("expected the first segment's words with probabilities, got %+v", words)
+	}
+	if words := second.Words(); len(words) != 2 || words[0].Start != 1600*time.Millisecond || words[0].Probability != 1 {
+		t.Fatalf("expected the second segment's words, got %+v", words)
+	}
+}
+
+func TestVerboseResultKeepsWordsPastTheLastSegment(t *testing.T) {
+	response := verboseResponse{
+		Segments: []verboseSegment{{End: 1, Text: " Good morning."}, {Start: 1, End: 2, Text: " "}},
+		Words: []verboseWord{
+			{Word: "Good", End: 0.5},
+			{Word: "morning.", Start: 0.5, End: 1},
+			{Word: "everyone.", Start: 1.2, End: 1.8},
+		},
+	}
+
+	result := response.result(speech.TranscribeOptions{})
+	if len(result.Segments) != 1 || len(result.Segments[0].Tokens) != 3 {
+		t.Fatalf("expected every word in the only segment with text, got %+v", result.Segments)
+	}
+}
+
+func TestClientRetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			http.Error(w, "warming up", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, verboseJSON)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Transcribe(context.Background(), make([]float32, 1600), speech.TranscribeOptions{}); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("expected 2 requests, got %d", got)
+	}
+}
+
+func TestClientDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "unknown model", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{URL: server.URL, Retries: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Transcribe(context.Background(), make([]float32, 1600), speech.TranscribeOptions{}); err == nil {
+		t.Fatal("expected a bad request to fail")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected 1 request, got %d", got)
+	}
+}
+
+func TestClientTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := NewClient(Config{URL: server.URL, Timeout: 50 * time.Millisecond, Retries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Transcribe(context.Background(), make([]float32, 1600), speech.TranscribeOptions{}); err == nil {
+		t.Fatal("expected a hung server to time out")
+	}
+}
+
+func TestClientStopsOnCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := NewClient(Config{URL: server.URL, Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	if _, err := client.Transcribe(ctx, make([]float32, 1600), speech.TranscribeOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context's error, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("expected cancellation to skip retries, took %s", elapsed)
+	}
+}
@@ -0,0 +1,92 @@
+package speech
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Strategy is how whisper picks each token.
+type Strategy string
+
+const (
+	// StrategyGreedy takes the most likely token, sampling BestOf candidates
+	// when it falls back to a higher temperature.
+	StrategyGreedy Strategy = "greedy"
+	// StrategyBeam keeps the BeamSize most likely sequences. It is slower but
+	// more accurate than greedy decoding.
+	StrategyBeam Strategy = "beam"
+)
+
+// maxAudioContext is the encoder context of a full 30-second window.
+const maxAudioContext = 1500
+
+// DecodeOptions tune whisper's decoder. Zero values keep whisper's defaults,
+// except TemperatureIncrement, where zero disables the fallback.
+type DecodeOptions struct {
+	// Strategy defaults to StrategyGreedy.
+	Strategy Strategy `json:"strategy"`
+	// BeamSize is the number of beams with StrategyBeam. Whisper defaults to 5.
+	BeamSize int `json:"beamSize"`
+	// BestOf is the number of candidates sampled at each fallback temperature
+	// with StrategyGreedy. Whisper defaults to 5.
+	BestOf int `json:"bestOf"`
+	// Temperature is the temperature of the first attempt. Zero always picks
+	// the most likely token.
+	Temperature float32 `json:"temperature"`
+	// TemperatureIncrement raises the temperature for another attempt whenever
+	// a decode looks unreliable (high compression ratio or low log
+	// probability). Zero or less disables the fallback.
+	TemperatureIncrement float32 `json:"temperatureIncrement"`
+	// MaxSegmentLength splits segments at word boundaries past this many
+	// characters. Zero does not split.
+	MaxSegmentLength int `json:"maxSegmentLength"`
+	// Threads is the number of CPU threads. Zero uses the context's share of
+	// the CPUs.
+	Threads int `json:"threads"`
+	// AudioContext shrinks the encoder context, up to 1500 for 30 seconds of
+	// audio. Short chunks encode faster with a smaller context at some cost in
+	// accuracy. Zero uses the full context.
+	AudioContext int `json:"audioContext"`
+}
+
+// DefaultPartialDecodeOptions favor latency: partials are revised until the
+// final arrives, so a cheaper decode is enough. The encoder context of 512
+// covers about ten seconds, twice the chunker's partial window.
+func DefaultPartialDecodeOptions() DecodeOptions {
+	return DecodeOptions{
+		Strategy:     StrategyGreedy,
+		BestOf:       1,
+		AudioContext: 512,
+	}
+}
+
+// DefaultFinalDecodeOptions favor accuracy: finals are what gets kept.
+func DefaultFinalDecodeOptions() DecodeOptions {
+	return DecodeOptions{
+		Strategy:             StrategyBeam,
+		BeamSize:             5,
+		BestOf:               5,
+		TemperatureIncrement: 0.2,
+	}
+}
+
+// Validate reports options whisper would reject or misread.
+func (o DecodeOptions) Validate() error {
+	switch o.Strategy {
+	case "", StrategyGreedy, StrategyBeam:
+	default:
+		return fmt.Errorf("unknown decoding strategy %q", o.Strategy)
+	}
+
+	var errs []error
+	if o.BeamSize < 0 || o.BestOf < 0 || o.MaxSegmentLength < 0 || o.Threads < 0 {
+		errs = append(errs, errors.New("beam size, best-of, max segment length and threads must not be negative"))
+	}
+	if o.Temperature < 0 || o.Temperature > 1 {
+		errs = append(errs, fmt.Errorf("temperature %.2f is outside 0 to 1", o.Temperature))
+	}
+	if o.AudioContext < 0 || o.AudioContext > maxAudioContext {
+		errs = append(errs, fmt.Errorf("audio context %d is outside 0 to %d", o.AudioContext, maxAudioContext))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,29 @@
+package speech
+
+import "testing"
+
+func TestDecodeOptionsValidate(t *testing.T) {
+	valid := []DecodeOptions{
+		{},
+		DefaultPartialDecodeOptions(),
+		DefaultFinalDecodeOptions(),
+		{Strategy: StrategyGreedy, Temperature: 1, TemperatureIncrement: -1, AudioContext: maxAudioContext},
+	}
+	for _, options := range valid {
+		if err := options.Validate(); err != nil {
+			t.Fatalf("expected %+v to be valid, got %v", options, err)
+		}
+	}
+
+	invalid := []DecodeOptions{
+		{Strategy: "sampling"},
+		{BeamSize: -1},
+		{Temperature: 1.5},
+		{AudioContext: maxAudioContext + 1},
+	}
+	for _, options := range invalid {
+		if err := options.Validate(); err == nil {
+			t.Fatalf("expected %+v to be rejected", options)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package speech
+
+import (
+	"bytes"
+	"compress/zlib"
+	"math"
+)
+
+// AvgLogProbability returns the mean log probability of the segment's tokens,
+// as whisper reports per segment. Values near zero mean a confident decode;
+// zero is also returned for a segment without tokens.
+func (s Segment) AvgLogProbability() float32 {
+	if len(s.Tokens) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, token := range s.Tokens {
+		sum += math.Log(max(float64(token.Probability), 1e-10))
+	}
+	return float32(sum / float64(len(s.Tokens)))
+}
+
+// CompressionRatio returns the length of the text divided by its zlib
+// compressed length. Repetitive text compresses well, so a high ratio flags
+// decoding loops.
+func (s Segment) CompressionRatio() float32 {
+	if s.Text == "" {
+		return 0
+	}
+
+	// Go's default level barely compresses short texts; the best level comes
+	// close to the zlib whisper's reference threshold of 2.4 was tuned with.
+	var compressed bytes.Buffer
+	writer, _ := zlib.NewWriterLevel(&compressed, zlib.BestCompression)
+	_, _ = writer.Write([]byte(s.Text))
+	_ = writer.Close()
+	return float32(len(s.Text)) / float32(compressed.Len())
+}
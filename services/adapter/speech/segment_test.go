@@ -0,0 +1,53 @@
+package speech
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSegmentCompressionRatioFlagsRepetition(t *testing.T) {
+	speech := Segment{Text: "We moved the release to Thursday because the installer still fails on macOS."}
+	loop := Segment{Text: strings.Repeat("I'm going to go to the store. ", 8)}
+
+	if ratio := speech.CompressionRatio(); ratio > 2.4 {
+		t.Fatalf("expected ordinary speech to compress poorly, got ratio %.2f", ratio)
+	}
+	if ratio := loop.CompressionRatio(); ratio <= 2.4 {
+		t.Fatalf("expected a repeated sentence to compress well, got ratio %.2f", ratio)
+	}
+}
+
+func TestSegmentAvgLogProbability(t *testing.T) {
+	segment := Segment{Tokens: []Token{{Probability: 1}, {Probability: float32(math.Exp(-2))}}}
+	if got := segment.AvgLogProbability(); math.Abs(float64(got)+1) > 1e-5 {
+		t.Fatalf("expected -1, got %f", got)
+	}
+	if got := (Segment{}).AvgLogProbability(); got != 0 {
+		t.Fatalf("expected 0 without tokens, got %f", got)
+	}
+}
+
+func TestSegmentWordsGroupsTokens(t *testing.T) {
+	segment := Segment{Tokens: []Token{
+		{Text: " Hel", Start: 0, End: 200 * time.Millisecond, Probability: 0.9},
+		{Text: "lo", Start: 200 * time.Millisecond, End: 400 * time.Millisecond, Probability: 0.5},
+		{Text: ",", Start: 400 * time.Millisecond, End: 420 * time.Millisecond, Probability: 0.7},
+		{Text: " world", Start: 500 * time.Millisecond, End: 900 * time.Millisecond, Probability: 0.8},
+	}}
+
+	words := segment.Words()
+	if len(words) != 2 {
+		t.Fatalf("expected 2 words, got %d", len(words))
+	}
+	if words[0].Text != "Hello," || words[0].Start != 0 || words[0].End != 420*time.Millisecond {
+		t.Fatalf("expected Hello, over 0-420ms, got %+v", words[0])
+	}
+	if math.Abs(float64(words[0].Probability)-0.7) > 1e-6 {
+		t.Fatalf("expected the mean token probability 0.7, got %f", words[0].Probability)
+	}
+	if words[1].Text != "world" || words[1].Probability != 0.8 {
+		t.Fatalf("expected world at 0.8, got %+v", words[1])
+	}
+}
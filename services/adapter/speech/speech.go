@@ -0,0 +1,134 @@
+// Package speech holds the types shared by the transcription backends: the
+// options of a transcription and the segments, tokens and words it returns. It
+// is pure Go, so that backends that do not link whisper.cpp can use it.
+package speech
+
+import (
+	"strings"
+	"time"
+)
+
+// AutoLanguage asks a multilingual model to detect the spoken language.
+const AutoLanguage = "auto"
+
+// TranscribeOptions configure one transcription.
+type TranscribeOptions struct {
+	TokenTimestamps bool
+	// Language is the spoken language as an ISO 639-1 code such as "en", or
+	// AutoLanguage. Empty means AutoLanguage. English-only models ignore it.
+	Language string
+	// Translate outputs English text whatever the spoken language. It requires
+	// a multilingual model.
+	Translate bool
+	// InitialPrompt is text whisper treats as preceding the audio. It steers
+	// spelling and style, e.g. towards names from a glossary.
+	InitialPrompt string
+	// Decode tunes the decoder. The zero value decodes greedily with whisper's
+	// defaults.
+	Decode DecodeOptions
+}
+
+// Result is the output of one Transcribe call.
+type Result struct {
+	Segments []Segment
+	// Language is the language the chunk was transcribed as.
+	Language string
+	// LanguageProbability is the detection confidence when Language was
+	// detected, and zero when it was given or the model is English-only.
+	LanguageProbability float32
+}
+
+// Segment is a stretch of transcribed text with its timing.
+type Segment struct {
+	Start  time.Duration `json:"start"`
+	End    time.Duration `json:"end"`
+	Text   string        `json:"text"`
+	Tokens []Token       `json:"tokens,omitempty"`
+	// NoSpeechProbability is whisper's probability that the segment's audio
+	// holds no speech at all, from the no-speech token at its start.
+	NoSpeechProbability float32 `json:"noSpeechProbability"`
+	// SpeakerTurnNext reports that another speaker takes over after the
+	// segment. Only tinydiarize models detect turns.
+	SpeakerTurnNext bool `json:"speakerTurnNext,omitempty"`
+}
+
+// Token is one text token of a segment. Start and End are relative to the
+// beginning of the transcribed samples and are only set when TokenTimestamps is
+// requested.
+type Token struct {
+	Text        string        `json:"text"`
+	Start       time.Duration `json:"start"`
+	End         time.Duration `json:"end"`
+	Probability float32       `json:"probability"`
+}
+
+// Word is a whitespace-delimited word made of one or more tokens. Probability
+// is the mean probability of its tokens.
+type Word struct {
+	Text        string        `json:"text"`
+	Start       time.Duration `json:"start"`
+	End         time.Duration `json:"end"`
+	Probability float32       `json:"probability"`
+}
+
+// Words groups the segment's tokens into words. Whisper tokens are sub-word
+// pieces; a token starting with a space begins a new word, and punctuation
+// stays attached to the word before it.
+func (s Segment) Words() []Word {
+	var words []Word
+	var tokens int
+	for _, token := range s.Tokens {
+		if len(words) == 0 || strings.HasPrefix(token.Text, " ") {
+			if tokens > 0 {
+				words[len(words)-1].Probability /= float32(tokens)
+			}
+			words = append(words, Word{Start: token.Start})
+			tokens = 0
+		}
+
+		current := &words[len(words)-1]
+		current.Text += token.Text
+		current.End = token.End
+		current.Probability += token.Probability
+		tokens++
+	}
+	if tokens > 0 {
+		words[len(words)-1].Probability /= float32(tokens)
+	}
+
+	for i := range words {
+		words[i].Text = strings.TrimSpace(words[i].Text)
+	}
+	return words
+}
+
+// Capabilities describe what a transcription backend supports.
+type Capabilities struct {
+	// Languages are the ISO 639-1 codes the backend transcribes. Nil means the
+	// backend cannot tell and accepts any code.
+	Languages []string `json:"languages"`
+	// Multilingual reports support for languages other than English, including
+	// detecting the spoken language.
+	Multilingual bool `json:"multilingual"`
+	// Translate reports whether the backend can translate into English.
+	Translate bool `json:"translate"`
+	// WordTimestamps reports whether TokenTimestamps yields timed tokens.
+	WordTimestamps bool `json:"wordTimestamps"`
+	// Concurrency is the number of chunks the backend transcribes at once.
+	Concurrency int `json:"concurrency"`
+	// SpeakerTurns reports whether segments mark speaker turns with
+	// SpeakerTurnNext.
+	SpeakerTurns bool `json:"speakerTurns"`
+}
+
+// CombineSegments joins the text of segments, skipping empty ones.
+func CombineSegments(segments []Segment) string {
+	var parts []string
+	for _, segment := range segments {
+		text := strings.TrimSpace(segment.Text)
+		if text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
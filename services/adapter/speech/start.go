@@ -0,0 +1,22 @@
+package speech
+
+import "context"
+
+// startKey is the context key of the function WithStart attaches.
+type startKey struct{}
+
+// WithStart returns a context under which a transcription calls start once
+// its inference begins, after any wait for the backend, such as for a free
+// whisper context. Callers use it to time inference alone.
+func WithStart(ctx context.Context, start func()) context.Context {
+	return context.WithValue(ctx, startKey{}, start)
+}
+
+// Started reports to the caller of a transcription under ctx that its
+// inference begins. Backends call it once they stop waiting; it does nothing
+// without WithStart.
+func Started(ctx context.Context) {
+	if start, ok := ctx.Value(startKey{}).(func()); ok {
+		start()
+	}
+}
@@ -0,0 +1,173 @@
+package whisper
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const defaultModel = "tiny.en-q5_1"
+
+// ggmlMagic opens every ggml model file.
+const ggmlMagic = 0x67676d6c
+
+// multilingualVocabulary is the vocabulary size of multilingual models;
+// English-only models have one token fewer.
+const multilingualVocabulary = 51865
+
+// quantizationVersionFactor separates the quantization version from the
+// tensor type in a model's ftype.
+const quantizationVersionFactor = 1000
+
+// modelNamePattern is what a model name may contain, so that it stays a plain
+// file name.
+var modelNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ModelHeader is what a ggml model file says about itself.
+type ModelHeader struct {
+	// Size is the model family, such as "tiny" or "large", read from the
+	// number of encoder layers. It is empty for unknown layouts.
+	Size string
+	// Multilingual reports whether the model transcribes languages other than
+	// English.
+	Multilingual bool
+	// Quantization is the tensor type, such as "f16" or "q5_1".
+	Quantization string
+}
+
+// modelSizes names the model families by encoder layer count.
+var modelSizes = map[int32]string{4: "tiny", 6: "base", 12: "small", 24: "medium", 32: "large"}
+
+// quantizations names ggml's file types.
+var quantizations = map[int32]string{
+	0: "f32", 1: "f16", 2: "q4_0", 3: "q4_1", 7: "q8_0", 8: "q5_0", 9: "q5_1",
+	10: "q2_k", 11: "q3_k", 12: "q4_k", 13: "q5_k", 14: "q6_k",
+}
+
+// ReadModelHeader reads the hyperparameters at the start of a ggml model file
+// without loading the model.
+func ReadModelHeader(path string) (ModelHeader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ModelHeader{}, err
+	}
+	defer file.Close()
+
+	return readModelHeader(file)
+}
+
+func readModelHeader(r io.Reader) (ModelHeader, error) {
+	var header struct {
+		Magic        uint32
+		Vocabulary   int32
+		AudioContext int32
+		AudioState   int32
+		AudioHeads   int32
+		AudioLayers  int32
+		TextContext  int32
+		TextState    int32
+		TextHeads    int32
+		TextLayers   int32
+		Mels         int32
+		FileType     int32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return ModelHeader{}, fmt.Errorf("read model header: %w", err)
+	}
+	if header.Magic != ggmlMagic {
+		return ModelHeader{}, errors.New("not a ggml model file")
+	}
+
+	quantization, ok := quantizations[header.FileType%quantizationVersionFactor]
+	if !ok {
+		quantization = fmt.Sprintf("type %d", header.FileType%quantizationVersionFactor)
+	}
+	return ModelHeader{
+		Size:         modelSizes[header.AudioLayers],
+		Multilingual: header.Vocabulary >= multilingualVocabulary,
+		Quantization: quantization,
+	}, nil
+}
+
+// ModelName returns the model named by EKKO_MODEL, or the default one.
+func ModelName() string {
+	if name := os.Getenv("EKKO_MODEL"); name != "" {
+		return name
+	}
+	return defaultModel
+}
+
+// ValidModelName reports whether name can name a model file.
+func ValidModelName(name string) bool {
+	return modelNamePattern.MatchString(name)
+}
+
+// ModelFile returns the file name of the named model.
+func ModelFile(name string) string {
+	return "ggml-" + name + ".bin"
+}
+
+// ModelNameOf returns the model name of a model file name, or false when the
+// file is not named like one.
+func ModelNameOf(file string) (string, bool) {
+	name, ok := strings.CutPrefix(file, "ggml-")
+	if !ok {
+		return "", false
+	}
+	name, ok = strings.CutSuffix(name, ".bin")
+	return name, ok && ValidModelName(name)
+}
+
+// IsTinydiarize reports whether the model file at path is a tinydiarize model,
+// fine-tuned to emit speaker-turn tokens. ggml files carry no marker for it, so
+// it goes by whisper.cpp's naming, as in ggml-small.en-tdrz.bin.
+func IsTinydiarize(path string) bool {
+	name, ok := ModelNameOf(filepath.Base(path))
+	return ok && slices.Contains(strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '.' }), "tdrz")
+}
+
+// UserModelDir is where models installed from the app are kept:
+// $XDG_DATA_HOME/ekko/ggml, or ~/.local/share/ekko/ggml.
+func UserModelDir() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "ekko", "ggml"), nil
+}
+
+// ModelDirs returns the directories models are looked up in, in order: the
+// system install, the user's data directory and the repo's dev directory.
+func ModelDirs() []string {
+	dirs := []string{"/usr/share/ekko/ggml"}
+	if dir, err := UserModelDir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	// fallback to dev path, relative to the repo root
+	return append(dirs, "assets/ggml")
+}
+
+// FindModel returns the path of the named model in the first of dirs that
+// has it.
+func FindModel(dirs []string, name string) (string, error) {
+	if !ValidModelName(name) {
+		return "", fmt.Errorf("invalid model name %q", name)
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, ModelFile(name))
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("model %s is not installed", name)
+}
@@ -0,0 +1,63 @@
+package whisper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// modelHeaderBytes encodes a ggml header with the given vocabulary, encoder
+// layers and file type.
+func modelHeaderBytes(vocabulary, audioLayers, fileType int32) []byte {
+	var buf bytes.Buffer
+	fields := []any{uint32(ggmlMagic), vocabulary, int32(1500), int32(384), int32(6), audioLayers,
+		int32(448), int32(384), int32(6), audioLayers, int32(80), fileType}
+	for _, field := range fields {
+		_ = binary.Write(&buf, binary.LittleEndian, field)
+	}
+	return buf.Bytes()
+}
+
+func TestReadModelHeader(t *testing.T) {
+	header, err := readModelHeader(bytes.NewReader(modelHeaderBytes(51864, 4, 2009)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header != (ModelHeader{Size: "tiny", Quantization: "q5_1"}) {
+		t.Fatalf("expected an English-only tiny q5_1 model, got %+v", header)
+	}
+
+	header, err = readModelHeader(bytes.NewReader(modelHeaderBytes(51866, 32, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header != (ModelHeader{Size: "large", Multilingual: true, Quantization: "f16"}) {
+		t.Fatalf("expected a multilingual large f16 model, got %+v", header)
+	}
+
+	if _, err := readModelHeader(bytes.NewReader([]byte("GGUF not a whisper model at all....."))); err == nil {
+		t.Fatal("expected a foreign file to be rejected")
+	}
+}
+
+func TestModelNameOf(t *testing.T) {
+	if name, ok := ModelNameOf(ModelFile("base.en-q5_1")); !ok || name != "base.en-q5_1" {
+		t.Fatalf("expected base.en-q5_1, got %q", name)
+	}
+	for _, file := range []string{"base.bin", "ggml-base.gguf", "ggml-.bin", "ggml-../x.bin"} {
+		if _, ok := ModelNameOf(file); ok {
+			t.Fatalf("expected %q not to name a model", file)
+		}
+	}
+}
+
+func TestIsTinydiarize(t *testing.T) {
+	if !IsTinydiarize("/models/ggml-small.en-tdrz.bin") {
+		t.Fatal("expected small.en-tdrz to be a tinydiarize model")
+	}
+	for _, path := range []string{"/models/ggml-small.en.bin", "/tdrz/ggml-base.bin", "/models/ggml-tdrzx.bin"} {
+		if IsTinydiarize(path) {
+			t.Fatalf("expected %q not to be a tinydiarize model", path)
+		}
+	}
+}
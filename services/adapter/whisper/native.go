@@ -0,0 +1,258 @@
+package whisper
+
+/*
+#cgo LDFLAGS: -lwhisper -lggml -lggml-base -lggml-cpu -lm -lstdc++
+#cgo linux LDFLAGS: -fopenmp
+#cgo darwin LDFLAGS: -lggml-metal -lggml-blas
+#cgo darwin LDFLAGS: -framework Accelerate -framework Metal -framework Foundation -framework CoreGraphics
+#include <stdint.h>
+#include <stdlib.h>
+#include <whisper.h>
+
+void ekko_set_abort(struct whisper_full_params *params, uintptr_t handle);
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/cgo"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// whisper.cpp is called directly rather than through the Go bindings: the
+// bindings always decode greedily, cannot set best-of, and share unguarded
+// callback maps between contexts, which breaks concurrent inference.
+
+// timestampUnit is the unit of whisper's segment and token timestamps.
+const timestampUnit = 10 * time.Millisecond
+
+// nativeModel is a loaded whisper.cpp context holding only the model weights.
+// Inference runs on a nativeState, so that concurrent inferences share the
+// weights.
+type nativeModel struct {
+	ctx *C.struct_whisper_context
+}
+
+// nativeState is a whisper.cpp inference state of a model: its key-value
+// caches, compute buffers and the results of its last inference. It runs one
+// inference at a time.
+type nativeState struct {
+	model *nativeModel
+	state *C.struct_whisper_state
+}
+
+// inferenceParams are the settings of one whisper_full call.
+type inferenceParams struct {
+	decode          DecodeOptions
+	threads         int
+	language        string
+	translate       bool
+	tokenTimestamps bool
+	prompt          string
+	// speakerTurns enables tinydiarize's speaker-turn detection.
+	speakerTurns bool
+}
+
+// loadModel reads the weights of the model file at path, without an
+// inference state.
+func loadModel(path string) (*nativeModel, error) {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	ctx := C.whisper_init_from_file_with_params_no_state(cPath, C.whisper_context_default_params())
+	if ctx == nil {
+		return nil, fmt.Errorf("load whisper model %s", path)
+	}
+	return &nativeModel{ctx: ctx}, nil
+}
+
+// close frees the model. It and its states must not be used afterwards.
+func (m *nativeModel) close() {
+	C.whisper_free(m.ctx)
+	m.ctx = nil
+}
+
+// newState allocates an inference state on the model.
+func (m *nativeModel) newState() (*nativeState, error) {
+	state := C.whisper_init_state(m.ctx)
+	if state == nil {
+		return nil, errors.New("allocate whisper inference state")
+	}
+	return &nativeState{model: m, state: state}, nil
+}
+
+// stateBytes estimates the memory of one inference state from its key-value
+// caches, which hold a 16-bit key and value per layer, embedding dimension and
+// text or audio position. Compute buffers come on top.
+func (m *nativeModel) stateBytes() int64 {
+	layers := int64(C.whisper_model_n_text_layer(m.ctx))
+	width := int64(C.whisper_model_n_text_state(m.ctx))
+	positions := int64(C.whisper_n_text_ctx(m.ctx)) + int64(C.whisper_n_audio_ctx(m.ctx))
+	return 2 * 2 * layers * width * positions
+}
+
+// close frees the state. It must not be used afterwards.
+func (s *nativeState) close() {
+	C.whisper_free_state(s.state)
+	s.state = nil
+}
+
+// isMultilingual reports whether the model supports languages other than English.
+func (m *nativeModel) isMultilingual() bool {
+	return C.whisper_is_multilingual(m.ctx) != 0
+}
+
+// languages returns every language code whisper knows.
+func languages() []string {
+	var codes []string
+	for id := range int(C.whisper_lang_max_id()) + 1 {
+		codes = append(codes, C.GoString(C.whisper_lang_str(C.int(id))))
+	}
+	return codes
+}
+
+// isLanguage reports whether code is a language whisper knows.
+func isLanguage(code string) bool {
+	cCode := C.CString(code)
+	defer C.free(unsafe.Pointer(cCode))
+
+	return C.whisper_lang_id(cCode) >= 0
+}
+
+// full runs the encoder and decoder over samples. The results stay in the
+// state until the next call. Inference stops early, returning ctx's error,
+// once ctx is done.
+func (s *nativeState) full(ctx context.Context, samples []float32, p inferenceParams) error {
+	strategy := C.enum_whisper_sampling_strategy(C.WHISPER_SAMPLING_GREEDY)
+	if p.decode.Strategy == StrategyBeam {
+		strategy = C.WHISPER_SAMPLING_BEAM_SEARCH
+	}
+
+	params := C.whisper_full_default_params(strategy)
+	params.n_threads = C.int(p.threads)
+	params.no_context = C.bool(true)
+	params.print_special = C.bool(false)
+	params.print_progress = C.bool(false)
+	params.print_realtime = C.bool(false)
+	params.print_timestamps = C.bool(false)
+	params.translate = C.bool(p.translate)
+	params.token_timestamps = C.bool(p.tokenTimestamps)
+	params.tdrz_enable = C.bool(p.speakerTurns)
+	params.temperature = C.float(p.decode.Temperature)
+	params.temperature_inc = C.float(p.decode.TemperatureIncrement)
+	params.audio_ctx = C.int(p.decode.AudioContext)
+	if p.decode.MaxSegmentLength > 0 {
+		params.max_len = C.int(p.decode.MaxSegmentLength)
+		params.split_on_word = C.bool(true)
+	}
+	if p.decode.BestOf > 0 {
+		params.greedy.best_of = C.int(p.decode.BestOf)
+	}
+	if p.decode.BeamSize > 0 {
+		params.beam_search.beam_size = C.int(p.decode.BeamSize)
+	}
+
+	language := C.CString(p.language)
+	defer C.free(unsafe.Pointer(language))
+	params.language = language
+
+	if p.prompt != "" {
+		prompt := C.CString(p.prompt)
+		defer C.free(unsafe.Pointer(prompt))
+		params.initial_prompt = prompt
+	}
+
+	handle := cgo.NewHandle(ctx)
+	defer handle.Delete()
+	C.ekko_set_abort(&params, C.uintptr_t(handle))
+
+	if C.whisper_full_with_state(s.model.ctx, s.state, params, (*C.float)(&samples[0]), C.int(len(samples))) != 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return errors.New("whisper inference failed")
+	}
+	return ctx.Err()
+}
+
+// ekkoAborted tells whisper.cpp whether the context behind handle is done. It
+// is called before encoding and between graph computations.
+//
+//export ekkoAborted
+func ekkoAborted(handle C.uintptr_t) C.bool {
+	ctx := cgo.Handle(handle).Value().(context.Context)
+	return C.bool(ctx.Err() != nil)
+}
+
+// detectedLanguage returns the language of the last inference.
+func (s *nativeState) detectedLanguage() string {
+	return C.GoString(C.whisper_lang_str(C.whisper_full_lang_id_from_state(s.state)))
+}
+
+// detectionProbability returns the probability of the most likely language on
+// the mel spectrogram of the last inference. It runs the encoder again, so it
+// is only used while the language is still being detected. Zero means unknown.
+func (s *nativeState) detectionProbability(threads int) float32 {
+	probabilities := make([]C.float, int(C.whisper_lang_max_id())+1)
+	if C.whisper_lang_auto_detect_with_state(s.model.ctx, s.state, 0, C.int(threads), &probabilities[0]) < 0 {
+		return 0
+	}
+
+	var best float32
+	for _, probability := range probabilities {
+		best = max(best, float32(probability))
+	}
+	return best
+}
+
+// segments returns the non-empty segments of the last inference with their
+// text tokens and no-speech probability. Token times are only read when they were requested. A turn
+// after a skipped empty segment moves to the segment before it.
+func (s *nativeState) segments(tokenTimestamps bool) []Segment {
+	eot := C.whisper_token_eot(s.model.ctx)
+
+	var segments []Segment
+	for i := range int(C.whisper_full_n_segments_from_state(s.state)) {
+		n := C.int(i)
+		text := strings.TrimSpace(C.GoString(C.whisper_full_get_segment_text_from_state(s.state, n)))
+		turn := bool(C.whisper_full_get_segment_speaker_turn_next_from_state(s.state, n))
+		if text == "" {
+			if turn && len(segments) > 0 {
+				segments[len(segments)-1].SpeakerTurnNext = true
+			}
+			continue
+		}
+
+		segment := Segment{
+			Start:               time.Duration(C.whisper_full_get_segment_t0_from_state(s.state, n)) * timestampUnit,
+			End:                 time.Duration(C.whisper_full_get_segment_t1_from_state(s.state, n)) * timestampUnit,
+			Text:                text,
+			NoSpeechProbability: float32(C.whisper_full_get_segment_no_speech_prob_from_state(s.state, n)),
+			SpeakerTurnNext:     turn,
+		}
+		for j := range int(C.whisper_full_n_tokens_from_state(s.state, n)) {
+			data := C.whisper_full_get_token_data_from_state(s.state, n, C.int(j))
+			// Special tokens (timestamps, start and end markers) sort after the end-of-text token.
+			if data.id >= eot {
+				continue
+			}
+			tokenText := C.GoString(C.whisper_full_get_token_text_from_state(s.model.ctx, s.state, n, C.int(j)))
+			if tokenText == "" {
+				continue
+			}
+
+			token := Token{Text: tokenText, Probability: float32(data.p)}
+			if tokenTimestamps {
+				token.Start = time.Duration(data.t0) * timestampUnit
+				token.End = time.Duration(data.t1) * timestampUnit
+			}
+			segment.Tokens = append(segment.Tokens, token)
+		}
+		segments = append(segments, segment)
+	}
+	return segments
+}
@@ -0,0 +1,22 @@
+#include <stdint.h>
+#include <whisper.h>
+
+#include "_cgo_export.h"
+
+// The callbacks receive a cgo.Handle of the call's context.Context and tell
+// whisper.cpp to stop once it is done.
+
+static bool ekko_encoder_begin(struct whisper_context *ctx, struct whisper_state *state, void *user_data) {
+    return !ekkoAborted((uintptr_t)user_data);
+}
+
+static bool ekko_abort(void *user_data) {
+    return ekkoAborted((uintptr_t)user_data);
+}
+
+void ekko_set_abort(struct whisper_full_params *params, uintptr_t handle) {
+    params->encoder_begin_callback = ekko_encoder_begin;
+    params->encoder_begin_callback_user_data = (void *)handle;
+    params->abort_callback = ekko_abort;
+    params->abort_callback_user_data = (void *)handle;
+}
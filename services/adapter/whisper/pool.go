@@ -0,0 +1,118 @@
+package whisper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// DefaultPoolSize is the number of contexts kept when neither ScriberOptions
+// nor EKKO_POOL_SIZE sets one.
+const DefaultPoolSize = 1
+
+// pooledContext is one inference state of the pool's model, ready for
+// inference.
+type pooledContext struct {
+	state *nativeState
+	// threads is the number of CPU threads the context runs inference on.
+	threads int
+}
+
+// contextPool hands out pre-initialized contexts, one caller at a time each.
+type contextPool struct {
+	// model holds the weights every context shares; nil in tests.
+	model *nativeModel
+	idle  chan *pooledContext
+	all   []*pooledContext
+	// closing is closed once the pool starts closing, failing every acquire.
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+// newContextPool loads the model at path once, with size inference states on
+// it. The CPU threads are split between them so that concurrent inference does
+// not oversubscribe.
+func newContextPool(path string, size int) (*contextPool, error) {
+	if size < 1 {
+		return nil, errors.New("whisper context pool size must be positive")
+	}
+
+	model, err := loadModel(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := &contextPool{model: model, idle: make(chan *pooledContext, size), closing: make(chan struct{})}
+	threads := max(1, runtime.NumCPU()/size)
+	for range size {
+		state, err := model.newState()
+		if err != nil {
+			pool.close()
+			return nil, err
+		}
+
+		entry := &pooledContext{state: state, threads: threads}
+		pool.all = append(pool.all, entry)
+		pool.idle <- entry
+	}
+	return pool, nil
+}
+
+// acquire waits for an idle context or for ctx to end. It fails with
+// ErrClosed once the pool is closing.
+func (p *contextPool) acquire(ctx context.Context) (*pooledContext, error) {
+	select {
+	case entry := <-p.idle:
+		select {
+		case <-p.closing:
+			p.idle <- entry
+			return nil, ErrClosed
+		default:
+			return entry, nil
+		}
+	case <-p.closing:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// release returns a context to the pool.
+func (p *contextPool) release(entry *pooledContext) {
+	p.idle <- entry
+}
+
+// size returns the number of contexts in the pool.
+func (p *contextPool) size() int {
+	return len(p.all)
+}
+
+// close fails every waiting and later acquire, waits for the contexts in use
+// to be released and frees every state, then the model. Closing again does
+// nothing.
+func (p *contextPool) close() {
+	p.closeOnce.Do(func() {
+		close(p.closing)
+		for _, entry := range p.all {
+			<-p.idle
+			if entry.state != nil {
+				entry.state.close()
+			}
+		}
+		p.all = nil
+		if p.model != nil {
+			p.model.close()
+		}
+	})
+}
+
+// poolSizeFromEnv reads EKKO_POOL_SIZE, falling back to DefaultPoolSize.
+func poolSizeFromEnv() int {
+	size, err := strconv.Atoi(os.Getenv("EKKO_POOL_SIZE"))
+	if err != nil || size < 1 {
+		return DefaultPoolSize
+	}
+	return size
+}
@@ -1,115 +1,192 @@
 package whisper
 
 import (
-	"io"
+	"context"
+	"errors"
+	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
-	"sync"
-	"time"
 
-	"github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+	"github.com/tuanta7/ekko/services/adapter/speech"
 )
 
-const defaultModel = "tiny.en-q5_1"
+// Scriber transcribes audio with a pool of whisper contexts. Up to PoolSize
+// calls to Transcribe run concurrently; further calls wait for a free context.
+type Scriber struct {
+	pool *contextPool
+	// poolSize is the number of contexts loaded.
+	poolSize int
+	// modelBytes is the size of the model file.
+	modelBytes int64
+	// stateBytes estimates the memory of one context's inference state.
+	stateBytes int64
+	// multilingual is set for models that support languages other than
+	// English. It is read at load, so that it is known once the pool is closed.
+	multilingual bool
+	// speakerTurns is set for tinydiarize models, whose segments mark speaker
+	// turns.
+	speakerTurns bool
+}
 
-func modelPath() string {
-	name := os.Getenv("EKKO_MODEL")
-	if name == "" {
-		name = defaultModel
-	}
+// ScriberOptions configures NewScriber. Zero values fall back to the environment.
+type ScriberOptions struct {
+	// ModelPath is the model file. Defaults to the model named by EKKO_MODEL,
+	// looked up in ModelDirs.
+	ModelPath string
+	// PoolSize is the number of contexts, and so of concurrent transcriptions.
+	// The contexts share the model's weights, loaded once. Defaults to
+	// EKKO_POOL_SIZE, or DefaultPoolSize when that is unset.
+	PoolSize int
+}
 
-	file := "ggml-" + name + ".bin"
-	p := filepath.Join("/usr/share/ekko/ggml", file)
-	if _, err := os.Stat(p); err == nil {
-		return p
+func NewScriber(options ScriberOptions) (*Scriber, error) {
+	path := options.ModelPath
+	if path == "" {
+		var err error
+		if path, err = FindModel(ModelDirs(), ModelName()); err != nil {
+			return nil, err
+		}
 	}
-
-	// fallback to dev path, relative to the repo root
-	return filepath.Join("assets/ggml", file)
+	return newScriber(path, options)
 }
 
-type Scriber struct {
-	mu    sync.Mutex
-	model whisper.Model
-}
+// newScriber loads the pool from an explicit model path.
+func newScriber(path string, options ScriberOptions) (*Scriber, error) {
+	size := options.PoolSize
+	if size == 0 {
+		size = poolSizeFromEnv()
+	}
 
-func NewScriber() (*Scriber, error) {
-	model, err := whisper.New(modelPath())
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := newContextPool(path, size)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Scriber{
-		model: model,
+		pool:         pool,
+		poolSize:     pool.size(),
+		modelBytes:   info.Size(),
+		stateBytes:   pool.model.stateBytes(),
+		multilingual: pool.model.isMultilingual(),
+		speakerTurns: IsTinydiarize(path),
 	}, nil
 }
 
-func (s *Scriber) Close() error {
-	return s.model.Close()
+// PoolSize returns the number of transcriptions that can run at once.
+func (s *Scriber) PoolSize() int {
+	return s.poolSize
 }
 
-type TranscribeOptions struct {
-	TokenTimestamps bool
+// MemoryBytes estimates the memory the scriber's model takes: the weights,
+// loaded once and taking about as much memory as the model file, plus the
+// key-value caches of every context's inference state. Compute buffers come on
+// top.
+func (s *Scriber) MemoryBytes() int64 {
+	return s.modelBytes + s.stateBytes*int64(s.poolSize)
 }
 
-type Segment struct {
-	Start time.Duration `json:"start"`
-	End   time.Duration `json:"end"`
-	Text  string        `json:"text"`
+// Close waits for in-flight transcriptions and frees every context. Calls to
+// Transcribe waiting for a context, and any made afterwards, fail with
+// ErrClosed.
+func (s *Scriber) Close() error {
+	s.pool.close()
+	return nil
 }
 
-func (s *Scriber) Transcribe(samples []float32, options TranscribeOptions) ([]Segment, error) {
-	if len(samples) == 0 {
-		return nil, nil
+var (
+	// ErrModelNotMultilingual is returned when an English-only model is asked to translate.
+	ErrModelNotMultilingual = errors.New("model is not multilingual")
+	// ErrUnsupportedLanguage is returned for a language code whisper does not know.
+	ErrUnsupportedLanguage = errors.New("unsupported language")
+	// ErrClosed is returned by Transcribe once the Scriber is closed.
+	ErrClosed = errors.New("whisper scriber is closed")
+)
+
+// Capabilities reports what the loaded model supports.
+func (s *Scriber) Capabilities() Capabilities {
+	multilingual := s.IsMultilingual()
+	return Capabilities{
+		Languages:      s.Languages(),
+		Multilingual:   multilingual,
+		Translate:      multilingual,
+		WordTimestamps: true,
+		Concurrency:    s.PoolSize(),
+		SpeakerTurns:   s.speakerTurns,
 	}
+}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// IsMultilingual reports whether the model supports languages other than English.
+func (s *Scriber) IsMultilingual() bool {
+	return s.multilingual
+}
 
-	ctx, err := s.model.NewContext()
-	if err != nil {
-		return nil, err
+// Languages returns the language codes the model can transcribe.
+func (s *Scriber) Languages() []string {
+	if !s.IsMultilingual() {
+		return []string{"en"}
 	}
+	return languages()
+}
 
-	ctx.SetTokenTimestamps(options.TokenTimestamps)
+// Transcribe runs inference on 16 kHz mono samples. It waits for a free
+// context, reporting to speech.Started once it has one, and aborts inference
+// once ctx is done, returning ctx's error. It fails with ErrClosed once the
+// Scriber is closed.
+func (s *Scriber) Transcribe(ctx context.Context, samples []float32, options TranscribeOptions) (Result, error) {
+	if len(samples) == 0 {
+		return Result{}, nil
+	}
+	if err := options.Decode.Validate(); err != nil {
+		return Result{}, err
+	}
 
-	if err := ctx.Process(samples, nil, nil, nil); err != nil {
-		return nil, err
+	entry, err := s.pool.acquire(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer s.pool.release(entry)
+	speech.Started(ctx)
+
+	params := inferenceParams{
+		decode:          options.Decode,
+		threads:         entry.threads,
+		language:        "en",
+		translate:       options.Translate,
+		tokenTimestamps: options.TokenTimestamps,
+		prompt:          options.InitialPrompt,
+		speakerTurns:    s.speakerTurns,
+	}
+	if options.Decode.Threads > 0 {
+		params.threads = options.Decode.Threads
 	}
 
-	var segments []Segment
-	for {
-		segment, err := ctx.NextSegment()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return nil, err
+	detect := false
+	if s.multilingual {
+		params.language = options.Language
+		if params.language == "" {
+			params.language = AutoLanguage
 		}
-
-		text := strings.TrimSpace(segment.Text)
-		if text == "" {
-			continue
+		detect = params.language == AutoLanguage
+		if !detect && !isLanguage(params.language) {
+			return Result{}, fmt.Errorf("language %q: %w", params.language, ErrUnsupportedLanguage)
 		}
-
-		segments = append(segments, Segment{
-			Start: segment.Start,
-			End:   segment.End,
-			Text:  text,
-		})
+	} else if options.Translate {
+		return Result{}, ErrModelNotMultilingual
 	}
 
-	return segments, nil
-}
+	if err := entry.state.full(ctx, samples, params); err != nil {
+		return Result{}, err
+	}
 
-func CombineSegments(segments []Segment) string {
-	var parts []string
-	for _, segment := range segments {
-		text := strings.TrimSpace(segment.Text)
-		if text != "" {
-			parts = append(parts, text)
-		}
+	result := Result{Language: params.language}
+	if detect {
+		result.Language = entry.state.detectedLanguage()
+		result.LanguageProbability = entry.state.detectionProbability(params.threads)
 	}
-	return strings.Join(parts, " ")
+
+	result.Segments = entry.state.segments(options.TokenTimestamps)
+	return result, nil
 }
@@ -0,0 +1,164 @@
+package whisper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+func TestContextPoolLimitsConcurrentUse(t *testing.T) {
+	entry := &pooledContext{}
+	pool := &contextPool{idle: make(chan *pooledContext, 1), all: []*pooledContext{entry}}
+	pool.idle <- entry
+
+	acquired, err := pool.acquire(context.Background())
+	if err != nil || acquired != entry {
+		t.Fatalf("expected the idle context, got %v, %v", acquired, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pool.acquire(ctx); err == nil {
+		t.Fatal("expected acquire to fail while every context is in use")
+	}
+
+	pool.release(acquired)
+	if acquired, err := pool.acquire(context.Background()); err != nil || acquired != entry {
+		t.Fatalf("expected the released context, got %v, %v", acquired, err)
+	}
+}
+
+func TestScriberCountsModelWeightsOnce(t *testing.T) {
+	scriber := &Scriber{poolSize: 3, modelBytes: 75 << 20, stateBytes: 10 << 20}
+	if bytes := scriber.MemoryBytes(); bytes != 105<<20 {
+		t.Fatalf("expected the weights once plus three states, got %d bytes", bytes)
+	}
+}
+
+func TestScriberFailsOnceClosed(t *testing.T) {
+	scriber := &Scriber{
+		pool:         &contextPool{idle: make(chan *pooledContext), closing: make(chan struct{})},
+		multilingual: true,
+	}
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := scriber.Transcribe(context.Background(), make([]float32, 160), TranscribeOptions{})
+		waiting <- err
+	}()
+
+	if err := scriber.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-waiting; !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected a waiting call to fail with ErrClosed, got %v", err)
+	}
+	if _, err := scriber.Transcribe(context.Background(), make([]float32, 160), TranscribeOptions{}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed after Close, got %v", err)
+	}
+	if capabilities := scriber.Capabilities(); !capabilities.Multilingual || !capabilities.Translate {
+		t.Fatalf("expected the capabilities read at load, got %+v", capabilities)
+	}
+	if err := scriber.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The benchmarks need a real model. They use EKKO_BENCH_MODEL, or the default
+// model downloaded by `make download-model`, and are skipped otherwise:
+//
+//	go test -run '^$' -bench Transcribe -benchtime 20x ./services/adapter/whisper
+
+// BenchmarkTranscribeNewContextPerChunk measures the previous behavior: every
+// chunk takes one shared mutex and builds a fresh context.
+func BenchmarkTranscribeNewContextPerChunk(b *testing.B) {
+	path := benchmarkModelPath(b)
+	model, err := whisper.New(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer model.Close()
+
+	var mu sync.Mutex
+	samples := benchmarkSamples()
+	transcribe := func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		ctx, err := model.NewContext()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := ctx.Process(samples, nil, nil, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("sequential", func(b *testing.B) {
+		for range b.N {
+			transcribe()
+		}
+	})
+	b.Run("concurrent", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				transcribe()
+			}
+		})
+	})
+}
+
+// BenchmarkTranscribePooled measures Transcribe with reused contexts, one at a
+// time and with as many callers as there are contexts.
+func BenchmarkTranscribePooled(b *testing.B) {
+	path := benchmarkModelPath(b)
+	for _, size := range []int{1, 2} {
+		scriber, err := newScriber(path, ScriberOptions{PoolSize: size})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		samples := benchmarkSamples()
+		b.Run(fmt.Sprintf("pool=%d", size), func(b *testing.B) {
+			// Callers beyond the pool size wait for a context, so ns/op is the
+			// effective per-chunk latency at full load.
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := scriber.Transcribe(context.Background(), samples, TranscribeOptions{}); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+
+		_ = scriber.Close()
+	}
+}
+
+// benchmarkModelPath returns the model used by the benchmarks or skips them.
+func benchmarkModelPath(b *testing.B) string {
+	path := os.Getenv("EKKO_BENCH_MODEL")
+	if path == "" {
+		path = filepath.Join("..", "..", "..", "assets", "ggml", ModelFile(defaultModel))
+	}
+	if _, err := os.Stat(path); err != nil {
+		b.Skipf("model not available: %v", err)
+	}
+	return path
+}
+
+// benchmarkSamples returns a three-second chunk, about the size of a partial.
+func benchmarkSamples() []float32 {
+	samples := make([]float32, 3*16000)
+	for i := range samples {
+		samples[i] = float32(0.1 * math.Sin(2*math.Pi*220*float64(i)/16000))
+	}
+	return samples
+}
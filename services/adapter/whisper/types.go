@@ -0,0 +1,38 @@
+package whisper
+
+import "github.com/tuanta7/ekko/services/adapter/speech"
+
+// The transcription types live in the pure-Go speech package, which backends
+// that do not link whisper.cpp share; they are aliased here for the Scriber's
+// callers.
+type (
+	TranscribeOptions = speech.TranscribeOptions
+	DecodeOptions     = speech.DecodeOptions
+	Strategy          = speech.Strategy
+	Result            = speech.Result
+	Segment           = speech.Segment
+	Token             = speech.Token
+	Word              = speech.Word
+	Capabilities      = speech.Capabilities
+)
+
+const (
+	AutoLanguage   = speech.AutoLanguage
+	StrategyGreedy = speech.StrategyGreedy
+	StrategyBeam   = speech.StrategyBeam
+)
+
+// CombineSegments joins the text of segments, skipping empty ones.
+func CombineSegments(segments []Segment) string {
+	return speech.CombineSegments(segments)
+}
+
+// DefaultPartialDecodeOptions returns speech.DefaultPartialDecodeOptions.
+func DefaultPartialDecodeOptions() DecodeOptions {
+	return speech.DefaultPartialDecodeOptions()
+}
+
+// DefaultFinalDecodeOptions returns speech.DefaultFinalDecodeOptions.
+func DefaultFinalDecodeOptions() DecodeOptions {
+	return speech.DefaultFinalDecodeOptions()
+}
@@ -14,12 +14,44 @@ type AudioChunk struct {
 	End time.Duration
 	// Final reports whether the chunk completes an utterance.
 	Final bool
+	// UtteranceID identifies the utterance the chunk belongs to. Partial and final
+	// chunks of one utterance share it, and it increases with every new utterance.
+	UtteranceID int64
+	// Revision orders the chunks of one utterance, starting at 1. A later revision
+	// replaces every earlier one, and the final chunk always has the highest.
+	Revision int
+	// Overlap is the leading audio already included in the previous final chunk,
+	// carried over when an utterance was split at its maximum duration.
+	Overlap time.Duration
+	// Annotation is set on final chunks that mark a music, noise or silence
+	// stretch instead of carrying audio. Such chunks have no samples.
+	Annotation SoundClass
+
+	// buffer is the pooled storage behind Samples, returned by Release.
+	buffer *[]float32
+}
+
+// Release returns the chunk's sample buffer to the chunker's pool once the
+// samples are no longer needed, typically right after transcription. Samples
+// must not be used afterwards, and Release must be called at most once per
+// emitted chunk, including across copies of it. Chunks that are never released
+// are simply garbage collected.
+func (a *AudioChunk) Release() {
+	if a.buffer == nil {
+		return
+	}
+
+	putChunkBuffer(a.buffer)
+	a.buffer = nil
+	a.Samples = nil
 }
 
 // AudioChunker groups incoming audio frames into partial and final speech chunks.
 type AudioChunker struct {
 	// Config defines the speech detection and chunk emission behavior.
 	Config Config
+	// Trace, when set, records every frame decision and emitted chunk.
+	Trace *Trace
 
 	// sampleCursor is the total number of input samples processed so far.
 	sampleCursor int64
@@ -27,16 +59,44 @@ type AudioChunker struct {
 	inSpeech bool
 	// speechStart is the absolute sample offset where the current buffer begins.
 	speechStart int64
-	// speechSamples contains the current utterance, including retained padding.
-	speechSamples []float32
-	// preRollSamples contains recent idle audio to prepend when speech begins.
-	preRollSamples []float32
+	// audio retains the most recent input, including the current utterance and pre-roll.
+	audio *ringBuffer
+	// preRollSamples is the length of recent idle audio to prepend when speech begins.
+	preRollSamples int
 	// silenceSamples is the current run of non-speech samples in the utterance.
 	silenceSamples int
 	// activeSpeechSamples is the total number of samples classified as speech.
 	activeSpeechSamples int
 	// lastPartialAt is the buffer length when the previous partial chunk was emitted.
 	lastPartialAt int
+	// utteranceID is the identifier of the current or most recent utterance.
+	utteranceID int64
+	// revision is the number of chunks emitted for the current utterance.
+	revision int
+	// sharedSamples is the number of leading buffered samples that were already
+	// part of the previous final chunk.
+	sharedSamples int
+	// classifier labels energetic frames as speech, music or noise.
+	classifier *soundClassifier
+	// musicSamples is the number of energetic samples in the utterance labeled music.
+	musicSamples int
+	// noiseSamples is the number of energetic samples in the utterance labeled noise.
+	noiseSamples int
+	// annotation is the open music or noise stretch that suppressed utterances extend.
+	annotation *annotationStretch
+	// readyAnnotations holds closed stretches waiting to be returned.
+	readyAnnotations []AudioChunk
+	// lastActivityEnd is the absolute end of the last final chunk or suppressed utterance.
+	lastActivityEnd int64
+}
+
+// annotationStretch is a run of suppressed utterances of the same sound class.
+type annotationStretch struct {
+	class       SoundClass
+	start       int64
+	end         int64
+	utteranceID int64
+	revision    int
 }
 
 func NewAudioChunker() *AudioChunker {
@@ -51,57 +111,111 @@ func (c *AudioChunker) AddFrame(samples []float32) []AudioChunk {
 		return nil
 	}
 
+	c.reserve(len(samples))
 	frameStart := c.sampleCursor
 	c.sampleCursor += int64(len(samples))
+	c.audio.write(samples)
 
-	if isSpeech(samples, c.Config.energyThreshold) {
-		return c.addSpeechFrame(samples, frameStart)
+	level := rms(samples)
+	energetic := level >= c.Config.energyThreshold
+	class := c.classify(samples, energetic)
+
+	var chunks []AudioChunk
+	if energetic {
+		chunks = c.addSpeechFrame(len(samples), frameStart, class)
+	} else {
+		chunks = c.addSilenceFrame(len(samples))
 	}
+	chunks = c.takeAnnotations(chunks)
 
-	return c.addSilenceFrame(samples)
+	if c.Trace != nil {
+		c.Trace.recordFrame(c, frameStart, len(samples), level, class)
+		c.Trace.recordChunks(chunks)
+	}
+	return chunks
 }
 
-// Flush finalizes and returns any pending utterance that contains enough speech.
+// Flush finalizes and returns any pending utterance that contains enough speech,
+// followed by the annotations of any open music, noise or silence stretch.
 func (c *AudioChunker) Flush() []AudioChunk {
-	if !c.inSpeech {
-		return nil
+	var chunks []AudioChunk
+	if c.inSpeech {
+		chunk, ok := c.finalChunk(0)
+		c.resetAfterFinal(0)
+		if ok {
+			chunks = c.emitSpeech(chunks, chunk)
+		}
 	}
 
-	chunk, ok := c.finalChunk(0)
-	c.resetAfterFinal(nil)
-	if !ok {
-		return nil
+	c.closeAnnotation()
+	chunks = c.takeAnnotations(chunks)
+	chunks = c.annotateSilence(chunks, c.sampleCursor)
+
+	if c.Trace != nil {
+		c.Trace.recordChunks(chunks)
 	}
-	return []AudioChunk{chunk}
+	return chunks
+}
+
+// classify labels an energetic frame, or returns SoundSilence for a quiet one.
+func (c *AudioChunker) classify(samples []float32, energetic bool) SoundClass {
+	if !c.Config.classifySound {
+		if energetic {
+			return SoundSpeech
+		}
+		return SoundSilence
+	}
+
+	if c.classifier == nil {
+		c.classifier = newSoundClassifier(c.Config.sampleRate)
+	}
+	c.classifier.observe(samples, energetic)
+	if !energetic {
+		return SoundSilence
+	}
+	return c.classifier.classify()
 }
 
 // addSpeechFrame adds a detected speech frame and emits chunks when their limits are reached.
-func (c *AudioChunker) addSpeechFrame(samples []float32, frameStart int64) []AudioChunk {
+func (c *AudioChunker) addSpeechFrame(frameSamples int, frameStart int64, class SoundClass) []AudioChunk {
+	var chunks []AudioChunk
 	if !c.inSpeech {
+		chunks = c.annotateSilence(chunks, frameStart-int64(c.preRollSamples))
 		c.inSpeech = true
-		c.speechStart = frameStart - int64(len(c.preRollSamples))
-		c.speechSamples = append([]float32(nil), c.preRollSamples...)
+		c.speechStart = frameStart - int64(c.preRollSamples)
 		c.silenceSamples = 0
 		c.activeSpeechSamples = 0
 		c.lastPartialAt = 0
+		c.musicSamples = 0
+		c.noiseSamples = 0
+		c.utteranceID++
+		c.revision = 0
 	}
 
-	c.speechSamples = append(c.speechSamples, samples...)
-	c.activeSpeechSamples += len(samples)
+	c.activeSpeechSamples += frameSamples
+	switch class {
+	case SoundMusic:
+		c.musicSamples += frameSamples
+	case SoundNoise:
+		c.noiseSamples += frameSamples
+	}
 	c.silenceSamples = 0
 
-	var chunks []AudioChunk
 	if c.shouldEmitPartial() {
-		chunks = append(chunks, c.partialChunk())
-		c.lastPartialAt = len(c.speechSamples)
+		chunks = c.emitSpeech(chunks, c.partialChunk())
+		c.lastPartialAt = c.speechLength()
 	}
 
-	if samplesDuration(len(c.speechSamples), c.Config.sampleRate) >= c.Config.maxFinalDuration {
+	if samplesDuration(c.speechLength(), c.Config.sampleRate) >= c.Config.maxFinalDuration {
 		chunk, ok := c.finalChunk(0)
-		tail := tailSamples(chunk.Samples, samplesForDuration(c.Config.overlap, c.Config.sampleRate))
-		c.resetAfterFinal(tail)
+		overlap := 0
 		if ok {
-			chunks = append(chunks, chunk)
+			overlap = min(samplesForDuration(c.Config.overlap, c.Config.sampleRate), len(chunk.Samples))
+		}
+		c.resetAfterFinal(overlap)
+		if ok {
+			c.sharedSamples = overlap
+			chunks = c.emitSpeech(chunks, chunk)
 		}
 	}
 
@@ -109,14 +223,13 @@ func (c *AudioChunker) addSpeechFrame(samples []float32, frameStart int64) []Aud
 }
 
 // addSilenceFrame retains padding and finalizes an utterance after sustained silence.
-func (c *AudioChunker) addSilenceFrame(samples []float32) []AudioChunk {
+func (c *AudioChunker) addSilenceFrame(frameSamples int) []AudioChunk {
 	if !c.inSpeech {
-		c.appendPreRoll(samples)
+		c.appendPreRoll(frameSamples)
 		return nil
 	}
 
-	c.speechSamples = append(c.speechSamples, samples...)
-	c.silenceSamples += len(samples)
+	c.silenceSamples += frameSamples
 
 	if samplesDuration(c.silenceSamples, c.Config.sampleRate) < c.Config.silenceToFinal {
 		return nil
@@ -128,18 +241,17 @@ func (c *AudioChunker) addSilenceFrame(samples []float32) []AudioChunk {
 	}
 
 	chunk, ok := c.finalChunk(trailingSilenceToDrop)
-	tail := tailSamples(c.speechSamples, samplesForDuration(c.Config.speechPad, c.Config.sampleRate))
-	c.resetAfterFinal(tail)
+	c.resetAfterFinal(min(samplesForDuration(c.Config.speechPad, c.Config.sampleRate), c.speechLength()))
 	if !ok {
 		return nil
 	}
 
-	return []AudioChunk{chunk}
+	return c.emitSpeech(nil, chunk)
 }
 
 // shouldEmitPartial reports whether enough speech and new audio exist for a partial chunk.
 func (c *AudioChunker) shouldEmitPartial() bool {
-	if len(c.speechSamples) == 0 {
+	if c.speechLength() == 0 {
 		return false
 	}
 
@@ -147,32 +259,43 @@ func (c *AudioChunker) shouldEmitPartial() bool {
 		return false
 	}
 
-	return samplesDuration(len(c.speechSamples)-c.lastPartialAt, c.Config.sampleRate) >= c.Config.partialInterval
+	if c.utteranceClass() != SoundSpeech {
+		return false
+	}
+
+	return samplesDuration(c.speechLength()-c.lastPartialAt, c.Config.sampleRate) >= c.Config.partialInterval
 }
 
 // partialChunk copies the most recent configured window into a non-final chunk.
 func (c *AudioChunker) partialChunk() AudioChunk {
 	windowSamples := samplesForDuration(c.Config.partialWindow, c.Config.sampleRate)
 	startOffset := 0
-	if len(c.speechSamples) > windowSamples {
-		startOffset = len(c.speechSamples) - windowSamples
+	if c.speechLength() > windowSamples {
+		startOffset = c.speechLength() - windowSamples
 	}
 
-	samples := append([]float32(nil), c.speechSamples[startOffset:]...)
 	startSample := c.speechStart + int64(startOffset)
-	endSample := startSample + int64(len(samples))
+	endSample := c.sampleCursor
+	buffer := c.copyChunk(startSample, endSample)
+	sharedSamples := max(c.sharedSamples-startOffset, 0)
+	c.revision++
 
 	return AudioChunk{
-		Samples: samples,
-		Start:   samplesDuration(startSample, c.Config.sampleRate),
-		End:     samplesDuration(endSample, c.Config.sampleRate),
-		Final:   false,
+		Samples:     *buffer,
+		Start:       samplesDuration(startSample, c.Config.sampleRate),
+		End:         samplesDuration(endSample, c.Config.sampleRate),
+		Final:       false,
+		UtteranceID: c.utteranceID,
+		Revision:    c.revision,
+		Overlap:     samplesDuration(sharedSamples, c.Config.sampleRate),
+		buffer:      buffer,
 	}
 }
 
-// finalChunk builds a final chunk after removing the requested number of trailing samples.
+// finalChunk builds a final chunk after removing the requested number of trailing
+// samples. A music or noise utterance extends the open annotation instead.
 func (c *AudioChunker) finalChunk(dropTailSamples int) (AudioChunk, bool) {
-	end := len(c.speechSamples) - dropTailSamples
+	end := c.speechLength() - dropTailSamples
 	if end < 0 {
 		end = 0
 	}
@@ -181,54 +304,195 @@ func (c *AudioChunker) finalChunk(dropTailSamples int) (AudioChunk, bool) {
 		return AudioChunk{}, false
 	}
 
-	samples := append([]float32(nil), c.speechSamples[:end]...)
-	endSample := c.speechStart + int64(len(samples))
+	endSample := c.speechStart + int64(end)
+	c.lastActivityEnd = endSample
+	if class := c.utteranceClass(); class != SoundSpeech {
+		c.extendAnnotation(class, c.speechStart, endSample)
+		return AudioChunk{}, false
+	}
+
+	buffer := c.copyChunk(c.speechStart, endSample)
+	c.revision++
 
 	return AudioChunk{
-		Samples: samples,
-		Start:   samplesDuration(c.speechStart, c.Config.sampleRate),
-		End:     samplesDuration(endSample, c.Config.sampleRate),
-		Final:   true,
+		Samples:     *buffer,
+		Start:       samplesDuration(c.speechStart, c.Config.sampleRate),
+		End:         samplesDuration(endSample, c.Config.sampleRate),
+		Final:       true,
+		UtteranceID: c.utteranceID,
+		Revision:    c.revision,
+		Overlap:     samplesDuration(min(c.sharedSamples, end), c.Config.sampleRate),
+		buffer:      buffer,
 	}, true
 }
 
-// resetAfterFinal clears the utterance state and preserves the supplied audio as pre-roll.
-func (c *AudioChunker) resetAfterFinal(preRoll []float32) {
+// resetAfterFinal clears the utterance state and keeps the newest preRoll samples
+// of the stream as pre-roll.
+func (c *AudioChunker) resetAfterFinal(preRoll int) {
 	c.inSpeech = false
 	c.speechStart = 0
-	c.speechSamples = nil
 	c.silenceSamples = 0
 	c.activeSpeechSamples = 0
 	c.lastPartialAt = 0
-	c.preRollSamples = append([]float32(nil), preRoll...)
+	c.sharedSamples = 0
+	c.musicSamples = 0
+	c.noiseSamples = 0
+	c.preRollSamples = preRoll
+}
+
+// utteranceClass labels the open utterance by the majority class of its energetic frames.
+func (c *AudioChunker) utteranceClass() SoundClass {
+	nonSpeech := c.musicSamples + c.noiseSamples
+	if 2*nonSpeech <= c.activeSpeechSamples {
+		return SoundSpeech
+	}
+	if c.musicSamples >= c.noiseSamples {
+		return SoundMusic
+	}
+	return SoundNoise
+}
+
+// emitSpeech appends a speech chunk, preceded by the annotation of any music or
+// noise stretch that the speech ends.
+func (c *AudioChunker) emitSpeech(chunks []AudioChunk, chunk AudioChunk) []AudioChunk {
+	c.closeAnnotation()
+	chunks = c.takeAnnotations(chunks)
+	return append(chunks, chunk)
+}
+
+// extendAnnotation adds a suppressed utterance to the open stretch, closing the
+// stretch first if the class changed or the gap would itself be annotated as silence.
+func (c *AudioChunker) extendAnnotation(class SoundClass, start, end int64) {
+	maxGap := int64(samplesForDuration(c.Config.minSilenceAnnotation, c.Config.sampleRate))
+	if c.annotation != nil && (c.annotation.class != class || start-c.annotation.end >= maxGap) {
+		c.closeAnnotation()
+	}
+
+	if c.annotation == nil {
+		c.annotation = &annotationStretch{class: class, start: start}
+	}
+	c.annotation.end = end
+	c.annotation.utteranceID = c.utteranceID
+	c.annotation.revision = c.revision + 1
+}
+
+// closeAnnotation ends the open stretch, queueing it if it is long enough to report.
+// It takes the ID of its last utterance so that it replaces that utterance's partials.
+func (c *AudioChunker) closeAnnotation() {
+	stretch := c.annotation
+	if stretch == nil {
+		return
+	}
+
+	c.annotation = nil
+	if samplesDuration(stretch.end-stretch.start, c.Config.sampleRate) < c.Config.minNonSpeechAnnotation {
+		return
+	}
+
+	chunk := c.annotationChunk(stretch.class, stretch.start, stretch.end)
+	chunk.UtteranceID = stretch.utteranceID
+	chunk.Revision = stretch.revision
+	c.readyAnnotations = append(c.readyAnnotations, chunk)
+}
+
+// takeAnnotations appends and clears the queued annotations.
+func (c *AudioChunker) takeAnnotations(chunks []AudioChunk) []AudioChunk {
+	if len(c.readyAnnotations) == 0 {
+		return chunks
+	}
+
+	chunks = append(chunks, c.readyAnnotations...)
+	c.readyAnnotations = c.readyAnnotations[:0]
+	return chunks
+}
+
+// annotateSilence reports the quiet stretch since the last activity when it
+// reaches minSilenceAnnotation. A silence annotation gets its own utterance ID.
+func (c *AudioChunker) annotateSilence(chunks []AudioChunk, end int64) []AudioChunk {
+	minSamples := int64(samplesForDuration(c.Config.minSilenceAnnotation, c.Config.sampleRate))
+	if end-c.lastActivityEnd < minSamples {
+		return chunks
+	}
+
+	c.closeAnnotation()
+	chunks = c.takeAnnotations(chunks)
+
+	c.utteranceID++
+	chunk := c.annotationChunk(SoundSilence, c.lastActivityEnd, end)
+	chunk.UtteranceID = c.utteranceID
+	chunk.Revision = 1
+	c.lastActivityEnd = end
+	return append(chunks, chunk)
 }
 
-// appendPreRoll adds idle audio to the rolling pre-speech buffer.
-func (c *AudioChunker) appendPreRoll(samples []float32) {
-	c.preRollSamples = append(c.preRollSamples, samples...)
+// annotationChunk builds a sample-free final chunk marking a non-speech stretch.
+func (c *AudioChunker) annotationChunk(class SoundClass, start, end int64) AudioChunk {
+	return AudioChunk{
+		Start:      samplesDuration(start, c.Config.sampleRate),
+		End:        samplesDuration(end, c.Config.sampleRate),
+		Final:      true,
+		Annotation: class,
+	}
+}
+
+// appendPreRoll extends the rolling pre-speech region by newly written idle audio.
+func (c *AudioChunker) appendPreRoll(frameSamples int) {
+	c.preRollSamples += frameSamples
 	c.capPreRoll()
 }
 
 // capPreRoll limits pre-roll audio to the configured speech padding duration.
 func (c *AudioChunker) capPreRoll() {
 	maxSamples := samplesForDuration(c.Config.speechPad, c.Config.sampleRate)
-	if len(c.preRollSamples) > maxSamples {
-		c.preRollSamples = append([]float32(nil), c.preRollSamples[len(c.preRollSamples)-maxSamples:]...)
+	if c.preRollSamples > maxSamples {
+		c.sharedSamples = max(c.sharedSamples-(c.preRollSamples-maxSamples), 0)
+		c.preRollSamples = maxSamples
 	}
 }
 
-// samplesForDuration converts a duration to a sample count at the given sample rate.
-func samplesForDuration(duration time.Duration, sampleRate int) int {
-	return int(duration.Seconds() * float64(sampleRate))
+// speechLength returns the number of samples buffered for the open utterance.
+func (c *AudioChunker) speechLength() int {
+	if !c.inSpeech {
+		return 0
+	}
+	return int(c.sampleCursor - c.speechStart)
 }
 
-// tailSamples returns a copy of at most count samples from the end of an audio buffer.
-func tailSamples(samples []float32, count int) []float32 {
-	if count <= 0 || len(samples) == 0 {
-		return nil
+// reserve makes sure the ring can take another frame without overwriting the
+// open utterance or pre-roll, growing it only when frames exceed the expected size.
+func (c *AudioChunker) reserve(frameSamples int) {
+	retained := c.preRollSamples
+	if c.inSpeech {
+		retained = c.speechLength()
 	}
-	if len(samples) <= count {
-		return append([]float32(nil), samples...)
+
+	required := retained + frameSamples
+	if c.audio == nil {
+		c.audio = newRingBuffer(max(required, c.ringCapacity()))
+		c.audio.end = c.sampleCursor
+		return
 	}
-	return append([]float32(nil), samples[len(samples)-count:]...)
+	if c.audio.capacity() < required {
+		c.audio.grow(max(required, 2*c.audio.capacity()), c.sampleCursor-int64(retained))
+	}
+}
+
+// ringCapacity returns the ring size needed for the longest utterance plus one
+// frame and the largest carried pre-roll.
+func (c *AudioChunker) ringCapacity() int {
+	frameSamples := samplesForDuration(c.Config.frameDuration, c.Config.sampleRate)
+	carried := max(samplesForDuration(c.Config.overlap, c.Config.sampleRate), samplesForDuration(c.Config.speechPad, c.Config.sampleRate))
+	return samplesForDuration(c.Config.maxFinalDuration, c.Config.sampleRate) + carried + 2*frameSamples
+}
+
+// copyChunk copies the absolute sample range [from, to) into a pooled buffer.
+func (c *AudioChunker) copyChunk(from, to int64) *[]float32 {
+	buffer := getChunkBuffer(int(to-from), c.audio.capacity())
+	c.audio.copyRange(*buffer, from, to)
+	return buffer
+}
+
+// samplesForDuration converts a duration to a sample count at the given sample rate.
+func samplesForDuration(duration time.Duration, sampleRate int) int {
+	return int(duration.Seconds() * float64(sampleRate))
 }
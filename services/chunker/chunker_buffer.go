@@ -0,0 +1,82 @@
+package chunker
+
+import (
+	"sync"
+)
+
+// ringBuffer keeps the most recent samples of a stream in a fixed-capacity
+// circular buffer addressed by absolute sample offsets.
+type ringBuffer struct {
+	// data is the circular storage; offset n lives at index n % len(data).
+	data []float32
+	// end is the absolute offset immediately after the newest stored sample.
+	end int64
+}
+
+// newRingBuffer allocates a ring that retains up to capacity samples.
+func newRingBuffer(capacity int) *ringBuffer {
+	return &ringBuffer{data: make([]float32, capacity)}
+}
+
+// capacity returns the number of samples the ring can retain.
+func (r *ringBuffer) capacity() int {
+	return len(r.data)
+}
+
+// write appends samples, overwriting the oldest retained audio once full.
+func (r *ringBuffer) write(samples []float32) {
+	if len(samples) > len(r.data) {
+		r.end += int64(len(samples) - len(r.data))
+		samples = samples[len(samples)-len(r.data):]
+	}
+
+	for len(samples) > 0 {
+		index := int(r.end % int64(len(r.data)))
+		n := copy(r.data[index:], samples)
+		samples = samples[n:]
+		r.end += int64(n)
+	}
+}
+
+// copyRange copies the samples in the absolute range [from, to) into dst, which
+// must have room for them. The range must still be retained by the ring.
+func (r *ringBuffer) copyRange(dst []float32, from, to int64) {
+	for from < to {
+		index := int(from % int64(len(r.data)))
+		n := copy(dst, r.data[index:min(len(r.data), index+int(to-from))])
+		dst = dst[n:]
+		from += int64(n)
+	}
+}
+
+// grow reallocates the ring with a larger capacity, preserving the samples from
+// the absolute offset keepFrom onward.
+func (r *ringBuffer) grow(capacity int, keepFrom int64) {
+	grown := &ringBuffer{data: make([]float32, capacity), end: keepFrom}
+	if keepFrom < r.end {
+		retained := make([]float32, r.end-keepFrom)
+		r.copyRange(retained, keepFrom, r.end)
+		grown.write(retained)
+	}
+	*r = *grown
+}
+
+// chunkBuffers recycles the sample buffers of released chunks.
+var chunkBuffers sync.Pool
+
+// getChunkBuffer returns a buffer of length n, reusing a released one when it is
+// large enough. New buffers get capacityHint so they can hold any later chunk.
+func getChunkBuffer(n, capacityHint int) *[]float32 {
+	if buffer, ok := chunkBuffers.Get().(*[]float32); ok && cap(*buffer) >= n {
+		*buffer = (*buffer)[:n]
+		return buffer
+	}
+
+	samples := make([]float32, n, max(n, capacityHint))
+	return &samples
+}
+
+// putChunkBuffer makes a buffer available to later chunks.
+func putChunkBuffer(buffer *[]float32) {
+	chunkBuffers.Put(buffer)
+}
@@ -0,0 +1,350 @@
+package chunker
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+)
+
+// SoundClass labels the kind of audio in a stretch of the stream.
+type SoundClass string
+
+const (
+	SoundSpeech  SoundClass = "speech"
+	SoundMusic   SoundClass = "music"
+	SoundNoise   SoundClass = "noise"
+	SoundSilence SoundClass = "silence"
+)
+
+const (
+	// classifierFFTSize is the FFT length used for the onset spectrum of each hop.
+	classifierFFTSize = 512
+	// classifierHop is the analysis step of the spectral flux envelope.
+	classifierHop = 25 * time.Millisecond
+	// classifierHistory is the sliding window the class decision is based on.
+	classifierHistory = 4 * time.Second
+	// classifierWarmUp is the history required before any frame is labeled non-speech.
+	classifierWarmUp = time.Second
+	// minPitch and maxPitch bound the fundamental frequencies searched for harmonicity.
+	minPitch = 50
+	maxPitch = 1000
+	// minBeatPeriod and maxBeatPeriod bound the onset periodicity treated as rhythm.
+	minBeatPeriod = 300 * time.Millisecond
+	maxBeatPeriod = 1500 * time.Millisecond
+)
+
+const (
+	// voicedHarmonicity is the autocorrelation peak above which a frame counts as tonal.
+	voicedHarmonicity = 0.6
+	// lowEnergyRatio is the fraction of the mean energy below which a hop counts as a dip.
+	lowEnergyRatio = 0.5
+	// musicRhythm is the onset periodicity above which tonal audio counts as music.
+	musicRhythm = 0.45
+	// sustainedDips is the dip fraction below which audio counts as sustained rather
+	// than syllabic; speech pauses between syllables several times a second.
+	sustainedDips = 0.1
+	// rhythmicDips is the dip fraction rhythmic music stays under even between beats.
+	rhythmicDips = 0.25
+)
+
+// soundClassifier labels energetic frames as speech, music or noise from the
+// spectral flux, harmonicity and rhythm regularity of a sliding window. It is a
+// heuristic tuned to keep speech: whenever the evidence is weak, it says speech.
+type soundClassifier struct {
+	// sampleRate is the number of input samples per second.
+	sampleRate int
+	// hann is the analysis window applied to each hop before the FFT.
+	hann []float64
+	// spectrum is the reused FFT buffer.
+	spectrum []complex128
+	// magnitudes holds the magnitude spectrum of the previous hop.
+	magnitudes []float64
+	// flux is the onset envelope: positive spectral flux per hop.
+	flux *historyBuffer
+	// energy is the RMS amplitude per hop.
+	energy *historyBuffer
+	// harmonicity is the autocorrelation peak per energetic frame, or NaN when undefined.
+	harmonicity *historyBuffer
+	// scratch is reused for mean-removed copies of frames and envelopes.
+	scratch []float64
+}
+
+// newSoundClassifier prepares the reusable analysis buffers for a sample rate.
+func newSoundClassifier(sampleRate int) *soundClassifier {
+	hop := samplesForDuration(classifierHop, sampleRate)
+	hann := make([]float64, hop)
+	for i := range hann {
+		hann[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(hop-1))
+	}
+
+	hops := int(classifierHistory / classifierHop)
+	return &soundClassifier{
+		sampleRate:  sampleRate,
+		hann:        hann,
+		spectrum:    make([]complex128, classifierFFTSize),
+		magnitudes:  make([]float64, classifierFFTSize/2),
+		flux:        newHistoryBuffer(hops),
+		energy:      newHistoryBuffer(hops),
+		harmonicity: newHistoryBuffer(hops),
+	}
+}
+
+// observe updates the window with one frame. Harmonicity is only measured for
+// energetic frames; quiet frames still feed the onset and energy envelopes.
+func (s *soundClassifier) observe(samples []float32, energetic bool) {
+	hop := len(s.hann)
+	for start := 0; start+hop <= len(samples); start += hop {
+		block := samples[start : start+hop]
+		s.flux.push(s.spectralFlux(block))
+		s.energy.push(rms(block))
+	}
+
+	if energetic {
+		s.harmonicity.push(s.frameHarmonicity(samples))
+	}
+}
+
+// classify labels the audio currently in the window.
+func (s *soundClassifier) classify() SoundClass {
+	warmUp := int(classifierWarmUp / classifierHop)
+	if s.flux.len() < warmUp {
+		return SoundSpeech
+	}
+
+	voiced, measured := 0, 0
+	for _, value := range s.harmonicity.values() {
+		if math.IsNaN(value) {
+			continue
+		}
+		measured++
+		if value >= voicedHarmonicity {
+			voiced++
+		}
+	}
+	if measured == 0 {
+		return SoundSpeech
+	}
+
+	voicedShare := float64(voiced) / float64(measured)
+	dips := s.dipShare()
+	switch {
+	case voicedShare >= 0.5 && dips < rhythmicDips && s.rhythm() >= musicRhythm:
+		return SoundMusic
+	case voicedShare >= 0.9 && dips < sustainedDips:
+		return SoundMusic
+	case voicedShare < 0.2 && dips < sustainedDips && s.fluxVariation() < 0.5:
+		return SoundNoise
+	default:
+		return SoundSpeech
+	}
+}
+
+// spectralFlux returns the normalized positive change of the magnitude spectrum
+// since the previous hop, which peaks at note and syllable onsets.
+func (s *soundClassifier) spectralFlux(block []float32) float64 {
+	for i := range s.spectrum {
+		s.spectrum[i] = 0
+	}
+	for i, sample := range block {
+		s.spectrum[i] = complex(float64(sample)*s.hann[i], 0)
+	}
+	fft(s.spectrum)
+
+	var rise, total float64
+	for i := range s.magnitudes {
+		magnitude := cmplx.Abs(s.spectrum[i+1])
+		rise += math.Max(0, magnitude-s.magnitudes[i])
+		total += magnitude
+		s.magnitudes[i] = magnitude
+	}
+	if total == 0 {
+		return 0
+	}
+	return rise / total
+}
+
+// dipShare returns the fraction of hops whose energy falls well below the window mean.
+func (s *soundClassifier) dipShare() float64 {
+	energies := s.energy.values()
+	mean := meanOf(energies)
+	if mean == 0 {
+		return 1
+	}
+
+	dips := 0
+	for _, value := range energies {
+		if value < lowEnergyRatio*mean {
+			dips++
+		}
+	}
+	return float64(dips) / float64(len(energies))
+}
+
+// fluxVariation returns the coefficient of variation of the onset envelope.
+// Stationary noise changes little from hop to hop; speech and music do not.
+func (s *soundClassifier) fluxVariation() float64 {
+	values := s.flux.values()
+	mean := meanOf(values)
+	if mean == 0 {
+		return 0
+	}
+
+	var variance float64
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+	return math.Sqrt(variance/float64(len(values))) / mean
+}
+
+// rhythm returns the strongest normalized autocorrelation of the onset envelope
+// at beat periods. Regular beats repeat their onsets; speech syllables do not.
+func (s *soundClassifier) rhythm() float64 {
+	values := s.flux.values()
+	mean := meanOf(values)
+	centered := s.centered(len(values))
+	for i, value := range values {
+		centered[i] = value - mean
+	}
+
+	minLag := int(minBeatPeriod / classifierHop)
+	maxLag := min(int(maxBeatPeriod/classifierHop), len(centered)/2)
+	best := 0.0
+	for lag := minLag; lag <= maxLag; lag++ {
+		best = math.Max(best, normalizedCorrelation(centered[:len(centered)-lag], centered[lag:]))
+	}
+	return best
+}
+
+// frameHarmonicity returns the strongest normalized autocorrelation of a frame at
+// pitch periods, or NaN when the frame has no variation to measure.
+func (s *soundClassifier) frameHarmonicity(samples []float32) float64 {
+	centered := s.centered(len(samples))
+	var mean float64
+	for _, sample := range samples {
+		mean += float64(sample)
+	}
+	mean /= float64(len(samples))
+
+	var energy float64
+	for i, sample := range samples {
+		centered[i] = float64(sample) - mean
+		energy += centered[i] * centered[i]
+	}
+	if energy < 1e-9*float64(len(samples)) {
+		return math.NaN()
+	}
+
+	minLag := s.sampleRate / maxPitch
+	maxLag := min(s.sampleRate/minPitch, len(centered)/2)
+	best := 0.0
+	for lag := minLag; lag <= maxLag; lag++ {
+		best = math.Max(best, normalizedCorrelation(centered[:len(centered)-lag], centered[lag:]))
+	}
+	return best
+}
+
+// centered returns the scratch buffer resized to n values.
+func (s *soundClassifier) centered(n int) []float64 {
+	if cap(s.scratch) < n {
+		s.scratch = make([]float64, n)
+	}
+	return s.scratch[:n]
+}
+
+// normalizedCorrelation returns the cosine similarity of two equally long signals.
+func normalizedCorrelation(a, b []float64) float64 {
+	var dot, energyA, energyB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		energyA += a[i] * a[i]
+		energyB += b[i] * b[i]
+	}
+	if energyA == 0 || energyB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(energyA*energyB)
+}
+
+// meanOf returns the arithmetic mean of values, or zero when there are none.
+func meanOf(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+// fft computes an in-place radix-2 Cooley-Tukey transform; len(x) must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			twiddle := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := x[start+k], twiddle*x[start+k+size/2]
+				x[start+k] = even + odd
+				x[start+k+size/2] = even - odd
+				twiddle *= step
+			}
+		}
+	}
+}
+
+// historyBuffer keeps the most recent feature values in insertion order.
+type historyBuffer struct {
+	data    []float64
+	ordered []float64
+	next    int
+	full    bool
+}
+
+// newHistoryBuffer allocates a history of the given capacity.
+func newHistoryBuffer(capacity int) *historyBuffer {
+	return &historyBuffer{
+		data:    make([]float64, capacity),
+		ordered: make([]float64, 0, capacity),
+	}
+}
+
+// push stores a value, evicting the oldest once full.
+func (h *historyBuffer) push(value float64) {
+	h.data[h.next] = value
+	h.next = (h.next + 1) % len(h.data)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// len returns the number of stored values.
+func (h *historyBuffer) len() int {
+	if h.full {
+		return len(h.data)
+	}
+	return h.next
+}
+
+// values returns the stored values from oldest to newest. The slice is reused by
+// the next call.
+func (h *historyBuffer) values() []float64 {
+	h.ordered = h.ordered[:0]
+	if h.full {
+		h.ordered = append(h.ordered, h.data[h.next:]...)
+	}
+	return append(h.ordered, h.data[:h.next]...)
+}
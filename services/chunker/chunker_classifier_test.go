@@ -0,0 +1,196 @@
+package chunker
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// TestSoundClassifierLabelsSyntheticAudio verifies that each synthetic signal is mostly given its own class.
+func TestSoundClassifierLabelsSyntheticAudio(t *testing.T) {
+	tests := []struct {
+		name     string
+		generate func(rng *rand.Rand, sampleRate int, duration time.Duration) []float32
+		expected SoundClass
+		minShare float64
+	}{
+		{name: "speech", generate: speechSignal, expected: SoundSpeech, minShare: 0.95},
+		{name: "music", generate: musicSignal, expected: SoundMusic, minShare: 0.5},
+		{name: "noise", generate: noiseSignal, expected: SoundNoise, minShare: 0.5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for seed := int64(1); seed <= 3; seed++ {
+				share := classifiedShare(test.generate(rand.New(rand.NewSource(seed)), DefaultConfig.sampleRate, 6*time.Second), test.expected)
+				if share < test.minShare {
+					t.Fatalf("seed %d: expected at least %.2f of frames labeled %s, got %.2f", seed, test.minShare, test.expected, share)
+				}
+			}
+		})
+	}
+}
+
+// TestSoundClassifierTreatsFlatFramesAsSpeech verifies that frames without measurable pitch never suppress speech.
+func TestSoundClassifierTreatsFlatFramesAsSpeech(t *testing.T) {
+	signal := make([]float32, samplesForDuration(3*time.Second, DefaultConfig.sampleRate))
+	for i := range signal {
+		signal[i] = 0.2
+	}
+
+	if share := classifiedShare(signal, SoundSpeech); share != 1 {
+		t.Fatalf("expected every flat frame labeled speech, got %.2f", share)
+	}
+}
+
+// TestAudioChunkerAnnotatesMusicInsteadOfTranscribingIt verifies that a music stretch becomes one annotation.
+func TestAudioChunkerAnnotatesMusicInsteadOfTranscribingIt(t *testing.T) {
+	audioChunker := NewAudioChunker()
+	rng := rand.New(rand.NewSource(1))
+
+	chunks := addTestSignal(audioChunker, musicSignal(rng, audioChunker.Config.sampleRate, 20*time.Second))
+	chunks = append(chunks, addTestFrames(audioChunker, 50, 0)...)
+	chunks = append(chunks, audioChunker.Flush()...)
+
+	var annotations []AudioChunk
+	for _, chunk := range chunks {
+		if chunk.Final && chunk.Annotation == "" {
+			t.Fatalf("expected no transcribable final for music, got one at %s", chunk.Start)
+		}
+		if chunk.Annotation != "" {
+			annotations = append(annotations, chunk)
+		}
+	}
+
+	if len(annotations) != 1 {
+		t.Fatalf("expected one annotation, got %d", len(annotations))
+	}
+	annotation := annotations[0]
+	if annotation.Annotation != SoundMusic || len(annotation.Samples) != 0 {
+		t.Fatalf("expected a sample-free music annotation, got %s with %d samples", annotation.Annotation, len(annotation.Samples))
+	}
+	if duration := annotation.End - annotation.Start; duration < 15*time.Second {
+		t.Fatalf("expected the annotation to cover most of the music, got %s", duration)
+	}
+	last := chunks[len(chunks)-1]
+	if last.Annotation != SoundMusic || last.UtteranceID != annotation.UtteranceID {
+		t.Fatal("expected the annotation to be the last chunk of its utterance")
+	}
+}
+
+// TestAudioChunkerAnnotatesLongSilence verifies that long gaps between utterances are reported with their own ID.
+func TestAudioChunkerAnnotatesLongSilence(t *testing.T) {
+	audioChunker := NewAudioChunker()
+	audioChunker.Config.minSilenceAnnotation = 2 * time.Second
+
+	chunks := addTestFrames(audioChunker, 10, 0.2)
+	chunks = append(chunks, addTestFrames(audioChunker, 150, 0)...)
+	chunks = append(chunks, addTestFrames(audioChunker, 10, 0.2)...)
+	chunks = append(chunks, audioChunker.Flush()...)
+
+	if len(chunks) != 3 {
+		t.Fatalf("expected final, silence annotation and final, got %d chunks", len(chunks))
+	}
+	silence := chunks[1]
+	if silence.Annotation != SoundSilence || !silence.Final {
+		t.Fatalf("expected a final silence annotation, got %q", silence.Annotation)
+	}
+	if silence.Start != chunks[0].End {
+		t.Fatalf("expected silence to start at the previous final end %s, got %s", chunks[0].End, silence.Start)
+	}
+	if chunks[0].UtteranceID != 1 || silence.UtteranceID != 2 || chunks[2].UtteranceID != 3 {
+		t.Fatalf("expected utterance IDs 1, 2 and 3, got %d, %d and %d",
+			chunks[0].UtteranceID, silence.UtteranceID, chunks[2].UtteranceID)
+	}
+}
+
+// classifiedShare returns the fraction of energetic frames the classifier gives the expected class.
+func classifiedShare(signal []float32, expected SoundClass) float64 {
+	sampleRate := DefaultConfig.sampleRate
+	frameSamples := samplesForDuration(DefaultConfig.frameDuration, sampleRate)
+	classifier := newSoundClassifier(sampleRate)
+
+	matched, total := 0, 0
+	for start := 0; start+frameSamples <= len(signal); start += frameSamples {
+		frame := signal[start : start+frameSamples]
+		energetic := rms(frame) >= DefaultConfig.energyThreshold
+		classifier.observe(frame, energetic)
+		if !energetic || samplesDuration(start, sampleRate) < 2*time.Second {
+			continue
+		}
+
+		total++
+		if classifier.classify() == expected {
+			matched++
+		}
+	}
+	return float64(matched) / float64(total)
+}
+
+// addTestSignal sends a signal to a chunker frame by frame and collects its output.
+func addTestSignal(audioChunker *AudioChunker, signal []float32) []AudioChunk {
+	frameSamples := samplesForDuration(audioChunker.Config.frameDuration, audioChunker.Config.sampleRate)
+
+	var chunks []AudioChunk
+	for start := 0; start+frameSamples <= len(signal); start += frameSamples {
+		chunks = append(chunks, audioChunker.AddFrame(signal[start:start+frameSamples])...)
+	}
+	return chunks
+}
+
+// speechSignal synthesizes voiced syllables with gliding pitch separated by short irregular pauses.
+func speechSignal(rng *rand.Rand, sampleRate int, duration time.Duration) []float32 {
+	signal := make([]float32, samplesForDuration(duration, sampleRate))
+	for i := 0; i < len(signal); {
+		syllable := samplesForDuration(time.Duration(120+rng.Intn(150))*time.Millisecond, sampleRate)
+		pitch := 110 + 90*rng.Float64()
+		phase := 0.0
+		for j := 0; j < syllable && i+j < len(signal); j++ {
+			progress := float64(j) / float64(syllable)
+			phase += 2 * math.Pi * pitch * (1 + 0.2*progress) / float64(sampleRate)
+			var value float64
+			for harmonic := 1; harmonic <= 8; harmonic++ {
+				value += math.Sin(float64(harmonic)*phase) / float64(harmonic)
+			}
+			signal[i+j] = float32(0.05 * math.Sin(math.Pi*progress) * value)
+		}
+		i += syllable
+
+		pause := time.Duration(40+rng.Intn(120)) * time.Millisecond
+		if rng.Float64() < 0.15 {
+			pause += 300 * time.Millisecond
+		}
+		i += samplesForDuration(pause, sampleRate)
+	}
+
+	for i := range signal {
+		signal[i] += float32(0.003 * rng.NormFloat64())
+	}
+	return signal
+}
+
+// musicSignal synthesizes a sustained chord with an accent on every beat.
+func musicSignal(_ *rand.Rand, sampleRate int, duration time.Duration) []float32 {
+	signal := make([]float32, samplesForDuration(duration, sampleRate))
+	beat := 0.5
+	for i := range signal {
+		at := float64(i) / float64(sampleRate)
+		envelope := 0.6 + 0.8*math.Exp(-math.Mod(at, beat)/0.06)
+		var value float64
+		for _, frequency := range []float64{220, 277.2, 329.6} {
+			value += math.Sin(2 * math.Pi * frequency * at)
+		}
+		signal[i] = float32(0.05 * envelope * value)
+	}
+	return signal
+}
+
+// noiseSignal synthesizes stationary white noise.
+func noiseSignal(rng *rand.Rand, sampleRate int, duration time.Duration) []float32 {
+	signal := make([]float32, samplesForDuration(duration, sampleRate))
+	for i := range signal {
+		signal[i] = float32(0.1 * rng.NormFloat64())
+	}
+	return signal
+}
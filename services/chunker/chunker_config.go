@@ -1,6 +1,8 @@
 package chunker
 
 import (
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/tuanta7/ekko/services/adapter/ffmpeg"
@@ -28,6 +30,12 @@ type Config struct {
 	maxFinalDuration time.Duration
 	// energyThreshold is the minimum RMS amplitude used to classify a frame as speech.
 	energyThreshold float64
+	// classifySound enables the speech/music/noise classifier for energetic frames.
+	classifySound bool
+	// minNonSpeechAnnotation is the shortest music or noise stretch reported as an annotation.
+	minNonSpeechAnnotation time.Duration
+	// minSilenceAnnotation is the shortest silence between utterances reported as an annotation.
+	minSilenceAnnotation time.Duration
 }
 
 // DefaultConfig contains the standard chunking settings used by NewAudioChunker.
@@ -42,4 +50,73 @@ var DefaultConfig = Config{
 	partialInterval:  2 * time.Second,
 	maxFinalDuration: 8 * time.Second,
 	energyThreshold:  0.01,
+
+	classifySound:          true,
+	minNonSpeechAnnotation: 5 * time.Second,
+	minSilenceAnnotation:   30 * time.Second,
+}
+
+// Set assigns the Config field with the given name from its text form, so that
+// tools can tune presets without exporting every field. Durations use Go
+// syntax such as "700ms"; sampleRate is an integer and classifySound a boolean.
+func (c *Config) Set(name, value string) error {
+	durations := map[string]*time.Duration{
+		"frameDuration":          &c.frameDuration,
+		"minSpeech":              &c.minSpeech,
+		"silenceToFinal":         &c.silenceToFinal,
+		"speechPad":              &c.speechPad,
+		"overlap":                &c.overlap,
+		"partialWindow":          &c.partialWindow,
+		"partialInterval":        &c.partialInterval,
+		"maxFinalDuration":       &c.maxFinalDuration,
+		"minNonSpeechAnnotation": &c.minNonSpeechAnnotation,
+		"minSilenceAnnotation":   &c.minSilenceAnnotation,
+	}
+	if field, ok := durations[name]; ok {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("chunker config %s: %w", name, err)
+		}
+		*field = duration
+		return nil
+	}
+
+	var err error
+	switch name {
+	case "sampleRate":
+		c.sampleRate, err = strconv.Atoi(value)
+	case "energyThreshold":
+		c.energyThreshold, err = strconv.ParseFloat(value, 64)
+	case "classifySound":
+		c.classifySound, err = strconv.ParseBool(value)
+	default:
+		return fmt.Errorf("unknown chunker config field %q", name)
+	}
+	if err != nil {
+		return fmt.Errorf("chunker config %s: %w", name, err)
+	}
+	return nil
+}
+
+// SampleRate returns the input sample rate the chunker expects.
+func (c Config) SampleRate() int {
+	return c.sampleRate
+}
+
+// FrameSamples returns the number of samples in one frameDuration frame.
+func (c Config) FrameSamples() int {
+	return samplesForDuration(c.frameDuration, c.sampleRate)
+}
+
+// PartialInterval returns the amount of new audio required between partial
+// chunks.
+func (c Config) PartialInterval() time.Duration {
+	return c.partialInterval
+}
+
+// SetPartialInterval changes the amount of new audio required between partial
+// chunks. It takes effect from the next frame, so that a session can emit
+// partials less often while transcription falls behind.
+func (c *Config) SetPartialInterval(interval time.Duration) {
+	c.partialInterval = interval
 }
@@ -7,19 +7,13 @@ import (
 	"golang.org/x/exp/constraints"
 )
 
-// isSpeech reports whether a frame's RMS amplitude meets the speech threshold.
-func isSpeech(samples []float32, threshold float64) bool {
-	if len(samples) == 0 {
-		return false
-	}
-
+// rms returns the root mean square amplitude of a block.
+func rms(samples []float32) float64 {
 	var sum float64
 	for _, sample := range samples {
 		sum += float64(sample * sample)
 	}
-
-	rms := math.Sqrt(sum / float64(len(samples)))
-	return rms >= threshold
+	return math.Sqrt(sum / float64(len(samples)))
 }
 
 // samplesDuration converts a 64-bit sample count to a duration.
@@ -80,6 +80,125 @@ func TestAudioChunkerPreservesConfiguredOverlapAfterMaximumDuration(t *testing.T
 	if len(chunks[0].Samples) != samplesForDuration(800*time.Millisecond, audioChunker.Config.sampleRate) {
 		t.Fatalf("expected overlap plus new speech, got %d samples", len(chunks[0].Samples))
 	}
+	if chunks[0].Overlap != 500*time.Millisecond {
+		t.Fatalf("expected chunk to report 500ms of overlap, got %s", chunks[0].Overlap)
+	}
+}
+
+// TestAudioChunkerReportsOverlapOnlyForSharedAudio verifies that padding and aged-out overlap are not reported.
+func TestAudioChunkerReportsOverlapOnlyForSharedAudio(t *testing.T) {
+	audioChunker := NewAudioChunker()
+	audioChunker.Config.partialInterval = time.Hour
+
+	addTestFrames(audioChunker, 10, 0.2)
+	addTestFrames(audioChunker, 7, 0)
+	addTestFrames(audioChunker, 5, 0.2)
+	chunks := audioChunker.Flush()
+	if len(chunks) != 1 || chunks[0].Overlap != 0 {
+		t.Fatalf("expected silence-finalized continuation without overlap, got %#v", chunks)
+	}
+
+	addTestFrames(audioChunker, 80, 0.2)
+	addTestFrames(audioChunker, 1, 0)
+	addTestFrames(audioChunker, 5, 0.2)
+	chunks = audioChunker.Flush()
+	if len(chunks) != 1 {
+		t.Fatalf("expected one flushed chunk, got %d", len(chunks))
+	}
+	if chunks[0].Overlap != 200*time.Millisecond {
+		t.Fatalf("expected pre-roll cap to shrink overlap to 200ms, got %s", chunks[0].Overlap)
+	}
+}
+
+// TestAudioChunkerLinksRevisionsOfAnUtterance verifies utterance IDs and revision ordering.
+func TestAudioChunkerLinksRevisionsOfAnUtterance(t *testing.T) {
+	audioChunker := NewAudioChunker()
+	audioChunker.Config.partialInterval = 3 * time.Second
+
+	chunks := addTestFrames(audioChunker, 80, 0.2)
+	if len(chunks) != 3 {
+		t.Fatalf("expected two partials and a forced final, got %d chunks", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.UtteranceID != 1 {
+			t.Fatalf("chunk %d: expected utterance 1, got %d", i, chunk.UtteranceID)
+		}
+		if chunk.Revision != i+1 {
+			t.Fatalf("chunk %d: expected revision %d, got %d", i, i+1, chunk.Revision)
+		}
+	}
+	if !chunks[2].Final {
+		t.Fatal("expected the last revision to be final")
+	}
+
+	addTestFrames(audioChunker, 3, 0.2)
+	chunks = audioChunker.Flush()
+	if len(chunks) != 1 {
+		t.Fatalf("expected one flushed chunk, got %d", len(chunks))
+	}
+	if chunks[0].UtteranceID != 2 || chunks[0].Revision != 1 {
+		t.Fatalf("expected continuation to start utterance 2 at revision 1, got %d/%d",
+			chunks[0].UtteranceID, chunks[0].Revision)
+	}
+}
+
+// TestAudioChunkerGrowsForOversizedFrames verifies that frames larger than the ring keep all utterance audio.
+func TestAudioChunkerGrowsForOversizedFrames(t *testing.T) {
+	audioChunker := NewAudioChunker()
+	// The periodic test frame is tonal and sustained, which the classifier would call music.
+	audioChunker.Config.classifySound = false
+	addTestFrames(audioChunker, 3, 0)
+
+	frame := make([]float32, samplesForDuration(10*time.Second, audioChunker.Config.sampleRate))
+	for i := range frame {
+		frame[i] = 0.2 + float32(i%7)*0.01
+	}
+
+	chunks := audioChunker.AddFrame(frame)
+	if len(chunks) != 2 || !chunks[1].Final {
+		t.Fatalf("expected a partial and a forced final, got %d chunks", len(chunks))
+	}
+
+	final := chunks[1]
+	preRoll := samplesForDuration(audioChunker.Config.speechPad, audioChunker.Config.sampleRate)
+	if len(final.Samples) != preRoll+len(frame) {
+		t.Fatalf("expected pre-roll plus the whole frame, got %d samples", len(final.Samples))
+	}
+	for i, sample := range final.Samples[preRoll:] {
+		if sample != frame[i] {
+			t.Fatalf("sample %d: expected %f, got %f", i, frame[i], sample)
+		}
+	}
+}
+
+// TestRingBufferCopiesAcrossWrap verifies reads that span the end of the circular storage.
+func TestRingBufferCopiesAcrossWrap(t *testing.T) {
+	ring := newRingBuffer(4)
+	ring.write([]float32{1, 2, 3})
+	ring.write([]float32{4, 5, 6})
+
+	dst := make([]float32, 4)
+	ring.copyRange(dst, 2, 6)
+	expectSamples(t, dst, []float32{3, 4, 5, 6})
+
+	ring.grow(8, 3)
+	ring.write([]float32{7})
+	dst = make([]float32, 4)
+	ring.copyRange(dst, 3, 7)
+	expectSamples(t, dst, []float32{4, 5, 6, 7})
+}
+
+// expectSamples compares two sample slices element by element.
+func expectSamples(t *testing.T, got, expected []float32) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d samples, got %d", len(expected), len(got))
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Fatalf("sample %d: expected %f, got %f", i, expected[i], got[i])
+		}
+	}
 }
 
 // addTestFrames sends repeated fixed-amplitude frames to a chunker and collects its output.
@@ -96,3 +215,41 @@ func addTestFrames(audioChunker *AudioChunker, count int, amplitude float32) []A
 	}
 	return chunks
 }
+
+// BenchmarkAudioChunkerContinuousSpeech measures per-frame cost while partials and forced finals are emitted.
+func BenchmarkAudioChunkerContinuousSpeech(b *testing.B) {
+	benchmarkAudioChunker(b, func(int) float32 { return 0.2 })
+}
+
+// BenchmarkAudioChunkerConversation measures per-frame cost for alternating speech and silence.
+func BenchmarkAudioChunkerConversation(b *testing.B) {
+	benchmarkAudioChunker(b, func(frame int) float32 {
+		if frame%40 < 30 {
+			return 0.2
+		}
+		return 0
+	})
+}
+
+// benchmarkAudioChunker feeds one frame per iteration and releases emitted chunks like the transcriber does.
+func benchmarkAudioChunker(b *testing.B, amplitude func(frame int) float32) {
+	audioChunker := NewAudioChunker()
+	frameSamples := samplesForDuration(audioChunker.Config.frameDuration, audioChunker.Config.sampleRate)
+	speech := make([]float32, frameSamples)
+	silence := make([]float32, frameSamples)
+	for i := range speech {
+		speech[i] = 0.2
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		frame := silence
+		if amplitude(i) != 0 {
+			frame = speech
+		}
+		for _, chunk := range audioChunker.AddFrame(frame) {
+			chunk.Release()
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package chunker
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Trace records the chunker's per-frame decisions and emitted chunk boundaries
+// so that Config values can be tuned against real recordings.
+type Trace struct {
+	// Frames holds one entry per non-empty frame passed to AddFrame.
+	Frames []FrameTrace `json:"frames"`
+	// Chunks holds every chunk returned by AddFrame and Flush, in emission order.
+	Chunks []ChunkTrace `json:"chunks"`
+}
+
+// FrameTrace is the detection state of one input frame.
+type FrameTrace struct {
+	// Start is the frame's offset from the beginning of the stream.
+	Start time.Duration `json:"-"`
+	// End is the offset immediately after the frame.
+	End time.Duration `json:"-"`
+	// RMS is the frame's root mean square amplitude.
+	RMS float64 `json:"rms"`
+	// Threshold is the energyThreshold the frame was compared with.
+	Threshold float64 `json:"threshold"`
+	// Speech reports whether the frame met the threshold.
+	Speech bool `json:"speech"`
+	// Class is the sound classifier's label, or silence for quiet frames.
+	Class SoundClass `json:"class"`
+	// InUtterance reports whether an utterance was open after the frame.
+	InUtterance bool `json:"inUtterance"`
+}
+
+// ChunkTrace is the boundary of one emitted chunk.
+type ChunkTrace struct {
+	UtteranceID int64         `json:"utteranceID"`
+	Revision    int           `json:"revision"`
+	Start       time.Duration `json:"-"`
+	End         time.Duration `json:"-"`
+	Overlap     time.Duration `json:"-"`
+	Final       bool          `json:"final"`
+	Annotation  SoundClass    `json:"annotation,omitempty"`
+}
+
+// NewTrace returns an empty trace ready to be attached to AudioChunker.Trace.
+func NewTrace() *Trace {
+	return &Trace{}
+}
+
+// recordFrame appends the state of the frame that AddFrame just processed.
+func (t *Trace) recordFrame(c *AudioChunker, frameStart int64, frameSamples int, level float64, class SoundClass) {
+	t.Frames = append(t.Frames, FrameTrace{
+		Start:       samplesDuration(frameStart, c.Config.sampleRate),
+		End:         samplesDuration(frameStart+int64(frameSamples), c.Config.sampleRate),
+		RMS:         level,
+		Threshold:   c.Config.energyThreshold,
+		Speech:      level >= c.Config.energyThreshold,
+		Class:       class,
+		InUtterance: c.inSpeech,
+	})
+}
+
+// recordChunks appends the boundaries of emitted chunks.
+func (t *Trace) recordChunks(chunks []AudioChunk) {
+	for _, chunk := range chunks {
+		t.Chunks = append(t.Chunks, ChunkTrace{
+			UtteranceID: chunk.UtteranceID,
+			Revision:    chunk.Revision,
+			Start:       chunk.Start,
+			End:         chunk.End,
+			Overlap:     chunk.Overlap,
+			Final:       chunk.Final,
+			Annotation:  chunk.Annotation,
+		})
+	}
+}
+
+// MarshalJSON adds millisecond offsets, which are easier to plot than nanoseconds.
+func (f FrameTrace) MarshalJSON() ([]byte, error) {
+	type frame FrameTrace
+	return json.Marshal(struct {
+		StartMs int64 `json:"startMs"`
+		EndMs   int64 `json:"endMs"`
+		frame
+	}{f.Start.Milliseconds(), f.End.Milliseconds(), frame(f)})
+}
+
+// MarshalJSON adds millisecond offsets, which are easier to plot than nanoseconds.
+func (c ChunkTrace) MarshalJSON() ([]byte, error) {
+	type chunk ChunkTrace
+	return json.Marshal(struct {
+		StartMs   int64 `json:"startMs"`
+		EndMs     int64 `json:"endMs"`
+		OverlapMs int64 `json:"overlapMs"`
+		chunk
+	}{c.Start.Milliseconds(), c.End.Milliseconds(), c.Overlap.Milliseconds(), chunk(c)})
+}
+
+// WriteJSON writes the trace as one indented JSON document.
+func (t *Trace) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(t)
+}
+
+// WriteCSV writes frames and chunks as one table, told apart by the kind column.
+// Columns that do not apply to a row are left empty.
+func (t *Trace) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{
+		"kind", "start_ms", "end_ms", "rms", "threshold", "speech", "class", "in_utterance",
+		"utterance_id", "revision", "final", "overlap_ms", "annotation",
+	})
+
+	for _, frame := range t.Frames {
+		_ = writer.Write([]string{
+			"frame",
+			formatMs(frame.Start),
+			formatMs(frame.End),
+			strconv.FormatFloat(frame.RMS, 'f', 6, 64),
+			strconv.FormatFloat(frame.Threshold, 'f', 6, 64),
+			strconv.FormatBool(frame.Speech),
+			string(frame.Class),
+			strconv.FormatBool(frame.InUtterance),
+			"", "", "", "", "",
+		})
+	}
+
+	for _, chunk := range t.Chunks {
+		_ = writer.Write([]string{
+			"chunk",
+			formatMs(chunk.Start),
+			formatMs(chunk.End),
+			"", "", "", "", "",
+			strconv.FormatInt(chunk.UtteranceID, 10),
+			strconv.Itoa(chunk.Revision),
+			strconv.FormatBool(chunk.Final),
+			formatMs(chunk.Overlap),
+			string(chunk.Annotation),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+const (
+	// svgPixelsPerSecond is the horizontal scale of the SVG timeline.
+	svgPixelsPerSecond = 50
+	// svgLevelHeight is the height of the RMS plot.
+	svgLevelHeight = 120
+	// svgLaneHeight is the height of each chunk lane below the plot.
+	svgLaneHeight = 14
+)
+
+// svgClassColours fills the frame band under the RMS plot by sound class.
+var svgClassColours = map[SoundClass]string{
+	SoundSpeech:  "#4ade80",
+	SoundMusic:   "#c084fc",
+	SoundNoise:   "#fb923c",
+	SoundSilence: "#e5e7eb",
+}
+
+// WriteSVG draws the trace as a timeline: RMS on a logarithmic scale with the
+// threshold line, a band coloured by frame class, then one lane for partials
+// and one for finals and annotations.
+func (t *Trace) WriteSVG(w io.Writer) error {
+	var duration time.Duration
+	if len(t.Frames) > 0 {
+		duration = t.Frames[len(t.Frames)-1].End
+	}
+
+	x := func(offset time.Duration) float64 {
+		return offset.Seconds() * svgPixelsPerSecond
+	}
+	// Levels span 1e-4 to 1 RMS, mapped bottom to top.
+	y := func(level float64) float64 {
+		level = math.Max(level, 1e-4)
+		return svgLevelHeight * (-math.Log10(level) / 4)
+	}
+
+	width := math.Max(x(duration), 1)
+	bandTop := float64(svgLevelHeight + 4)
+	partialTop := bandTop + svgLaneHeight
+	finalTop := partialTop + svgLaneHeight
+	height := finalTop + svgLaneHeight + 16
+
+	out := &svgWriter{w: w}
+	out.printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" font-family="sans-serif" font-size="9">`+"\n", width, height)
+	out.printf(`<rect width="100%%" height="100%%" fill="white"/>` + "\n")
+
+	for _, frame := range t.Frames {
+		out.printf(`<rect x="%.1f" y="%.1f" width="%.1f" height="%d" fill="%s"/>`+"\n",
+			x(frame.Start), bandTop, x(frame.End)-x(frame.Start), svgLaneHeight-2, svgClassColours[frame.Class])
+	}
+
+	out.printf(`<polyline fill="none" stroke="#2563eb" stroke-width="1" points="`)
+	for _, frame := range t.Frames {
+		out.printf("%.1f,%.1f %.1f,%.1f ", x(frame.Start), y(frame.RMS), x(frame.End), y(frame.RMS))
+	}
+	out.printf(`"/>` + "\n")
+
+	out.printf(`<polyline fill="none" stroke="#dc2626" stroke-dasharray="4 2" points="`)
+	for _, frame := range t.Frames {
+		out.printf("%.1f,%.1f %.1f,%.1f ", x(frame.Start), y(frame.Threshold), x(frame.End), y(frame.Threshold))
+	}
+	out.printf(`"/>` + "\n")
+
+	for _, chunk := range t.Chunks {
+		top, fill := partialTop, "#93c5fd"
+		switch {
+		case chunk.Annotation != "":
+			top, fill = finalTop, svgClassColours[chunk.Annotation]
+		case chunk.Final:
+			top, fill = finalTop, "#1d4ed8"
+		}
+		out.printf(`<rect x="%.1f" y="%.1f" width="%.1f" height="%d" fill="%s" fill-opacity="0.7" stroke="white">`,
+			x(chunk.Start), top, math.Max(x(chunk.End)-x(chunk.Start), 1), svgLaneHeight-2, fill)
+		out.printf(`<title>utterance %d rev %d %s-%s</title></rect>`+"\n",
+			chunk.UtteranceID, chunk.Revision, chunk.Start, chunk.End)
+	}
+
+	for second := 0; time.Duration(second)*time.Second <= duration; second += 5 {
+		offset := time.Duration(second) * time.Second
+		out.printf(`<text x="%.1f" y="%.1f">%s</text>`+"\n", x(offset)+2, height-4, offset)
+	}
+
+	out.printf("</svg>\n")
+	return out.err
+}
+
+// svgWriter keeps the first write error so the drawing code can stay linear.
+type svgWriter struct {
+	w   io.Writer
+	err error
+}
+
+// printf writes formatted output unless an earlier write failed.
+func (s *svgWriter) printf(format string, args ...any) {
+	if s.err != nil {
+		return
+	}
+	_, s.err = fmt.Fprintf(s.w, format, args...)
+}
+
+// formatMs formats a duration as whole milliseconds.
+func formatMs(duration time.Duration) string {
+	return strconv.FormatInt(duration.Milliseconds(), 10)
+}
@@ -0,0 +1,116 @@
+package chunker
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTraceRecordsFramesAndChunks verifies that a trace mirrors every frame decision and emitted chunk.
+func TestTraceRecordsFramesAndChunks(t *testing.T) {
+	audioChunker := NewAudioChunker()
+	audioChunker.Trace = NewTrace()
+
+	var chunks []AudioChunk
+	chunks = append(chunks, addTestFrames(audioChunker, 2, 0)...)
+	chunks = append(chunks, addTestFrames(audioChunker, 10, 0.2)...)
+	chunks = append(chunks, addTestFrames(audioChunker, 8, 0)...)
+	chunks = append(chunks, audioChunker.Flush()...)
+
+	trace := audioChunker.Trace
+	if len(trace.Frames) != 20 {
+		t.Fatalf("expected 20 traced frames, got %d", len(trace.Frames))
+	}
+	if len(trace.Chunks) != len(chunks) || len(chunks) == 0 {
+		t.Fatalf("expected %d traced chunks, got %d", len(chunks), len(trace.Chunks))
+	}
+
+	first, speech := trace.Frames[0], trace.Frames[2]
+	if first.Speech || first.InUtterance || first.Class != SoundSilence {
+		t.Fatalf("expected the first frame to be idle silence, got %+v", first)
+	}
+	if !speech.Speech || !speech.InUtterance || speech.Start != 200*time.Millisecond {
+		t.Fatalf("expected the third frame to open an utterance at 200ms, got %+v", speech)
+	}
+	if speech.Threshold != audioChunker.Config.energyThreshold {
+		t.Fatalf("expected threshold %f, got %f", audioChunker.Config.energyThreshold, speech.Threshold)
+	}
+	if trace.Chunks[0].End != chunks[0].End || !trace.Chunks[0].Final {
+		t.Fatalf("expected the traced final to match the emitted chunk, got %+v", trace.Chunks[0])
+	}
+}
+
+// TestTraceWritesEveryFormat verifies the CSV, JSON and SVG encodings of a trace.
+func TestTraceWritesEveryFormat(t *testing.T) {
+	audioChunker := NewAudioChunker()
+	audioChunker.Trace = NewTrace()
+	addTestFrames(audioChunker, 10, 0.2)
+	addTestFrames(audioChunker, 8, 0)
+	trace := audioChunker.Trace
+
+	var output bytes.Buffer
+	if err := trace.WriteCSV(&output); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	rows, err := csv.NewReader(&output).ReadAll()
+	if err != nil {
+		t.Fatalf("expected valid CSV, got %v", err)
+	}
+	if len(rows) != 1+len(trace.Frames)+len(trace.Chunks) {
+		t.Fatalf("expected a header plus one row per frame and chunk, got %d rows", len(rows))
+	}
+	if last := rows[len(rows)-1]; last[0] != "chunk" || last[2] != "1300" {
+		t.Fatalf("expected the final chunk row to end at 1300ms, got %v", last)
+	}
+
+	output.Reset()
+	if err := trace.WriteJSON(&output); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	var decoded struct {
+		Frames []map[string]any `json:"frames"`
+		Chunks []map[string]any `json:"chunks"`
+	}
+	if err := json.Unmarshal(output.Bytes(), &decoded); err != nil {
+		t.Fatalf("expected valid JSON, got %v", err)
+	}
+	if len(decoded.Frames) != 18 || decoded.Frames[1]["startMs"] != 100.0 || decoded.Chunks[0]["final"] != true {
+		t.Fatalf("expected frames with millisecond offsets and a final chunk, got %v", decoded)
+	}
+
+	output.Reset()
+	if err := trace.WriteSVG(&output); err != nil {
+		t.Fatalf("WriteSVG() error = %v", err)
+	}
+	svg := output.String()
+	if !strings.HasPrefix(svg, "<svg") || !strings.HasSuffix(svg, "</svg>\n") || !strings.Contains(svg, "utterance 1 rev 1") {
+		t.Fatalf("expected a complete SVG with the chunk lane, got %q", svg)
+	}
+}
+
+// TestConfigSetParsesFieldsByName verifies the text overrides used by tuning tools.
+func TestConfigSetParsesFieldsByName(t *testing.T) {
+	config := DefaultConfig
+	for name, value := range map[string]string{
+		"energyThreshold": "0.02",
+		"silenceToFinal":  "500ms",
+		"classifySound":   "false",
+	} {
+		if err := config.Set(name, value); err != nil {
+			t.Fatalf("Set(%s) error = %v", name, err)
+		}
+	}
+
+	if config.energyThreshold != 0.02 || config.silenceToFinal != 500*time.Millisecond || config.classifySound {
+		t.Fatalf("expected overrides to be applied, got %+v", config)
+	}
+	if err := config.Set("silenceToFinal", "soon"); err == nil {
+		t.Fatal("expected an invalid duration to be rejected")
+	}
+	if err := config.Set("threshold", "0.1"); err == nil {
+		t.Fatal("expected an unknown field to be rejected")
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tuanta7/ekko/services/diarize"
+)
+
+// DefaultSpeakerThreshold is the voice similarity above which a final is
+// attributed to a known speaker rather than a new one. EKKO_SPEAKER_THRESHOLD
+// overrides it.
+const DefaultSpeakerThreshold = 0.9
+
+// speakerDiarizer labels a session's finals with the speaker whose voice they
+// match. Speakers are numbered from 1 in order of appearance. A nil
+// speakerDiarizer labels nothing.
+type speakerDiarizer struct {
+	mu       sync.Mutex
+	clusters *diarize.Clusterer
+	// last is the speaker of the last labelled part, given to parts too short
+	// to tell the voice.
+	last int
+}
+
+func newSpeakerDiarizer(maxSpeakers int) *speakerDiarizer {
+	threshold := thresholdFromEnv("EKKO_SPEAKER_THRESHOLD", DefaultSpeakerThreshold)
+	return &speakerDiarizer{clusters: diarize.NewClusterer(float64(threshold), maxSpeakers)}
+}
+
+// current returns the speaker of the last labelled part.
+func (d *speakerDiarizer) current() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.last
+}
+
+// label sets the speaker of each part of a final from the voice in its stretch
+// of samples, the final chunk's audio. A single part takes the whole chunk.
+// Parts must be labelled in final order, as each one refines the speakers'
+// voice prints for those that follow.
+func (d *speakerDiarizer) label(parts []speakerPart, samples []float32) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i := range parts {
+		part := &parts[i]
+		audio := samples
+		if len(parts) > 1 && len(part.segments) > 0 {
+			audio = sampleRange(samples, part.segments[0].Start, part.segments[len(part.segments)-1].End)
+		}
+		if embedding := diarize.Embed(audio); embedding != nil {
+			d.last = d.clusters.Assign(embedding)
+		}
+		part.speaker = d.last
+	}
+}
+
+// sampleRange returns the samples between two offsets, clamped to the audio.
+func sampleRange(samples []float32, start, end time.Duration) []float32 {
+	from := min(len(samples), max(0, int(start*diarize.SampleRate/time.Second)))
+	to := min(len(samples), max(from, int(end*diarize.SampleRate/time.Second)))
+	return samples[from:to]
+}
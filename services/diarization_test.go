@@ -0,0 +1,83 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+	"github.com/tuanta7/ekko/services/diarize"
+)
+
+// vowel synthesizes a vowel-like sound: harmonics of pitch, loudest around
+// formant.
+func vowel(pitch, formant float64, duration time.Duration) []float32 {
+	samples := make([]float32, int(duration*diarize.SampleRate/time.Second))
+	for i := range samples {
+		t := float64(i) / diarize.SampleRate
+		value := 0.0
+		for harmonic := 1.0; harmonic*pitch < 7000; harmonic++ {
+			distance := (harmonic*pitch - formant) / 600
+			value += math.Exp(-distance*distance) * math.Sin(2*math.Pi*harmonic*pitch*t+harmonic)
+		}
+		samples[i] = float32(0.1 * value)
+	}
+	return samples
+}
+
+func TestSpeakerDiarizerLabelsPartsByVoice(t *testing.T) {
+	diarizer := newSpeakerDiarizer(0)
+	low, high := vowel(110, 700, time.Second), vowel(220, 2200, time.Second)
+
+	chunk := append(append([]float32(nil), low...), high...)
+	parts := []speakerPart{
+		{segments: []whisper.Segment{{Text: "Ready?", End: time.Second}}},
+		{segments: []whisper.Segment{{Text: "Almost.", Start: time.Second, End: 2 * time.Second}}},
+	}
+	diarizer.label(parts, chunk)
+	if parts[0].speaker != 1 || parts[1].speaker != 2 {
+		t.Fatalf("expected speakers 1 and 2, got %d and %d", parts[0].speaker, parts[1].speaker)
+	}
+
+	parts = []speakerPart{{segments: []whisper.Segment{{Text: "Go on."}}}}
+	diarizer.label(parts, vowel(112, 700, time.Second))
+	if parts[0].speaker != 1 {
+		t.Fatalf("expected the first voice again, got speaker %d", parts[0].speaker)
+	}
+
+	parts = []speakerPart{{segments: []whisper.Segment{{Text: "Hm."}}}}
+	diarizer.label(parts, high[:diarize.SampleRate/10])
+	if parts[0].speaker != 1 {
+		t.Fatalf("expected a short part to keep the last speaker, got %d", parts[0].speaker)
+	}
+}
+
+func TestRenameSpeakerAppliesToExports(t *testing.T) {
+	transcript := &Transcript{}
+	transcript.add(TranscriptEvent{UtteranceID: 1, Text: "Ready?", Speaker: 1})
+	transcript.add(TranscriptEvent{UtteranceID: 2, Text: "Almost.", Speaker: 2, StartMs: 2000})
+	service := &TranscribeService{transcripts: map[string]*Transcript{"s": transcript}}
+
+	if err := service.RenameSpeaker("s", 1, " Alice "); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.RenameSpeaker("s", 0, "Bob"); err == nil {
+		t.Fatal("expected speaker 0 to be rejected")
+	}
+
+	got, err := service.Export("s", ExportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "[0:00] Alice: Ready?\n\n[0:02] Speaker 2: Almost.\n"; got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	if err := service.RenameSpeaker("s", 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := service.Export("s", ExportOptions{}); !strings.HasPrefix(got, "[0:00] Speaker 1: Ready?") {
+		t.Fatalf("expected the numbered label back, got %q", got)
+	}
+}
@@ -0,0 +1,63 @@
+package diarize
+
+// Clusterer groups embeddings into speakers as they arrive. Each speaker is
+// represented by the mean of the embeddings assigned to it. A Clusterer is not
+// safe for concurrent use.
+type Clusterer struct {
+	// threshold is the similarity to a speaker's centroid above which an
+	// embedding is assigned to that speaker.
+	threshold float64
+	// maxSpeakers caps the number of speakers; past it, embeddings go to the
+	// most similar one. Zero means no cap.
+	maxSpeakers int
+	speakers    []centroid
+}
+
+// centroid is the running mean of a speaker's embeddings.
+type centroid struct {
+	sum   Embedding
+	count int
+}
+
+func (c centroid) mean() Embedding {
+	mean := make(Embedding, len(c.sum))
+	for i, value := range c.sum {
+		mean[i] = value / float64(c.count)
+	}
+	return mean
+}
+
+// NewClusterer returns a Clusterer that starts a new speaker for an embedding
+// less similar than threshold to every known speaker, up to maxSpeakers.
+func NewClusterer(threshold float64, maxSpeakers int) *Clusterer {
+	return &Clusterer{threshold: threshold, maxSpeakers: maxSpeakers}
+}
+
+// Assign returns the speaker of an embedding, numbered from 1 in order of
+// appearance, and updates that speaker's centroid with it.
+func (c *Clusterer) Assign(embedding Embedding) int {
+	best, bestSimilarity := -1, -1.0
+	for i, speaker := range c.speakers {
+		if similarity := Similarity(embedding, speaker.mean()); similarity > bestSimilarity {
+			best, bestSimilarity = i, similarity
+		}
+	}
+
+	full := c.maxSpeakers > 0 && len(c.speakers) >= c.maxSpeakers
+	if best < 0 || (bestSimilarity < c.threshold && !full) {
+		c.speakers = append(c.speakers, centroid{sum: append(Embedding(nil), embedding...), count: 1})
+		return len(c.speakers)
+	}
+
+	speaker := &c.speakers[best]
+	for i, value := range embedding {
+		speaker.sum[i] += value
+	}
+	speaker.count++
+	return best + 1
+}
+
+// Speakers returns the number of speakers found so far.
+func (c *Clusterer) Speakers() int {
+	return len(c.speakers)
+}
@@ -0,0 +1,31 @@
+package diarize
+
+import "testing"
+
+func TestClustererAssignsSpeakersInOrder(t *testing.T) {
+	clusterer := NewClusterer(0.9, 0)
+	speakers := []int{
+		clusterer.Assign(Embedding{1, 0, 0}),
+		clusterer.Assign(Embedding{0, 1, 0}),
+		clusterer.Assign(Embedding{0.95, 0.1, 0}),
+		clusterer.Assign(Embedding{0.1, 0.98, 0}),
+	}
+	for i, expected := range []int{1, 2, 1, 2} {
+		if speakers[i] != expected {
+			t.Fatalf("expected speakers 1, 2, 1, 2, got %v", speakers)
+		}
+	}
+}
+
+func TestClustererCapsSpeakers(t *testing.T) {
+	clusterer := NewClusterer(0.9, 2)
+	clusterer.Assign(Embedding{1, 0, 0})
+	clusterer.Assign(Embedding{0, 1, 0})
+
+	if speaker := clusterer.Assign(Embedding{0.2, 0, 1}); speaker != 1 {
+		t.Fatalf("expected the closest of the two speakers, got %d", speaker)
+	}
+	if speakers := clusterer.Speakers(); speakers != 2 {
+		t.Fatalf("expected 2 speakers, got %d", speakers)
+	}
+}
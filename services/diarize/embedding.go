@@ -0,0 +1,205 @@
+// Package diarize tells speakers apart by their voice. Each stretch of speech
+// is summarized as an embedding of MFCC statistics, and embeddings are
+// clustered online into speakers as they arrive.
+package diarize
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+const (
+	// SampleRate is the rate of the samples Embed takes.
+	SampleRate = 16000
+
+	// frameSize and hopSize are the 25 ms analysis window and its 10 ms step.
+	frameSize = 400
+	hopSize   = 160
+	// fftSize is the FFT length each frame is padded to.
+	fftSize = 512
+	// melBands is the number of mel filters between minFrequency and maxFrequency.
+	melBands     = 26
+	minFrequency = 20.0
+	maxFrequency = 7600.0
+	// coefficients is the number of cepstral coefficients kept per frame. The
+	// zeroth, which follows loudness rather than voice, is not among them.
+	coefficients = 19
+	// voicedRange is how far below the loudest frame, in dB, a frame still
+	// counts as speech rather than a pause.
+	voicedRange = 30.0
+	// silenceFloor is the mean frame power, in dB, below which a frame is
+	// silence however loud the rest is.
+	silenceFloor = -60.0
+	// minFrames is the number of speech frames, half a second, below which a
+	// stretch is too short to say who speaks.
+	minFrames = 50
+)
+
+// Embedding is a voice print of a stretch of speech: the mean and standard
+// deviation of each cepstral coefficient over its speech frames. Embeddings of
+// the same voice point in similar directions.
+type Embedding []float64
+
+// Embed computes the embedding of 16 kHz mono samples. It returns nil when the
+// samples hold too little speech to tell the voice.
+func Embed(samples []float32) Embedding {
+	if len(samples) < frameSize {
+		return nil
+	}
+
+	bank := filterBank()
+	window := make([]float64, frameSize)
+	for i := range window {
+		window[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(frameSize-1))
+	}
+
+	var frames [][]float64
+	var energies []float64
+	spectrum := make([]complex128, fftSize)
+	power := make([]float64, fftSize/2+1)
+	for start := 0; start+frameSize <= len(samples); start += hopSize {
+		clear(spectrum)
+		energy := 0.0
+		for i := range frameSize {
+			sample := float64(samples[start+i])
+			energy += sample * sample
+			spectrum[i] = complex(sample*window[i], 0)
+		}
+		fft(spectrum)
+		for i := range power {
+			power[i] = real(spectrum[i] * cmplx.Conj(spectrum[i]))
+		}
+
+		frames = append(frames, cepstrum(bank, power))
+		energies = append(energies, 10*math.Log10(energy/frameSize+1e-10))
+	}
+
+	loudest := math.Inf(-1)
+	for _, energy := range energies {
+		loudest = math.Max(loudest, energy)
+	}
+	var voiced [][]float64
+	for i, frame := range frames {
+		if energies[i] >= max(loudest-voicedRange, silenceFloor) {
+			voiced = append(voiced, frame)
+		}
+	}
+	if len(voiced) < minFrames {
+		return nil
+	}
+
+	embedding := make(Embedding, 2*coefficients)
+	for _, frame := range voiced {
+		for i, value := range frame {
+			embedding[i] += value
+		}
+	}
+	for i := range coefficients {
+		embedding[i] /= float64(len(voiced))
+	}
+	for _, frame := range voiced {
+		for i, value := range frame {
+			deviation := value - embedding[i]
+			embedding[coefficients+i] += deviation * deviation
+		}
+	}
+	for i := range coefficients {
+		embedding[coefficients+i] = math.Sqrt(embedding[coefficients+i] / float64(len(voiced)))
+	}
+	return embedding
+}
+
+// Similarity is the cosine similarity of two embeddings, from -1 to 1.
+func Similarity(a, b Embedding) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// cepstrum returns the cepstral coefficients of one frame's power spectrum:
+// the DCT of its log mel energies, without the zeroth coefficient.
+func cepstrum(bank [][]float64, power []float64) []float64 {
+	logMel := make([]float64, melBands)
+	for band, weights := range bank {
+		energy := 0.0
+		for bin, weight := range weights {
+			energy += weight * power[bin]
+		}
+		logMel[band] = math.Log(energy + 1e-10)
+	}
+
+	cepstral := make([]float64, coefficients)
+	for k := range coefficients {
+		sum := 0.0
+		for band, value := range logMel {
+			sum += value * math.Cos(math.Pi*float64(k+1)*(float64(band)+0.5)/melBands)
+		}
+		cepstral[k] = sum
+	}
+	return cepstral
+}
+
+// filterBank returns the weights of each triangular mel filter over the FFT bins.
+func filterBank() [][]float64 {
+	mel := func(frequency float64) float64 { return 2595 * math.Log10(1+frequency/700) }
+	hertz := func(mel float64) float64 { return 700 * (math.Pow(10, mel/2595) - 1) }
+
+	edges := make([]float64, melBands+2)
+	low, high := mel(minFrequency), mel(maxFrequency)
+	for i := range edges {
+		edges[i] = hertz(low+(high-low)*float64(i)/float64(melBands+1)) * fftSize / SampleRate
+	}
+
+	bank := make([][]float64, melBands)
+	for band := range bank {
+		weights := make([]float64, fftSize/2+1)
+		left, center, right := edges[band], edges[band+1], edges[band+2]
+		for bin := range weights {
+			position := float64(bin)
+			switch {
+			case position > left && position <= center:
+				weights[bin] = (position - left) / (center - left)
+			case position > center && position < right:
+				weights[bin] = (right - position) / (right - center)
+			}
+		}
+		bank[band] = weights
+	}
+	return bank
+}
+
+// fft computes an in-place radix-2 Cooley-Tukey transform; len(x) must be a
+// power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			twiddle := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := x[start+k], twiddle*x[start+k+size/2]
+				x[start+k] = even + odd
+				x[start+k+size/2] = even - odd
+				twiddle *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package diarize
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// voice synthesizes a second of a vowel-like sound: harmonics of pitch whose
+// loudness peaks around formant, with a little noise.
+func voice(pitch, formant float64, seed int64) []float32 {
+	random := rand.New(rand.NewSource(seed))
+	samples := make([]float32, SampleRate)
+	for i := range samples {
+		t := float64(i) / SampleRate
+		value := 0.0
+		for harmonic := 1; float64(harmonic)*pitch < maxFrequency; harmonic++ {
+			frequency := float64(harmonic) * pitch
+			distance := (frequency - formant) / 600
+			value += math.Exp(-distance*distance) * math.Sin(2*math.Pi*frequency*t+float64(harmonic))
+		}
+		samples[i] = float32(0.1*value + 0.005*random.NormFloat64())
+	}
+	return samples
+}
+
+func TestEmbedTellsVoicesApart(t *testing.T) {
+	low, lowAgain := Embed(voice(110, 700, 1)), Embed(voice(115, 700, 2))
+	high := Embed(voice(220, 2200, 3))
+	if low == nil || lowAgain == nil || high == nil {
+		t.Fatal("expected a second of voice to embed")
+	}
+
+	same, different := Similarity(low, lowAgain), Similarity(low, high)
+	if same <= different {
+		t.Fatalf("expected the same voice to be more similar (%.3f) than another (%.3f)", same, different)
+	}
+}
+
+func TestEmbedNeedsEnoughSpeech(t *testing.T) {
+	if embedding := Embed(voice(110, 700, 1)[:SampleRate/4]); embedding != nil {
+		t.Fatalf("expected a quarter second to be too short, got %v", embedding)
+	}
+	if embedding := Embed(make([]float32, SampleRate)); embedding != nil {
+		t.Fatalf("expected silence not to embed, got %v", embedding)
+	}
+}
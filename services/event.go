@@ -7,7 +7,7 @@ const (
 
 	EventState   = "transcribe:state"
 	EventPartial = "transcribe:partial"
-	EventFinal   = "transcribe:final" 
+	EventFinal   = "transcribe:final"
 	EventError   = "transcribe:error"
 )
 
@@ -15,15 +15,63 @@ type StateEvent struct {
 	SessionID string `json:"sessionID"`
 	State     string `json:"state"`
 	Message   string `json:"message"`
+	// Adapted marks a message explaining how the session adapted to the load,
+	// such as pausing partials while transcription falls behind.
+	Adapted bool `json:"adapted,omitempty"`
 }
 
+// TranscriptEvent carries the text of one transcribed chunk.
+//
+// Events with the same UtteranceID describe the same utterance. Listeners should
+// keep only the event with the highest Revision per utterance: a partial replaces
+// any earlier partial, and the final replaces every partial. A partial that
+// arrives after the final of its utterance, or with a lower Revision than one
+// already shown, is stale and should be ignored. ChunkID only orders jobs within
+// a session and says nothing about which event a transcript replaces.
 type TranscriptEvent struct {
-	SessionID string `json:"sessionID"`
-	ChunkID   int64  `json:"chunkID"`
-	Text      string `json:"text"`
-	Final     bool   `json:"final"`
-	StartMs   int64  `json:"startMs"`
-	EndMs     int64  `json:"endMs"`
+	SessionID   string `json:"sessionID"`
+	ChunkID     int64  `json:"chunkID"`
+	UtteranceID int64  `json:"utteranceID"`
+	Revision    int    `json:"revision"`
+	Text        string `json:"text"`
+	Final       bool   `json:"final"`
+	StartMs     int64  `json:"startMs"`
+	EndMs       int64  `json:"endMs"`
+	// RawText is the text as recognized, kept when text processing rewrote
+	// Text. It is empty otherwise.
+	RawText string `json:"rawText,omitempty"`
+	// Annotation is set instead of transcribed text for music, noise or silence
+	// stretches; Text then holds a label such as "[music 00:42]".
+	Annotation string `json:"annotation,omitempty"`
+	// Language is the ISO 639-1 code the chunk was transcribed as.
+	Language string `json:"language,omitempty"`
+	// LanguageProbability is the detection confidence, or zero when the
+	// language was requested or already locked for the session.
+	LanguageProbability float32 `json:"languageProbability,omitempty"`
+	// Words are the timed words of a final transcript. Partials are decoded
+	// without token timestamps and carry none.
+	Words []TranscriptWord `json:"words,omitempty"`
+	// Redactions counts the personal data redacted from Text by type, such as
+	// "card".
+	Redactions map[string]int `json:"redactions,omitempty"`
+	// Part numbers the events a final is split into at speaker turns, from 0.
+	// Each part is a separate transcript line of the same utterance.
+	Part int `json:"part,omitempty"`
+	// Speaker labels who speaks, from 1. When the session diarizes, it is the
+	// voice the line was matched to, the same number for the same person
+	// throughout. Otherwise, with a tinydiarize model, it alternates between 1
+	// and 2 at every speaker turn, telling that the speaker changed but not who
+	// speaks. It is zero when neither applies.
+	Speaker int `json:"speaker,omitempty"`
+}
+
+// TranscriptWord is one word of a transcript with its confidence. Offsets are
+// relative to the start of the session, like StartMs and EndMs.
+type TranscriptWord struct {
+	Text        string  `json:"text"`
+	StartMs     int64   `json:"startMs"`
+	EndMs       int64   `json:"endMs"`
+	Probability float32 `json:"probability"`
 }
 
 type ErrorEvent struct {
@@ -50,6 +98,16 @@ func (t *TranscribeService) emitState(sessionID string, state string, message st
 	})
 }
 
+// emitAdaptation explains a change in how the session keeps up with real time.
+func (t *TranscribeService) emitAdaptation(sessionID string, message string) {
+	t.emit(EventState, StateEvent{
+		SessionID: sessionID,
+		State:     EventTranscribing,
+		Message:   message,
+		Adapted:   true,
+	})
+}
+
 func (t *TranscribeService) emitTranscript(event TranscriptEvent) {
 	if event.Final {
 		t.emit(EventFinal, event)
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Glossary is a named list of terms, such as product names, colleagues or
+// acronyms, that whisper should spell as written.
+type Glossary struct {
+	Name  string   `json:"name"`
+	Terms []string `json:"terms"`
+}
+
+// glossaryStore keeps the user's glossaries in a JSON file.
+type glossaryStore struct {
+	// mu serializes the changes, which read the glossaries before writing them.
+	mu       sync.Mutex
+	settings *settingsStore[[]Glossary]
+}
+
+// newGlossaryStore stores glossaries at path. The file is created on the first save.
+func newGlossaryStore(path string) *glossaryStore {
+	return &glossaryStore{settings: newSettingsStore[[]Glossary](path)}
+}
+
+// configPath returns the path of a file in the user's ekko config directory.
+func configPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ekko", name), nil
+}
+
+// list returns every glossary sorted by name.
+func (s *glossaryStore) list() ([]Glossary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.settings.load()
+}
+
+// get returns the glossary with the given name.
+func (s *glossaryStore) get(name string) (Glossary, error) {
+	glossaries, err := s.list()
+	if err != nil {
+		return Glossary{}, err
+	}
+	for _, glossary := range glossaries {
+		if glossary.Name == name {
+			return glossary, nil
+		}
+	}
+	return Glossary{}, fmt.Errorf("glossary %q not found", name)
+}
+
+// save adds a glossary or replaces the one with the same name.
+func (s *glossaryStore) save(glossary Glossary) error {
+	glossary.Name = strings.TrimSpace(glossary.Name)
+	if glossary.Name == "" {
+		return errors.New("glossary name is empty")
+	}
+	glossary.Terms = cleanTerms(glossary.Terms)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	glossaries, err := s.settings.load()
+	if err != nil {
+		return err
+	}
+	glossaries = slices.DeleteFunc(glossaries, func(g Glossary) bool { return g.Name == glossary.Name })
+	return s.write(append(glossaries, glossary))
+}
+
+// delete removes the glossary with the given name, if any.
+func (s *glossaryStore) delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	glossaries, err := s.settings.load()
+	if err != nil {
+		return err
+	}
+	return s.write(slices.DeleteFunc(glossaries, func(g Glossary) bool { return g.Name == name }))
+}
+
+// write replaces the file with the glossaries sorted by name.
+func (s *glossaryStore) write(glossaries []Glossary) error {
+	slices.SortFunc(glossaries, func(a, b Glossary) int { return strings.Compare(a.Name, b.Name) })
+	return s.settings.save(glossaries)
+}
+
+// cleanTerms trims terms and drops empty and duplicate ones, keeping their order.
+func cleanTerms(terms []string) []string {
+	var kept []string
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if term != "" && !slices.Contains(kept, term) {
+			kept = append(kept, term)
+		}
+	}
+	return kept
+}
+
+// Glossaries returns the saved glossaries sorted by name.
+func (t *TranscribeService) Glossaries() ([]Glossary, error) {
+	return t.glossaries.list()
+}
+
+// SaveGlossary adds a glossary or replaces the saved one with the same name.
+func (t *TranscribeService) SaveGlossary(glossary Glossary) error {
+	return t.glossaries.save(glossary)
+}
+
+// DeleteGlossary removes a saved glossary.
+func (t *TranscribeService) DeleteGlossary(name string) error {
+	return t.glossaries.delete(name)
+}
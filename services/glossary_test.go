@@ -0,0 +1,47 @@
+package services
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGlossaryStoreSavesAndDeletes(t *testing.T) {
+	store := newGlossaryStore(filepath.Join(t.TempDir(), "ekko", "glossaries.json"))
+
+	if glossaries, err := store.list(); err != nil || len(glossaries) != 0 {
+		t.Fatalf("expected no glossaries before the first save, got %v, %v", glossaries, err)
+	}
+
+	if err := store.save(Glossary{Name: "work", Terms: []string{"Kubernetes", " ", "OKR"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.save(Glossary{Name: "family", Terms: []string{"Linh"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.save(Glossary{Name: "work", Terms: []string{"Kubernetes", "SLO"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.save(Glossary{Name: " "}); err == nil {
+		t.Fatal("expected a glossary without a name to be rejected")
+	}
+
+	work, err := store.get("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(work.Terms, []string{"Kubernetes", "SLO"}) {
+		t.Fatalf("expected the saved glossary to be replaced, got %v", work.Terms)
+	}
+
+	if err := store.delete("work"); err != nil {
+		t.Fatal(err)
+	}
+	glossaries, err := store.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(glossaries) != 1 || glossaries[0].Name != "family" {
+		t.Fatalf("expected only the family glossary, got %v", glossaries)
+	}
+}
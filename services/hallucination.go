@@ -0,0 +1,184 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+	"github.com/tuanta7/ekko/services/redact"
+)
+
+const (
+	// DefaultNoSpeechThreshold is the no-speech probability above which a
+	// low-confidence segment is dropped. EKKO_NO_SPEECH_THRESHOLD overrides it.
+	DefaultNoSpeechThreshold = 0.6
+	// DefaultLogProbThreshold is the average log probability below which a
+	// segment likely holding no speech is dropped. EKKO_LOGPROB_THRESHOLD
+	// overrides it.
+	DefaultLogProbThreshold = -1.0
+	// DefaultCompressionRatioThreshold is the compression ratio above which a
+	// segment is dropped as a decoding loop. EKKO_COMPRESSION_RATIO_THRESHOLD
+	// overrides it.
+	DefaultCompressionRatioThreshold = 2.4
+)
+
+// defaultPhantomPhrases are phrases whisper is known to produce on silence and
+// noise, learnt from the subtitles of online videos.
+var defaultPhantomPhrases = []string{
+	"Thanks for watching!",
+	"Thank you for watching.",
+	"Please subscribe to my channel.",
+	"Don't forget to like and subscribe.",
+	"See you in the next video.",
+	"Subtitles by the Amara.org community",
+	"Transcription by CastingWords",
+}
+
+// hallucinationFilter drops segments whisper most likely made up.
+type hallucinationFilter struct {
+	noSpeechThreshold         float32
+	logProbThreshold          float32
+	compressionRatioThreshold float32
+	phrases                   *phraseBlocklist
+}
+
+// newHallucinationFilter reads the thresholds from the environment.
+func newHallucinationFilter(phrases *phraseBlocklist) *hallucinationFilter {
+	return &hallucinationFilter{
+		noSpeechThreshold:         thresholdFromEnv("EKKO_NO_SPEECH_THRESHOLD", DefaultNoSpeechThreshold),
+		logProbThreshold:          thresholdFromEnv("EKKO_LOGPROB_THRESHOLD", DefaultLogProbThreshold),
+		compressionRatioThreshold: thresholdFromEnv("EKKO_COMPRESSION_RATIO_THRESHOLD", DefaultCompressionRatioThreshold),
+		phrases:                   phrases,
+	}
+}
+
+// apply returns the segments that pass the filter and logs the dropped ones,
+// with their text passed through redactor, which may be nil.
+func (f *hallucinationFilter) apply(
+	sessionID string,
+	redactor *redact.Redactor,
+	segments []whisper.Segment,
+) []whisper.Segment {
+	var kept []whisper.Segment
+	for _, segment := range segments {
+		if reason := f.reason(segment); reason != "" {
+			text, _ := redactor.Redact(segment.Text)
+			log.Printf("session %s: dropped segment %q at %s: %s", sessionID, text, segment.Start, reason)
+			continue
+		}
+		kept = append(kept, segment)
+	}
+	return kept
+}
+
+// reason explains why a segment is dropped, or is empty when it is kept.
+func (f *hallucinationFilter) reason(segment whisper.Segment) string {
+	if ratio := segment.CompressionRatio(); ratio > f.compressionRatioThreshold {
+		return fmt.Sprintf("compression ratio %.2f above %.2f", ratio, f.compressionRatioThreshold)
+	}
+	// Like whisper, a segment likely holding no speech is only dropped when the
+	// decode was unsure too, so that soft but clear speech survives.
+	logProb := segment.AvgLogProbability()
+	if segment.NoSpeechProbability > f.noSpeechThreshold && logProb < f.logProbThreshold {
+		return fmt.Sprintf("no speech probability %.2f above %.2f with average log probability %.2f below %.2f",
+			segment.NoSpeechProbability, f.noSpeechThreshold, logProb, f.logProbThreshold)
+	}
+	if f.phrases != nil && f.phrases.contains(segment.Text) {
+		return "phantom phrase"
+	}
+	return ""
+}
+
+// thresholdFromEnv parses a float variable, falling back to fallback.
+func thresholdFromEnv(name string, fallback float32) float32 {
+	value, err := strconv.ParseFloat(os.Getenv(name), 32)
+	if err != nil {
+		return fallback
+	}
+	return float32(value)
+}
+
+// phraseBlocklist holds phantom phrases in a text file, one per line, so users
+// can edit it by hand as well as from the app.
+type phraseBlocklist struct {
+	mu       sync.Mutex
+	settings *settingsStore[[]string]
+	// phrases is the list as written by the user.
+	phrases []string
+	// normalized holds the phrases compared against segment text.
+	normalized map[string]bool
+}
+
+// newPhraseBlocklist loads the blocklist at path, starting from
+// defaultPhantomPhrases until the user saves one.
+func newPhraseBlocklist(path string) (*phraseBlocklist, error) {
+	blocklist := &phraseBlocklist{settings: newLinesStore(path, defaultPhantomPhrases)}
+
+	phrases, err := blocklist.settings.load()
+	if err != nil {
+		return nil, err
+	}
+	blocklist.set(phrases)
+	return blocklist, nil
+}
+
+// list returns the phrases as the user wrote them.
+func (b *phraseBlocklist) list() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return slices.Clone(b.phrases)
+}
+
+// save replaces the phrases and writes them to the file.
+func (b *phraseBlocklist) save(phrases []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.set(phrases)
+	return b.settings.save(b.phrases)
+}
+
+// set replaces the phrases in memory.
+func (b *phraseBlocklist) set(phrases []string) {
+	b.phrases = cleanTerms(phrases)
+	b.normalized = make(map[string]bool, len(b.phrases))
+	for _, phrase := range b.phrases {
+		b.normalized[normalizePhrase(phrase)] = true
+	}
+}
+
+// contains reports whether text is one of the phrases, ignoring case and punctuation.
+func (b *phraseBlocklist) contains(text string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.normalized[normalizePhrase(text)]
+}
+
+// normalizePhrase normalizes every word of text for comparison.
+func normalizePhrase(text string) string {
+	var words []string
+	for _, word := range strings.Fields(text) {
+		if word = normalizeWord(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// PhantomPhrases returns the phrases dropped from transcripts whenever a
+// segment consists of one of them.
+func (t *TranscribeService) PhantomPhrases() []string {
+	return t.filter.phrases.list()
+}
+
+// SavePhantomPhrases replaces the phantom phrase blocklist.
+func (t *TranscribeService) SavePhantomPhrases(phrases []string) error {
+	return t.filter.phrases.save(phrases)
+}
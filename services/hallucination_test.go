@@ -0,0 +1,71 @@
+package services
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+)
+
+func TestHallucinationFilterDropsSuspectSegments(t *testing.T) {
+	blocklist, err := newPhraseBlocklist(filepath.Join(t.TempDir(), "phantom-phrases.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := &hallucinationFilter{
+		noSpeechThreshold:         DefaultNoSpeechThreshold,
+		logProbThreshold:          DefaultLogProbThreshold,
+		compressionRatioThreshold: DefaultCompressionRatioThreshold,
+		phrases:                   blocklist,
+	}
+
+	confident := []whisper.Token{{Probability: 0.9}}
+	unsure := []whisper.Token{{Probability: 0.1}}
+	segments := []whisper.Segment{
+		{Text: "Let's start with the roadmap.", Tokens: confident},
+		{Text: "thanks for watching", Tokens: confident},
+		{Text: strings.Repeat("so so so so ", 10), Tokens: confident},
+		{Text: "Okay.", Tokens: unsure, NoSpeechProbability: 0.9},
+		{Text: "quietly said", Tokens: confident, NoSpeechProbability: 0.9},
+	}
+
+	kept := filter.apply("test", nil, segments)
+	var texts []string
+	for _, segment := range kept {
+		texts = append(texts, segment.Text)
+	}
+	if !slices.Equal(texts, []string{"Let's start with the roadmap.", "quietly said"}) {
+		t.Fatalf("expected only the real and the confident quiet segment, got %q", texts)
+	}
+}
+
+func TestPhraseBlocklistSavesUserPhrases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ekko", "phantom-phrases.txt")
+	blocklist, err := newPhraseBlocklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(blocklist.list(), defaultPhantomPhrases) {
+		t.Fatalf("expected the default phrases before the first save, got %q", blocklist.list())
+	}
+
+	if err := blocklist.save([]string{"Ming Pao Canada", " ", "Ming Pao Canada"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := newPhraseBlocklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(reloaded.list(), []string{"Ming Pao Canada"}) {
+		t.Fatalf("expected the saved phrase, got %q", reloaded.list())
+	}
+	if !reloaded.contains("ming pao, canada!") {
+		t.Fatal("expected matching to ignore case and punctuation")
+	}
+	if reloaded.contains("thanks for watching") {
+		t.Fatal("expected saved phrases to replace the defaults")
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+)
+
+// languageLockProbability is the detection confidence on a final chunk at which
+// a session stops detecting and keeps that language, so that short or noisy
+// chunks cannot flip the transcript into another language.
+const languageLockProbability = 0.8
+
+// SessionOptions configures a transcription session started with Start.
+type SessionOptions struct {
+	// Backend names the transcription backend, defaulting to DefaultBackend.
+	Backend string `json:"backend"`
+	// Language is the spoken language as an ISO 639-1 code such as "en". Empty
+	// or "auto" detects it, then locks the session to the first confident result.
+	Language string `json:"language"`
+	// Translate outputs English text whatever the spoken language.
+	Translate bool `json:"translate"`
+	// Glossary names a saved glossary whose terms are given to whisper as context.
+	Glossary string `json:"glossary"`
+	// Terms are extra glossary terms for this session only.
+	Terms []string `json:"terms"`
+	// PartialDecoding tunes the decoding of partials, defaulting to
+	// whisper.DefaultPartialDecodeOptions.
+	PartialDecoding *whisper.DecodeOptions `json:"partialDecoding,omitempty"`
+	// FinalDecoding tunes the decoding of finals, defaulting to
+	// whisper.DefaultFinalDecodeOptions.
+	FinalDecoding *whisper.DecodeOptions `json:"finalDecoding,omitempty"`
+	// PartialModel names an installed whisper model to transcribe partials
+	// with, such as a small one for instant feedback. Empty uses the backend's
+	// model. Only the whisper backend takes a model.
+	PartialModel string `json:"partialModel,omitempty"`
+	// FinalModel names an installed whisper model to transcribe finals with,
+	// such as a larger one for accuracy. Empty uses the backend's model.
+	FinalModel string `json:"finalModel,omitempty"`
+	// RefineModel names an installed whisper model, usually a larger one, that
+	// transcribes every final again once the session ends. Empty skips the
+	// refinement.
+	RefineModel string `json:"refineModel,omitempty"`
+	// Diarize tells speakers apart by their voice and labels every final with
+	// its speaker.
+	Diarize bool `json:"diarize,omitempty"`
+	// MaxSpeakers caps the number of speakers diarization finds. Zero means no
+	// cap.
+	MaxSpeakers int `json:"maxSpeakers,omitempty"`
+}
+
+// validate checks the options against what the backend supports.
+func (o SessionOptions) validate(capabilities whisper.Capabilities) error {
+	if o.Translate && !capabilities.Translate {
+		return fmt.Errorf("translation needs a multilingual model: %w", whisper.ErrModelNotMultilingual)
+	}
+	if err := o.decoding(false).Validate(); err != nil {
+		return fmt.Errorf("partial decoding: %w", err)
+	}
+	if err := o.decoding(true).Validate(); err != nil {
+		return fmt.Errorf("final decoding: %w", err)
+	}
+	if o.MaxSpeakers < 0 {
+		return errors.New("maximum speakers cannot be negative")
+	}
+	if o.Language == "" || o.Language == whisper.AutoLanguage {
+		return nil
+	}
+	if capabilities.Languages != nil && !slices.Contains(capabilities.Languages, o.Language) {
+		return fmt.Errorf("language %q is not supported by the backend", o.Language)
+	}
+	return nil
+}
+
+// decoding returns the decoder settings for partial or final chunks.
+func (o SessionOptions) decoding(final bool) whisper.DecodeOptions {
+	switch {
+	case final && o.FinalDecoding != nil:
+		return *o.FinalDecoding
+	case final:
+		return whisper.DefaultFinalDecodeOptions()
+	case o.PartialDecoding != nil:
+		return *o.PartialDecoding
+	default:
+		return whisper.DefaultPartialDecodeOptions()
+	}
+}
+
+// sessionLanguage tracks the language a session transcribes in.
+type sessionLanguage struct {
+	mu sync.Mutex
+	// language is the requested or locked language, empty while detecting.
+	language string
+}
+
+// newSessionLanguage starts from the requested language, detecting when none is given.
+func newSessionLanguage(requested string) *sessionLanguage {
+	if requested == whisper.AutoLanguage {
+		requested = ""
+	}
+	return &sessionLanguage{language: requested}
+}
+
+// current returns the language to request for the next chunk.
+func (l *sessionLanguage) current() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.language == "" {
+		return whisper.AutoLanguage
+	}
+	return l.language
+}
+
+// observe locks the session to a confidently detected language and reports
+// whether this result did so. Partials are too short to be trusted.
+func (l *sessionLanguage) observe(result whisper.Result, final bool) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.language != "" || !final || result.LanguageProbability < languageLockProbability {
+		return false
+	}
+	l.language = result.Language
+	return true
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+)
+
+func TestSessionLanguageLocksOnConfidentFinal(t *testing.T) {
+	language := newSessionLanguage("")
+	if got := language.current(); got != whisper.AutoLanguage {
+		t.Fatalf("expected detection to start with %q, got %q", whisper.AutoLanguage, got)
+	}
+
+	if language.observe(whisper.Result{Language: "de", LanguageProbability: 0.95}, false) {
+		t.Fatal("expected a partial not to lock the language")
+	}
+	if language.observe(whisper.Result{Language: "nl", LanguageProbability: 0.5}, true) {
+		t.Fatal("expected an unsure final not to lock the language")
+	}
+	if !language.observe(whisper.Result{Language: "de", LanguageProbability: 0.9}, true) {
+		t.Fatal("expected a confident final to lock the language")
+	}
+	if language.observe(whisper.Result{Language: "fr", LanguageProbability: 0.99}, true) {
+		t.Fatal("expected a locked language to stay locked")
+	}
+	if got := language.current(); got != "de" {
+		t.Fatalf("expected de, got %q", got)
+	}
+}
+
+func TestSessionLanguageKeepsRequestedLanguage(t *testing.T) {
+	language := newSessionLanguage("vi")
+	if language.observe(whisper.Result{Language: "en", LanguageProbability: 1}, true) {
+		t.Fatal("expected a requested language never to be replaced")
+	}
+	if got := language.current(); got != "vi" {
+		t.Fatalf("expected vi, got %q", got)
+	}
+}
+
+func TestSessionOptionsDecodingDefaults(t *testing.T) {
+	var options SessionOptions
+	if got := options.decoding(false); got != whisper.DefaultPartialDecodeOptions() {
+		t.Fatalf("expected the partial defaults, got %+v", got)
+	}
+	if got := options.decoding(true); got.Strategy != whisper.StrategyBeam {
+		t.Fatalf("expected finals to use beam search by default, got %+v", got)
+	}
+
+	options.FinalDecoding = &whisper.DecodeOptions{Strategy: whisper.StrategyGreedy, Temperature: 0.4}
+	if got := options.decoding(true); got != *options.FinalDecoding {
+		t.Fatalf("expected the requested final decoding, got %+v", got)
+	}
+	if got := options.decoding(false); got != whisper.DefaultPartialDecodeOptions() {
+		t.Fatalf("expected partials to keep their defaults, got %+v", got)
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/ffmpeg"
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+	"github.com/tuanta7/ekko/services/chunker"
+)
+
+const (
+	// highLoad is the real-time factor above which a session sheds work: past
+	// it, transcription barely keeps up with the audio coming in.
+	highLoad = 0.8
+	// lowLoad is the real-time factor below which a session takes back the
+	// work it shed. The gap to highLoad keeps it from going back and forth.
+	lowLoad = 0.4
+	// loadSmoothing is the weight of the latest transcription in the smoothed
+	// real-time factor.
+	loadSmoothing = 0.3
+	// loadSettle is the number of transcriptions measured after a change of
+	// level before the next one, so that the change shows in the measurements.
+	loadSettle = 3
+	// slowPartialFactor stretches the partial interval at loadSlowPartials.
+	slowPartialFactor = 2
+	// narrowBeamSize is the beam size of finals from loadNarrowBeam.
+	narrowBeamSize = 2
+)
+
+// loadLevel is how much work a session sheds to keep up with real time. Each
+// level sheds what the ones below it do.
+type loadLevel int
+
+const (
+	loadNormal loadLevel = iota
+	// loadSlowPartials emits partials half as often.
+	loadSlowPartials
+	// loadNoPartials stops transcribing partials.
+	loadNoPartials
+	// loadNarrowBeam decodes finals with a narrower beam.
+	loadNarrowBeam
+	// loadOverloaded has nothing left to shed and suggests a smaller model.
+	loadOverloaded
+)
+
+// loadAdapter measures how fast a session transcribes compared to real time
+// and, while it falls behind, trades latency and accuracy for speed, giving
+// them back once the load drops.
+type loadAdapter struct {
+	mu sync.Mutex
+	// rtf is the smoothed real-time factor: the time a transcription takes
+	// over the duration of its audio, shared among the backend's workers.
+	rtf   float64
+	level loadLevel
+	// settling counts down the transcriptions left to measure before the
+	// level may change again.
+	settling int
+}
+
+func newLoadAdapter() *loadAdapter {
+	return &loadAdapter{}
+}
+
+// observe records a transcription of audio that took elapsed on a backend
+// running concurrency of them at once. When the load moves the session to
+// another level, it returns a message explaining the change.
+func (l *loadAdapter) observe(elapsed, audio time.Duration, concurrency int) string {
+	if audio <= 0 {
+		return ""
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rtf := elapsed.Seconds() / audio.Seconds() / float64(max(1, concurrency))
+	if l.rtf == 0 {
+		l.rtf = rtf
+	} else {
+		l.rtf += loadSmoothing * (rtf - l.rtf)
+	}
+
+	if l.settling > 0 {
+		l.settling--
+		return ""
+	}
+	switch {
+	case l.rtf > highLoad && l.level < loadOverloaded:
+		l.level++
+		l.settling = loadSettle
+		return fmt.Sprintf("Falling behind at %.1fx real time: %s", l.rtf, l.level.shed())
+	case l.rtf < lowLoad && l.level > loadNormal:
+		restored := l.level.restore()
+		l.level--
+		l.settling = loadSettle
+		return fmt.Sprintf("Keeping up at %.1fx real time: %s", l.rtf, restored)
+	}
+	return ""
+}
+
+// shed describes what a session does on reaching the level.
+func (l loadLevel) shed() string {
+	switch l {
+	case loadSlowPartials:
+		return "partials less often"
+	case loadNoPartials:
+		return "partials paused"
+	case loadNarrowBeam:
+		return "finals decoded with a narrower beam"
+	default:
+		return "a smaller model would keep up"
+	}
+}
+
+// restore describes what a session takes back on leaving the level.
+func (l loadLevel) restore() string {
+	switch l {
+	case loadSlowPartials:
+		return "partials as often as before"
+	case loadNoPartials:
+		return "partials resumed"
+	case loadNarrowBeam:
+		return "finals decoded with the full beam"
+	default:
+		return "the model keeps up again"
+	}
+}
+
+// realTimeFactor returns the smoothed real-time factor, zero before the first
+// transcription.
+func (l *loadAdapter) realTimeFactor() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.rtf
+}
+
+// current returns the session's level.
+func (l *loadAdapter) current() loadLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.level
+}
+
+// tune sets the chunker's partial interval for the level, stretching base.
+func (l *loadAdapter) tune(config *chunker.Config, base time.Duration) {
+	interval := base
+	if l.current() >= loadSlowPartials {
+		interval = base * slowPartialFactor
+	}
+	config.SetPartialInterval(interval)
+}
+
+// shedPartials drops the partials among chunks while partials are paused,
+// releasing their samples, and returns the rest.
+func (l *loadAdapter) shedPartials(chunks []chunker.AudioChunk) []chunker.AudioChunk {
+	if l.current() < loadNoPartials {
+		return chunks
+	}
+
+	kept := chunks[:0]
+	for _, chunk := range chunks {
+		if chunk.Final {
+			kept = append(kept, chunk)
+			continue
+		}
+		chunk.Release()
+	}
+	return kept
+}
+
+// decoding narrows the beam of a final's decoder settings from loadNarrowBeam.
+func (l *loadAdapter) decoding(options whisper.DecodeOptions, final bool) whisper.DecodeOptions {
+	if !final || options.Strategy != whisper.StrategyBeam || l.current() < loadNarrowBeam {
+		return options
+	}
+
+	if options.BeamSize == 0 || options.BeamSize > narrowBeamSize {
+		options.BeamSize = narrowBeamSize
+	}
+	return options
+}
+
+// samplesDuration returns how long samples of 16 kHz audio last.
+func samplesDuration(samples []float32) time.Duration {
+	return time.Duration(len(samples)) * time.Second / time.Duration(ffmpeg.DefaultSampleRate)
+}
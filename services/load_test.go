@@ -0,0 +1,94 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+	"github.com/tuanta7/ekko/services/chunker"
+)
+
+// observeUntilChange feeds the adapter transcriptions of a second of audio
+// that took elapsed until its level changes, and returns the message.
+func observeUntilChange(t *testing.T, load *loadAdapter, elapsed time.Duration) string {
+	t.Helper()
+	for range 20 {
+		if message := load.observe(elapsed, time.Second, 1); message != "" {
+			return message
+		}
+	}
+	t.Fatalf("expected the level to change from %d", load.current())
+	return ""
+}
+
+func TestLoadAdapterShedsWorkAndRestoresIt(t *testing.T) {
+	load := newLoadAdapter()
+	if message := load.observe(200*time.Millisecond, time.Second, 1); message != "" {
+		t.Fatalf("expected no change while keeping up, got %q", message)
+	}
+
+	for level := loadSlowPartials; level <= loadOverloaded; level++ {
+		message := observeUntilChange(t, load, 2*time.Second)
+		if load.current() != level || !strings.HasPrefix(message, "Falling behind") {
+			t.Fatalf("expected level %d with an explanation, got %d: %q", level, load.current(), message)
+		}
+	}
+	for range 20 {
+		if message := load.observe(2*time.Second, time.Second, 1); message != "" {
+			t.Fatalf("expected nothing left to shed, got %q", message)
+		}
+	}
+
+	for level := loadNarrowBeam; level >= loadNormal; level-- {
+		message := observeUntilChange(t, load, 0)
+		if load.current() != level || !strings.HasPrefix(message, "Keeping up") {
+			t.Fatalf("expected level %d with an explanation, got %d: %q", level, load.current(), message)
+		}
+	}
+}
+
+func TestLoadAdapterSharesLoadAmongWorkers(t *testing.T) {
+	load := newLoadAdapter()
+	if message := load.observe(1200*time.Millisecond, time.Second, 2); message != "" {
+		t.Fatalf("expected two workers to keep up, got %q", message)
+	}
+	if rtf := load.realTimeFactor(); rtf != 0.6 {
+		t.Fatalf("expected a real-time factor of 0.6, got %v", rtf)
+	}
+}
+
+func TestLoadAdapterAppliesLevel(t *testing.T) {
+	load := &loadAdapter{level: loadSlowPartials}
+	config := chunker.DefaultConfig
+	load.tune(&config, 2*time.Second)
+	if interval := config.PartialInterval(); interval != 4*time.Second {
+		t.Fatalf("expected partials every 4s, got %v", interval)
+	}
+
+	chunks := []chunker.AudioChunk{{UtteranceID: 1}, {UtteranceID: 1, Final: true}}
+	if kept := load.shedPartials(chunks); len(kept) != 2 {
+		t.Fatalf("expected partials to be kept, got %d chunks", len(kept))
+	}
+	beam := whisper.DecodeOptions{Strategy: whisper.StrategyBeam, BeamSize: 5}
+	if options := load.decoding(beam, true); options.BeamSize != 5 {
+		t.Fatalf("expected the full beam, got %d", options.BeamSize)
+	}
+
+	load.level = loadNarrowBeam
+	if kept := load.shedPartials(chunks); len(kept) != 1 || !kept[0].Final {
+		t.Fatalf("expected only the final, got %+v", kept)
+	}
+	if options := load.decoding(beam, true); options.BeamSize != narrowBeamSize {
+		t.Fatalf("expected a beam of %d, got %d", narrowBeamSize, options.BeamSize)
+	}
+	if options := load.decoding(beam, false); options.BeamSize != 5 {
+		t.Fatalf("expected partials to keep their beam, got %d", options.BeamSize)
+	}
+
+	load.level = loadNormal
+	load.tune(&config, 2*time.Second)
+	if interval := config.PartialInterval(); interval != 2*time.Second {
+		t.Fatalf("expected partials every 2s again, got %v", interval)
+	}
+}
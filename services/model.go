@@ -0,0 +1,662 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+const (
+	// DefaultModelMirror serves the ggml models whisper.cpp publishes. Set
+	// EKKO_MODEL_MIRROR to download from elsewhere.
+	DefaultModelMirror = "https://huggingface.co/ggerganov/whisper.cpp/resolve/main"
+
+	EventModelProgress = "model:progress"
+
+	// modelProgressInterval throttles progress events during an install.
+	modelProgressInterval = 250 * time.Millisecond
+	// modelHeaderTimeout is how long the mirror may take to start responding.
+	modelHeaderTimeout = 30 * time.Second
+	// modelStallTimeout is how long an install may go without receiving data
+	// before it is abandoned.
+	modelStallTimeout = 30 * time.Second
+)
+
+var (
+	// ErrInstallCancelled is returned by an install stopped with CancelInstall.
+	ErrInstallCancelled = errors.New("model install cancelled")
+	// errInstallStalled ends an install that received no data for
+	// modelStallTimeout.
+	errInstallStalled = errors.New("model install stalled")
+	// errNoChecksum reports that no checksum is known to verify a model against.
+	errNoChecksum = errors.New("no checksum known")
+)
+
+// Model is an installed ggml model.
+type Model struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// Bytes is the size of the model file.
+	Bytes int64 `json:"bytes"`
+	// Size is the model family, such as "tiny" or "large".
+	Size         string `json:"size"`
+	Multilingual bool   `json:"multilingual"`
+	Quantization string `json:"quantization"`
+	// SHA256 is the checksum the model was verified against when it was
+	// installed, if any.
+	SHA256 string `json:"sha256,omitempty"`
+	// Unverified reports that SHA256 was computed on import, with no expected
+	// checksum to verify the model against.
+	Unverified bool `json:"unverified,omitempty"`
+	// Active reports whether sessions transcribe with the model.
+	Active bool `json:"active"`
+}
+
+// ModelCheck is the result of verifying a model's checksum.
+type ModelCheck struct {
+	SHA256 string `json:"sha256"`
+	// Expected is the checksum the mirror publishes for the model or, failing
+	// that, the one it was verified against when installed. It is empty when
+	// neither is known, and the model cannot be verified.
+	Expected string `json:"expected"`
+	// Source tells where Expected comes from: "mirror", "install", or
+	// "import" when it was computed on an import that had nothing to verify
+	// the model against.
+	Source   string `json:"source,omitempty"`
+	Verified bool   `json:"verified"`
+}
+
+// ModelProgressEvent reports how far a model install has come.
+type ModelProgressEvent struct {
+	Name string `json:"name"`
+	// Bytes is the number of bytes copied so far.
+	Bytes int64 `json:"bytes"`
+	// Total is the size of the model, or zero when the source did not say.
+	Total int64 `json:"total"`
+	Done  bool  `json:"done"`
+}
+
+// ModelService lists, installs and verifies whisper models, and switches the
+// model the whisper backend transcribes with.
+type ModelService struct {
+	mu sync.Mutex
+	// switching serializes UseModel, which closes the previous model without
+	// holding mu.
+	switching sync.Mutex
+	ctx       context.Context
+	app       *application.App
+
+	transcribe *TranscribeService
+	// dirs are searched for models in order; earlier directories win.
+	dirs []string
+	// installDir is where installed models are written.
+	installDir string
+	mirror     string
+	http       *http.Client
+	// stallTimeout abandons an install that receives no data for that long.
+	stallTimeout time.Duration
+	// installs cancel the installs in progress, by model name.
+	installs map[string]context.CancelCauseFunc
+	// active is the name of the model sessions transcribe with.
+	active string
+}
+
+var _ application.ServiceStartup = (*ModelService)(nil)
+
+// NewModelService manages the models of the whisper backend of transcribe.
+func NewModelService(transcribe *TranscribeService) *ModelService {
+	return &ModelService{transcribe: transcribe}
+}
+
+func (m *ModelService) ServiceStartup(ctx context.Context, options application.ServiceOptions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	installDir, err := whisper.UserModelDir()
+	if err != nil {
+		return err
+	}
+
+	m.app = application.Get()
+	m.ctx = ctx
+	m.dirs = whisper.ModelDirs()
+	m.installDir = installDir
+	m.mirror = os.Getenv("EKKO_MODEL_MIRROR")
+	if m.mirror == "" {
+		m.mirror = DefaultModelMirror
+	}
+	// Downloads take as long as they take, but a mirror that stops answering
+	// must not hold them up forever; the stall timer covers the body.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = modelHeaderTimeout
+	m.http = &http.Client{Transport: transport}
+	m.stallTimeout = modelStallTimeout
+	m.installs = make(map[string]context.CancelCauseFunc)
+	m.active = whisper.ModelName()
+	return nil
+}
+
+// Models returns the installed models sorted by name. A model installed in
+// several directories is listed once, from the directory it loads from.
+func (m *ModelService) Models() ([]Model, error) {
+	m.mu.Lock()
+	dirs, active := m.dirs, m.active
+	m.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var models []Model
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			name, ok := whisper.ModelNameOf(entry.Name())
+			if !ok || entry.IsDir() || seen[name] {
+				continue
+			}
+			model, err := readModel(filepath.Join(dir, entry.Name()), name)
+			if err != nil {
+				// Half-written or foreign files are not models; skip them.
+				continue
+			}
+			model.Active = name == active
+			seen[name] = true
+			models = append(models, model)
+		}
+	}
+
+	slices.SortFunc(models, func(a, b Model) int { return strings.Compare(a.Name, b.Name) })
+	return models, nil
+}
+
+// readModel describes the model file at path.
+func readModel(path, name string) (Model, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Model{}, err
+	}
+	header, err := whisper.ReadModelHeader(path)
+	if err != nil {
+		return Model{}, err
+	}
+
+	sum, verified := recordedChecksum(path)
+	return Model{
+		Name:         name,
+		Path:         path,
+		Bytes:        info.Size(),
+		Size:         header.Size,
+		Multilingual: header.Multilingual,
+		Quantization: header.Quantization,
+		SHA256:       sum,
+		Unverified:   sum != "" && !verified,
+	}, nil
+}
+
+// VerifyModel hashes the named model and compares it with the checksum the
+// mirror publishes for it, or with the one it was verified against when
+// installed when the mirror publishes none or cannot be reached.
+func (m *ModelService) VerifyModel(name string) (ModelCheck, error) {
+	path, err := m.find(name)
+	if err != nil {
+		return ModelCheck{}, err
+	}
+
+	check := ModelCheck{Source: "install"}
+	if sum, verified := recordedChecksum(path); sum != "" {
+		check.Expected = sum
+		if !verified {
+			check.Source = "import"
+		}
+	}
+	m.mu.Lock()
+	ctx := m.ctx
+	m.mu.Unlock()
+	if sum, err := m.upstreamChecksum(ctx, name); err == nil && sum != "" {
+		check.Expected, check.Source = sum, "mirror"
+	}
+	if check.Expected == "" {
+		check.Source = ""
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return ModelCheck{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return ModelCheck{}, err
+	}
+
+	check.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	check.Verified = check.Expected != "" && check.Expected == check.SHA256
+	return check, nil
+}
+
+// DownloadModel installs the named model from the mirror. checksum is the
+// expected SHA-256 of the file; when empty, the mirror must publish one. A
+// mismatching download is discarded.
+func (m *ModelService) DownloadModel(name, checksum string) (Model, error) {
+	if !whisper.ValidModelName(name) {
+		return Model{}, fmt.Errorf("invalid model name %q", name)
+	}
+
+	ctx, done, err := m.startInstall(name)
+	if err != nil {
+		return Model{}, err
+	}
+	defer done()
+
+	checksum, err = m.expectedChecksum(ctx, name, checksum)
+	if err != nil {
+		return Model{}, installError(ctx, err)
+	}
+
+	m.mu.Lock()
+	url := m.mirror + "/" + whisper.ModelFile(name)
+	m.mu.Unlock()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Model{}, err
+	}
+	response, err := m.http.Do(request)
+	if err != nil {
+		return Model{}, installError(ctx, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return Model{}, fmt.Errorf("download %s: %s", url, response.Status)
+	}
+
+	return m.install(ctx, name, response.Body, response.ContentLength, checksum)
+}
+
+// ImportModel installs a model file from a local path. The model is named
+// after the file, without its ggml- prefix and .bin extension. checksum is the
+// expected SHA-256 of the file; when empty, the one the mirror publishes for a
+// model of that name is. When neither is known, the file is trusted as it is
+// and its checksum recorded as unverified.
+func (m *ModelService) ImportModel(path, checksum string) (Model, error) {
+	base := filepath.Base(path)
+	name, ok := whisper.ModelNameOf(base)
+	if !ok {
+		name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	if !whisper.ValidModelName(name) {
+		return Model{}, fmt.Errorf("cannot name a model after %s", base)
+	}
+
+	ctx, done, err := m.startInstall(name)
+	if err != nil {
+		return Model{}, err
+	}
+	defer done()
+
+	checksum, err = m.expectedChecksum(ctx, name, checksum)
+	if err != nil && (!errors.Is(err, errNoChecksum) || ctx.Err() != nil) {
+		return Model{}, installError(ctx, err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return Model{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return Model{}, err
+	}
+	return m.install(ctx, name, file, info.Size(), checksum)
+}
+
+// CancelInstall stops the download or import of the named model. Nothing of
+// it is left behind.
+func (m *ModelService) CancelInstall(name string) error {
+	m.mu.Lock()
+	cancel, ok := m.installs[name]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("model %s is not being installed", name)
+	}
+
+	cancel(ErrInstallCancelled)
+	return nil
+}
+
+// startInstall registers an install of the named model and returns its
+// context, which CancelInstall and shutting the app down end. done must be
+// called once the install is over.
+func (m *ModelService) startInstall(name string) (context.Context, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.installs[name]; ok {
+		return nil, nil, fmt.Errorf("model %s is already being installed", name)
+	}
+	ctx, cancel := context.WithCancelCause(m.ctx)
+	m.installs[name] = cancel
+	return ctx, func() {
+		cancel(nil)
+		m.mu.Lock()
+		delete(m.installs, name)
+		m.mu.Unlock()
+	}, nil
+}
+
+// installError reports why an install failed: err, unless the install was
+// cancelled or stalled.
+func installError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return err
+}
+
+// install writes a model into the install directory, emitting progress as it
+// goes. The model only appears under its name once it is complete, matches
+// checksum and is readable as a ggml model. The checksum is then recorded
+// next to it so that it can be verified later; without one, the model's own
+// is recorded as unverified.
+func (m *ModelService) install(ctx context.Context, name string, src io.Reader, total int64, checksum string) (Model, error) {
+	m.mu.Lock()
+	dir, stallTimeout := m.installDir, m.stallTimeout
+	m.mu.Unlock()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Model{}, err
+	}
+	path := filepath.Join(dir, whisper.ModelFile(name))
+	tmp, err := os.CreateTemp(dir, whisper.ModelFile(name)+".*.tmp")
+	if err != nil {
+		return Model{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	progress := &progressWriter{report: func(copied int64) {
+		m.emit(EventModelProgress, ModelProgressEvent{Name: name, Bytes: copied, Total: total})
+	}}
+	reader := newInstallReader(ctx, src, stallTimeout)
+	_, err = io.Copy(io.MultiWriter(tmp, hash, progress), reader)
+	reader.stop()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Model{}, fmt.Errorf("install model %s: %w", name, installError(ctx, err))
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if checksum != "" && sum != checksum {
+		return Model{}, fmt.Errorf("model %s: checksum %s does not match the expected %s", name, sum, checksum)
+	}
+	if _, err := whisper.ReadModelHeader(tmp.Name()); err != nil {
+		return Model{}, fmt.Errorf("model %s: %w", name, err)
+	}
+
+	// The checksum follows the model, so that a failed rename leaves the
+	// previous model with its own checksum.
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Model{}, err
+	}
+	m.transcribe.evictModel(name)
+	record := sum + "  " + whisper.ModelFile(name) + "\n"
+	if checksum == "" {
+		record = unverifiedComment + "\n" + record
+	}
+	if err := writeFileAtomic(checksumPath(path), []byte(record)); err != nil {
+		_ = os.Remove(checksumPath(path))
+		return Model{}, fmt.Errorf("record checksum of model %s: %w", name, err)
+	}
+	m.emit(EventModelProgress, ModelProgressEvent{Name: name, Bytes: progress.copied, Total: total, Done: true})
+
+	model, err := readModel(path, name)
+	if err != nil {
+		return Model{}, err
+	}
+	m.mu.Lock()
+	model.Active = name == m.active
+	m.mu.Unlock()
+	return model, nil
+}
+
+// UseModel switches the whisper backend to the named model. The model loads
+// when the next session starts; it cannot be switched during a session.
+func (m *ModelService) UseModel(name string) error {
+	path, err := m.find(name)
+	if err != nil {
+		return err
+	}
+	if _, err := whisper.ReadModelHeader(path); err != nil {
+		return fmt.Errorf("model %s: %w", name, err)
+	}
+
+	m.switching.Lock()
+	defer m.switching.Unlock()
+
+	err = m.transcribe.setBackend(DefaultBackend, func() (Transcriber, error) {
+		return whisper.NewScriber(whisper.ScriberOptions{ModelPath: path})
+	})
+	if err != nil {
+		return err
+	}
+
+	m.transcribe.evictModel(name)
+
+	m.mu.Lock()
+	m.active = name
+	m.mu.Unlock()
+	return nil
+}
+
+// find returns the path of the named model.
+func (m *ModelService) find(name string) (string, error) {
+	m.mu.Lock()
+	dirs := m.dirs
+	m.mu.Unlock()
+
+	return whisper.FindModel(dirs, name)
+}
+
+func (m *ModelService) emit(name string, data any) {
+	m.mu.Lock()
+	app := m.app
+	m.mu.Unlock()
+
+	if app != nil {
+		app.Event.Emit(name, data)
+	}
+}
+
+// expectedChecksum returns the SHA-256 a model must have: checksum when one is
+// given, or else the one the mirror publishes for the named model. It fails
+// with errNoChecksum when neither is known.
+func (m *ModelService) expectedChecksum(ctx context.Context, name, checksum string) (string, error) {
+	if checksum = strings.ToLower(strings.TrimSpace(checksum)); checksum != "" {
+		if !isSHA256(checksum) {
+			return "", fmt.Errorf("checksum %q is not a SHA-256", checksum)
+		}
+		return checksum, nil
+	}
+
+	sum, err := m.upstreamChecksum(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("model %s: %w: looking it up: %w", name, errNoChecksum, err)
+	}
+	if sum == "" {
+		return "", fmt.Errorf("model %s: %w: the mirror publishes none; give its expected SHA-256", name, errNoChecksum)
+	}
+	return sum, nil
+}
+
+// upstreamChecksum asks the mirror for the SHA-256 of the named model. Hugging
+// Face, like other Git LFS hosts, publishes it as the ETag of the file, in
+// X-Linked-Etag when the file itself is served from elsewhere. It returns an
+// empty string when the mirror publishes none.
+func (m *ModelService) upstreamChecksum(ctx context.Context, name string) (string, error) {
+	m.mu.Lock()
+	url, client := m.mirror+"/"+whisper.ModelFile(name), *m.http
+	m.mu.Unlock()
+
+	// The checksum is on the mirror's own response, not on the redirect target's.
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return "", err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	_ = response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("%s: %s", url, response.Status)
+	}
+
+	for _, header := range []string{"X-Linked-Etag", "Etag"} {
+		sum := strings.ToLower(strings.Trim(strings.TrimPrefix(response.Header.Get(header), "W/"), `"`))
+		if isSHA256(sum) {
+			return sum, nil
+		}
+	}
+	return "", nil
+}
+
+// isSHA256 reports whether sum is a hex-encoded SHA-256.
+func isSHA256(sum string) bool {
+	if len(sum) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(sum)
+	return err == nil
+}
+
+// unverifiedComment marks the checksum file of a model imported without an
+// expected checksum. sha256sum skips it as a comment.
+const unverifiedComment = "# unverified: computed on import"
+
+// checksumPath is the sha256sum file recording the checksum of a model.
+func checksumPath(modelPath string) string {
+	return modelPath + ".sha256"
+}
+
+// recordedChecksum reads the checksum recorded for a model, or returns an
+// empty string when there is none. verified reports whether the model was
+// verified against it when installed.
+func recordedChecksum(modelPath string) (sum string, verified bool) {
+	file, err := os.Open(checksumPath(modelPath))
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	verified = true
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == unverifiedComment {
+			verified = false
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, _, _ = strings.Cut(line, " ")
+		return strings.ToLower(sum), verified
+	}
+	return "", false
+}
+
+// installReader reads a model being installed until its install's context
+// ends, which it does itself with errInstallStalled when no data arrives for
+// the stall timeout.
+type installReader struct {
+	ctx   context.Context
+	src   io.Reader
+	stall *time.Timer
+	// release stops the stall timer and the context watch.
+	release func()
+	// timeout is the stall timeout.
+	timeout time.Duration
+}
+
+func newInstallReader(ctx context.Context, src io.Reader, timeout time.Duration) *installReader {
+	ctx, cancel := context.WithCancelCause(ctx)
+	// A blocked read does not look at the context, so the source, such as a
+	// response body, is closed to unblock it once the install ends.
+	unwatch := context.AfterFunc(ctx, func() {
+		if closer, ok := src.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	})
+	stall := time.AfterFunc(timeout, func() { cancel(errInstallStalled) })
+	return &installReader{
+		ctx:   ctx,
+		src:   src,
+		stall: stall,
+		release: func() {
+			stall.Stop()
+			unwatch()
+			cancel(nil)
+		},
+		timeout: timeout,
+	}
+}
+
+func (r *installReader) Read(data []byte) (int, error) {
+	if r.ctx.Err() != nil {
+		return 0, context.Cause(r.ctx)
+	}
+
+	n, err := r.src.Read(data)
+	if n > 0 {
+		r.stall.Reset(r.timeout)
+	}
+	if err != nil && r.ctx.Err() != nil {
+		return n, context.Cause(r.ctx)
+	}
+	return n, err
+}
+
+// stop releases the stall timer; the source is left open.
+func (r *installReader) stop() {
+	r.release()
+}
+
+// progressWriter counts the bytes written through it and reports them at most
+// every modelProgressInterval.
+type progressWriter struct {
+	copied   int64
+	reported time.Time
+	report   func(copied int64)
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	p.copied += int64(len(data))
+	if now := time.Now(); now.Sub(p.reported) >= modelProgressInterval {
+		p.reported = now
+		p.report(p.copied)
+	}
+	return len(data), nil
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+)
+
+// fakeModel returns the header of a tiny English-only q5_1 model followed by
+// some weights.
+func fakeModel() []byte {
+	var buf bytes.Buffer
+	for _, field := range []any{uint32(0x67676d6c), int32(51864), int32(1500), int32(384), int32(6), int32(4),
+		int32(448), int32(384), int32(6), int32(4), int32(80), int32(2009)} {
+		_ = binary.Write(&buf, binary.LittleEndian, field)
+	}
+	buf.WriteString("weights")
+	return buf.Bytes()
+}
+
+// newTestModelService searches dirs and installs into the first of them.
+func newTestModelService(t *testing.T, dirs ...string) *ModelService {
+	t.Helper()
+	service := NewModelService(&TranscribeService{
+		backends:     make(map[string]TranscriberFactory),
+		transcribers: make(map[string]Transcriber),
+		models:       make(map[string]*sharedModel),
+		sessions:     make(map[string]*TranscribeSession),
+	})
+	service.ctx = context.Background()
+	service.dirs = dirs
+	service.installDir = dirs[0]
+	service.http = &http.Client{}
+	service.stallTimeout = modelStallTimeout
+	service.installs = make(map[string]context.CancelCauseFunc)
+	service.active = "tiny.en"
+	return service
+}
+
+func writeModel(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, whisper.ModelFile(name))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestModelsListsEachModelOnce(t *testing.T) {
+	user, dev := t.TempDir(), t.TempDir()
+	writeModel(t, user, "tiny.en", fakeModel())
+	writeModel(t, dev, "tiny.en", fakeModel())
+	writeModel(t, dev, "broken", []byte("not a model"))
+	service := newTestModelService(t, user, dev)
+
+	models, err := service.Models()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 {
+		t.Fatalf("expected one model, got %+v", models)
+	}
+	model := models[0]
+	if model.Path != filepath.Join(user, "ggml-tiny.en.bin") || !model.Active {
+		t.Fatalf("expected the active model from the first directory, got %+v", model)
+	}
+	if model.Size != "tiny" || model.Multilingual || model.Quantization != "q5_1" {
+		t.Fatalf("expected an English-only tiny q5_1 model, got %+v", model)
+	}
+}
+
+func TestImportModelRecordsChecksum(t *testing.T) {
+	install, source := t.TempDir(), t.TempDir()
+	path := writeModel(t, source, "base", fakeModel())
+	service := newTestModelService(t, install)
+
+	if _, err := service.ImportModel(path, strings.Repeat("0", 64)); err == nil {
+		t.Fatal("expected a checksum mismatch to fail the import")
+	}
+	if entries, _ := os.ReadDir(install); len(entries) != 0 {
+		t.Fatalf("expected a failed import to leave nothing behind, got %d files", len(entries))
+	}
+
+	// The mirror cannot be reached, so nothing is known to verify against.
+	sum := sha256.Sum256(fakeModel())
+	model, err := service.ImportModel(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.SHA256 != hex.EncodeToString(sum[:]) || !model.Unverified {
+		t.Fatalf("expected base with its own checksum recorded as unverified, got %+v", model)
+	}
+	if check, err := service.VerifyModel("base"); err != nil || !check.Verified || check.Source != "import" {
+		t.Fatalf("expected the model to match the checksum computed on import, got %+v, %v", check, err)
+	}
+
+	model, err = service.ImportModel(path, hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.Name != "base" || model.SHA256 != hex.EncodeToString(sum[:]) || model.Unverified {
+		t.Fatalf("expected base with its checksum recorded, got %+v", model)
+	}
+
+	check, err := service.VerifyModel("base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check.Verified || check.Source != "install" {
+		t.Fatalf("expected the imported model to verify against its install, got %+v", check)
+	}
+
+	writeModel(t, install, "base", append(fakeModel(), 0))
+	if check, err := service.VerifyModel("base"); err != nil || check.Verified {
+		t.Fatalf("expected a modified model to fail verification, got %+v, %v", check, err)
+	}
+}
+
+func TestDownloadModelFromMirror(t *testing.T) {
+	sum := sha256.Sum256(fakeModel())
+	// Like Hugging Face, the mirror redirects to a CDN and publishes the
+	// checksum on the redirect.
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ggml-small.bin":
+			w.Header().Set("X-Linked-Etag", `"`+hex.EncodeToString(sum[:])+`"`)
+			http.Redirect(w, r, server.URL+"/cdn/small", http.StatusFound)
+		case "/ggml-base.bin", "/cdn/small":
+			w.Header().Set("Etag", `"not-a-checksum"`)
+			_, _ = w.Write(fakeModel())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	service := newTestModelService(t, t.TempDir())
+	service.mirror = server.URL
+
+	if _, err := service.DownloadModel("large", ""); err == nil {
+		t.Fatal("expected a missing model to fail")
+	}
+	if _, err := service.DownloadModel("base", ""); err == nil {
+		t.Fatal("expected a model without a published checksum to fail")
+	}
+	model, err := service.DownloadModel("small", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.Bytes != int64(len(fakeModel())) || model.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("expected %d bytes with their checksum, got %+v", len(fakeModel()), model)
+	}
+	if check, err := service.VerifyModel("small"); err != nil || !check.Verified || check.Source != "mirror" {
+		t.Fatalf("expected the model to verify against the mirror, got %+v, %v", check, err)
+	}
+	if _, err := service.DownloadModel("../small", ""); err == nil {
+		t.Fatal("expected a path to be rejected as a model name")
+	}
+}
+
+func TestDownloadModelStopsWhenStalledOrCancelled(t *testing.T) {
+	sum := sha256.Sum256(fakeModel())
+	// The mirror sends the start of the model, then nothing more.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(fakeModel())))
+		_, _ = w.Write(fakeModel()[:8])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	service := newTestModelService(t, dir)
+	service.mirror = server.URL
+	service.stallTimeout = 50 * time.Millisecond
+
+	if _, err := service.DownloadModel("small", hex.EncodeToString(sum[:])); !errors.Is(err, errInstallStalled) {
+		t.Fatalf("expected the download to stall, got %v", err)
+	}
+
+	service.stallTimeout = time.Minute
+	result := make(chan error, 1)
+	go func() {
+		_, err := service.DownloadModel("small", hex.EncodeToString(sum[:]))
+		result <- err
+	}()
+	for service.CancelInstall("small") != nil {
+		time.Sleep(time.Millisecond)
+	}
+	if err := <-result; !errors.Is(err, ErrInstallCancelled) {
+		t.Fatalf("expected the download to be cancelled, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected nothing left of the download, got %d files", len(entries))
+	}
+}
+
+func TestUseModelSwapsBackendBetweenSessions(t *testing.T) {
+	dir := t.TempDir()
+	writeModel(t, dir, "base", fakeModel())
+	service := newTestModelService(t, dir)
+	fake := newFakeTranscriber()
+	service.transcribe.transcribers[DefaultBackend] = fake
+
+	service.transcribe.sessions["running"] = &TranscribeSession{}
+	if err := service.UseModel("base"); err == nil {
+		t.Fatal("expected switching models during a session to fail")
+	}
+
+	delete(service.transcribe.sessions, "running")
+	if err := service.UseModel("base"); err != nil {
+		t.Fatal(err)
+	}
+	if !fake.closed {
+		t.Fatal("expected the previous model to be closed")
+	}
+	if _, ok := service.transcribe.transcribers[DefaultBackend]; ok {
+		t.Fatal("expected the backend to load again on the next session")
+	}
+	if service.active != "base" {
+		t.Fatalf("expected base to be active, got %q", service.active)
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import "sync"
+
+// resultOrder delivers the results of parallel workers. Finals, annotations
+// included, are delivered one at a time in the order they were queued, since
+// overlap trimming and prompt carry-over depend on the previous final. A
+// partial is delivered as soon as it is ready unless its utterance has
+// already been finalized.
+type resultOrder struct {
+	mu sync.Mutex
+	// next is the sequence number of the next final to deliver.
+	next int64
+	// pending holds finals that finished ahead of an earlier one.
+	pending map[int64]pendingFinal
+	// finalizedUtterance is the highest utterance whose final was delivered.
+	finalizedUtterance int64
+}
+
+// pendingFinal is a final waiting for its turn.
+type pendingFinal struct {
+	utteranceID int64
+	deliver     func()
+}
+
+func newResultOrder() *resultOrder {
+	return &resultOrder{next: 1, pending: make(map[int64]pendingFinal)}
+}
+
+// final queues the delivery of the final with the given sequence number and
+// runs every final that is now in order. Sequence numbers start at 1 and must
+// not skip any.
+func (o *resultOrder) final(sequence, utteranceID int64, deliver func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.pending[sequence] = pendingFinal{utteranceID: utteranceID, deliver: deliver}
+	for {
+		final, ok := o.pending[o.next]
+		if !ok {
+			return
+		}
+		delete(o.pending, o.next)
+		o.next++
+
+		o.finalizedUtterance = max(o.finalizedUtterance, final.utteranceID)
+		final.deliver()
+	}
+}
+
+// partial delivers a partial unless its utterance was finalized while it was
+// being transcribed, and reports whether it did.
+func (o *resultOrder) partial(utteranceID int64, deliver func()) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if utteranceID <= o.finalizedUtterance {
+		return false
+	}
+	deliver()
+	return true
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResultOrderDeliversFinalsInSequence(t *testing.T) {
+	order := newResultOrder()
+	var delivered []int64
+	deliver := func(sequence int64) func() {
+		return func() { delivered = append(delivered, sequence) }
+	}
+
+	order.final(2, 2, deliver(2))
+	order.final(3, 3, deliver(3))
+	if len(delivered) != 0 {
+		t.Fatalf("expected finals to wait for the first, got %v", delivered)
+	}
+
+	order.final(1, 1, deliver(1))
+	if !slices.Equal(delivered, []int64{1, 2, 3}) {
+		t.Fatalf("expected finals in sequence, got %v", delivered)
+	}
+}
+
+func TestResultOrderDiscardsLatePartials(t *testing.T) {
+	order := newResultOrder()
+	order.final(1, 4, func() {})
+
+	if order.partial(4, func() { t.Fatal("expected a partial of a finalized utterance to be dropped") }) {
+		t.Fatal("expected the late partial to be reported as dropped")
+	}
+
+	delivered := false
+	if !order.partial(5, func() { delivered = true }) || !delivered {
+		t.Fatal("expected a partial of the next utterance to be delivered")
+	}
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+)
+
+const (
+	// overlapTolerance absorbs the jitter of whisper token timestamps at the end of the overlap.
+	overlapTolerance = 100 * time.Millisecond
+	// maxOverlapWords bounds how many boundary words the text fallback compares.
+	maxOverlapWords = 8
+	// minFragmentLength is the shortest normalized word fragment treated as a cut-off word.
+	minFragmentLength = 3
+)
+
+// word is a whitespace-delimited word of a segment together with the tokens that spell it.
+type word struct {
+	text   string
+	end    time.Duration
+	tokens []whisper.Token
+}
+
+// trimOverlap removes the leading words of a final chunk that repeat audio the
+// previous final already transcribed. Token timestamps decide which words lie in
+// the overlap; without them, the boundary words of both texts are compared.
+func trimOverlap(previousText string, overlap time.Duration, segments []whisper.Segment) []whisper.Segment {
+	if overlap <= 0 || len(segments) == 0 {
+		return segments
+	}
+
+	if hasTokenTimestamps(segments) {
+		return dropWordsEndingBefore(segments, overlap+overlapTolerance)
+	}
+
+	return dropLeadingWords(segments, repeatedWordCount(previousText, whisper.CombineSegments(segments)))
+}
+
+// hasTokenTimestamps reports whether whisper returned usable token timings.
+func hasTokenTimestamps(segments []whisper.Segment) bool {
+	for _, segment := range segments {
+		for _, token := range segment.Tokens {
+			if token.End > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dropWordsEndingBefore removes leading words that end before cutoff. A word
+// that starts in the overlap but ends after it was cut off in the previous final,
+// so it is kept whole.
+func dropWordsEndingBefore(segments []whisper.Segment, cutoff time.Duration) []whisper.Segment {
+	var result []whisper.Segment
+	trimming := true
+	for _, segment := range segments {
+		if !trimming {
+			result = append(result, segment)
+			continue
+		}
+
+		words := segmentWords(segment)
+		kept := 0
+		for kept < len(words) && words[kept].end <= cutoff {
+			kept++
+		}
+		if kept < len(words) {
+			trimming = false
+		}
+		if rebuilt, ok := rebuildSegment(segment, words, kept); ok {
+			result = append(result, rebuilt)
+		}
+	}
+	return result
+}
+
+// dropLeadingWords removes the first count words across segments.
+func dropLeadingWords(segments []whisper.Segment, count int) []whisper.Segment {
+	if count <= 0 {
+		return segments
+	}
+
+	var result []whisper.Segment
+	for _, segment := range segments {
+		words := segmentWords(segment)
+		drop := min(count, len(words))
+		count -= drop
+		if rebuilt, ok := rebuildSegment(segment, words, drop); ok {
+			result = append(result, rebuilt)
+		}
+	}
+	return result
+}
+
+// segmentWords splits a segment into words, grouping tokens when whisper returned them.
+func segmentWords(segment whisper.Segment) []word {
+	if len(segment.Tokens) == 0 {
+		var words []word
+		for _, field := range strings.Fields(segment.Text) {
+			words = append(words, word{text: " " + field, end: segment.End})
+		}
+		return words
+	}
+
+	var words []word
+	for _, token := range segment.Tokens {
+		if len(words) == 0 || strings.HasPrefix(token.Text, " ") {
+			words = append(words, word{})
+		}
+		current := &words[len(words)-1]
+		current.text += token.Text
+		current.end = token.End
+		current.tokens = append(current.tokens, token)
+	}
+	return words
+}
+
+// rebuildSegment drops the first drop of a segment's words from its text and
+// tokens. The text is cut from the segment's own, which keeps the punctuation
+// and casing that tokens may lack, such as a remote server's bare words; only
+// when its words do not line up with the tokens is it rebuilt from them.
+func rebuildSegment(segment whisper.Segment, words []word, drop int) (whisper.Segment, bool) {
+	if drop == 0 {
+		return segment, strings.TrimSpace(segment.Text) != ""
+	}
+
+	var rebuilt strings.Builder
+	var tokens []whisper.Token
+	for _, w := range words[drop:] {
+		rebuilt.WriteString(w.text)
+		tokens = append(tokens, w.tokens...)
+	}
+
+	if len(strings.Fields(segment.Text)) == len(words) {
+		segment.Text = skipWords(segment.Text, drop)
+	} else {
+		segment.Text = strings.TrimSpace(rebuilt.String())
+	}
+	if segment.Text == "" {
+		return whisper.Segment{}, false
+	}
+	segment.Tokens = tokens
+	if len(tokens) > 0 {
+		segment.Start = tokens[0].Start
+	}
+	return segment, true
+}
+
+// skipWords returns text without its first count whitespace-delimited words.
+func skipWords(text string, count int) string {
+	text = strings.TrimSpace(text)
+	for range count {
+		end := strings.IndexFunc(text, unicode.IsSpace)
+		if end < 0 {
+			return ""
+		}
+		text = strings.TrimLeftFunc(text[end:], unicode.IsSpace)
+	}
+	return text
+}
+
+// repeatedWordCount returns how many leading words of next repeat the end of
+// previous. The first word of next may be the tail of a word cut at the start of
+// the overlap and is dropped; the last word of previous may be cut short, in
+// which case the complete word in next is kept.
+func repeatedWordCount(previous, next string) int {
+	tail := strings.Fields(previous)
+	head := strings.Fields(next)
+	tail = tail[max(len(tail)-maxOverlapWords, 0):]
+	head = head[:min(len(head), maxOverlapWords)]
+
+	for count := min(len(tail), len(head)); count > 0; count-- {
+		if drop, ok := matchBoundary(tail[len(tail)-count:], head[:count]); ok {
+			return drop
+		}
+	}
+	return 0
+}
+
+// matchBoundary compares aligned boundary words and returns how many to drop.
+func matchBoundary(tail, head []string) (int, bool) {
+	drop := len(head)
+	for i := range head {
+		previous, next := normalizeWord(tail[i]), normalizeWord(head[i])
+		switch {
+		case similarWords(previous, next):
+		case i == 0 && isFragment(next, previous, strings.HasSuffix):
+		case i == len(head)-1 && isFragment(previous, next, strings.HasPrefix):
+			drop--
+		default:
+			return 0, false
+		}
+	}
+	return drop, true
+}
+
+// isFragment reports whether fragment is a cut-off part of full.
+func isFragment(fragment, full string, match func(s, part string) bool) bool {
+	return len(fragment) >= minFragmentLength && len(fragment) < len(full) && match(full, fragment)
+}
+
+// similarWords tolerates a single-character recognition difference in longer words.
+func similarWords(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if min(len(a), len(b)) < 4 {
+		return false
+	}
+	return editDistance(a, b) <= 1
+}
+
+// normalizeWord lowercases a word and strips punctuation before comparison.
+func normalizeWord(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, text)
+}
+
+// editDistance returns the Levenshtein distance between two words.
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	row := make([]int, len(br)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		diagonal := row[0]
+		row[0] = i
+		for j := 1; j <= len(br); j++ {
+			substitution := diagonal
+			if ar[i-1] != br[j-1] {
+				substitution++
+			}
+			diagonal = row[j]
+			row[j] = min(row[j]+1, row[j-1]+1, substitution)
+		}
+	}
+	return row[len(br)]
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+)
+
+func TestTrimOverlapDropsDuplicatedTimedWords(t *testing.T) {
+	segments := []whisper.Segment{{
+		Text: "the meeting starts now",
+		Tokens: []whisper.Token{
+			{Text: " the", Start: 0, End: 150 * time.Millisecond},
+			{Text: " meet", Start: 150 * time.Millisecond, End: 300 * time.Millisecond},
+			{Text: "ing", Start: 300 * time.Millisecond, End: 420 * time.Millisecond},
+			{Text: " starts", Start: 600 * time.Millisecond, End: 900 * time.Millisecond},
+			{Text: " now", Start: 900 * time.Millisecond, End: 1100 * time.Millisecond},
+		},
+	}}
+
+	trimmed := trimOverlap("so the meeting", 500*time.Millisecond, segments)
+	if text := whisper.CombineSegments(trimmed); text != "starts now" {
+		t.Fatalf("expected overlap words to be dropped, got %q", text)
+	}
+	if trimmed[0].Start != 600*time.Millisecond {
+		t.Fatalf("expected segment to start at first kept word, got %s", trimmed[0].Start)
+	}
+}
+
+func TestTrimOverlapKeepsWordTruncatedByPreviousFinal(t *testing.T) {
+	segments := []whisper.Segment{{
+		Text: "sub transcription works",
+		Tokens: []whisper.Token{
+			{Text: " sub", Start: 0, End: 200 * time.Millisecond},
+			{Text: " trans", Start: 300 * time.Millisecond, End: 450 * time.Millisecond},
+			{Text: "cription", Start: 450 * time.Millisecond, End: 800 * time.Millisecond},
+			{Text: " works", Start: 800 * time.Millisecond, End: 1000 * time.Millisecond},
+		},
+	}}
+
+	trimmed := trimOverlap("live sub transcri", 500*time.Millisecond, segments)
+	if text := whisper.CombineSegments(trimmed); text != "transcription works" {
+		t.Fatalf("expected the word crossing the boundary to be kept whole, got %q", text)
+	}
+}
+
+func TestTrimOverlapKeepsPunctuationOfBareWordTokens(t *testing.T) {
+	// A remote server's words carry neither punctuation nor casing.
+	segments := []whisper.Segment{{
+		Text: "Good morning. Let's begin, shall we?",
+		Tokens: []whisper.Token{
+			{Text: " good", Start: 0, End: 200 * time.Millisecond},
+			{Text: " morning", Start: 200 * time.Millisecond, End: 450 * time.Millisecond},
+			{Text: " lets", Start: 700 * time.Millisecond, End: 900 * time.Millisecond},
+			{Text: " begin", Start: 900 * time.Millisecond, End: 1200 * time.Millisecond},
+			{Text: " shall", Start: 1300 * time.Millisecond, End: 1500 * time.Millisecond},
+			{Text: " we", Start: 1500 * time.Millisecond, End: 1700 * time.Millisecond},
+		},
+	}}
+
+	trimmed := trimOverlap("said good morning", 500*time.Millisecond, segments)
+	if text := whisper.CombineSegments(trimmed); text != "Let's begin, shall we?" {
+		t.Fatalf("expected the segment's own text past the overlap, got %q", text)
+	}
+	if len(trimmed[0].Tokens) != 4 {
+		t.Fatalf("expected the kept words' tokens, got %+v", trimmed[0].Tokens)
+	}
+}
+
+func TestTrimOverlapFallsBackToTextForDuplicatedWords(t *testing.T) {
+	segments := []whisper.Segment{
+		{Text: "next quarter."},
+		{Text: "Revenue grew."},
+	}
+
+	trimmed := trimOverlap("We will review the plan for next quarter", 500*time.Millisecond, segments)
+	if text := whisper.CombineSegments(trimmed); text != "Revenue grew." {
+		t.Fatalf("expected repeated words to be dropped, got %q", text)
+	}
+}
+
+func TestTrimOverlapFallsBackToTextForTruncatedWords(t *testing.T) {
+	segments := []whisper.Segment{{Text: "ning the deployment pipeline"}}
+	trimmed := trimOverlap("we are planning the", 500*time.Millisecond, segments)
+	if text := whisper.CombineSegments(trimmed); text != "deployment pipeline" {
+		t.Fatalf("expected leading word fragment and repeat to be dropped, got %q", text)
+	}
+
+	segments = []whisper.Segment{{Text: "the deployment pipeline"}}
+	trimmed = trimOverlap("check the deploy", 500*time.Millisecond, segments)
+	if text := whisper.CombineSegments(trimmed); text != "deployment pipeline" {
+		t.Fatalf("expected the complete form of a truncated word to be kept, got %q", text)
+	}
+}
+
+func TestTrimOverlapIgnoresChunksWithoutOverlap(t *testing.T) {
+	segments := []whisper.Segment{{Text: "so what now"}}
+
+	trimmed := trimOverlap("I think so", 0, segments)
+	if text := whisper.CombineSegments(trimmed); text != "so what now" {
+		t.Fatalf("expected text without overlap to be unchanged, got %q", text)
+	}
+}
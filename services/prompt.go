@@ -0,0 +1,143 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// maxPromptContext is the number of characters of previous finals fed back
+	// as context. Whisper keeps at most 224 prompt tokens; long prompts also make
+	// it more likely to continue the prompt instead of transcribing.
+	maxPromptContext = 200
+	// maxPromptGlossary is the number of characters of glossary terms in a prompt.
+	maxPromptGlossary = 400
+	// maxLoopPhrase is the longest phrase, in words, checked for repetition loops.
+	maxLoopPhrase = 8
+	// minLoopRepeats is the number of back-to-back copies of a phrase treated as a loop.
+	minLoopRepeats = 3
+	// minWordLoopRepeats is the number of back-to-back copies of a single word
+	// treated as a loop. People do say "no no no" or "bye bye bye".
+	minWordLoopRepeats = 6
+)
+
+// promptContext builds the initial prompt of each chunk from the session
+// glossary and the tail of previous finals.
+type promptContext struct {
+	mu sync.Mutex
+	// glossary is the joined glossary terms, already truncated.
+	glossary string
+	// tail is the end of the recent final transcripts.
+	tail string
+}
+
+// newPromptContext prepares the glossary part of every prompt.
+// Terms past maxPromptGlossary are left out.
+func newPromptContext(terms []string) *promptContext {
+	glossary := ""
+	for _, term := range cleanTerms(terms) {
+		next := term
+		if glossary != "" {
+			next = glossary + ", " + term
+		}
+		if len(next) > maxPromptGlossary {
+			break
+		}
+		glossary = next
+	}
+	if glossary != "" {
+		glossary += "."
+	}
+	return &promptContext{glossary: glossary}
+}
+
+// restart returns a context with the same glossary and no previous finals.
+func (p *promptContext) restart() *promptContext {
+	return &promptContext{glossary: p.glossary}
+}
+
+// prompt returns the initial prompt for the next chunk.
+func (p *promptContext) prompt() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return strings.TrimSpace(p.glossary + " " + p.tail)
+}
+
+// observeFinal adds a final transcript to the carried context. A final that
+// looped is not carried over and clears the context, since a prompt that
+// contains a loop tends to make whisper repeat it in the next chunk.
+func (p *promptContext) observeFinal(text string, looped bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if looped {
+		p.tail = ""
+		return
+	}
+	p.tail = lastCharacters(strings.TrimSpace(p.tail+" "+text), maxPromptContext)
+}
+
+// lastCharacters returns the end of text, at most limit bytes long, starting at a word.
+func lastCharacters(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+
+	text = text[len(text)-limit:]
+	for !utf8.RuneStart(text[0]) {
+		text = text[1:]
+	}
+	if space := strings.IndexByte(text, ' '); space >= 0 {
+		text = text[space+1:]
+	}
+	return text
+}
+
+// collapseRepetitions removes back-to-back copies of a phrase beyond the first
+// when the phrase appears at least minLoopRepeats times in a row, or a single
+// word minWordLoopRepeats times, which is how whisper's decoding loops show up.
+// It reports whether a loop was found.
+func collapseRepetitions(text string) (string, bool) {
+	words := strings.Fields(text)
+	looped := false
+
+	for size := 1; size <= maxLoopPhrase; size++ {
+		minRepeats := minLoopRepeats
+		if size == 1 {
+			minRepeats = minWordLoopRepeats
+		}
+		var kept []string
+		for i := 0; i < len(words); {
+			repeats := 1
+			for i+(repeats+1)*size <= len(words) && samePhrase(words[i:i+size], words[i+repeats*size:i+(repeats+1)*size]) {
+				repeats++
+			}
+			if repeats >= minRepeats {
+				kept = append(kept, words[i:i+size]...)
+				i += repeats * size
+				looped = true
+				continue
+			}
+			kept = append(kept, words[i])
+			i++
+		}
+		words = kept
+	}
+
+	if !looped {
+		return text, false
+	}
+	return strings.Join(words, " "), true
+}
+
+// samePhrase compares two phrases word by word, ignoring case and punctuation.
+func samePhrase(a, b []string) bool {
+	for i := range a {
+		if normalizeWord(a[i]) != normalizeWord(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCollapseRepetitionsRemovesLoops(t *testing.T) {
+	text, looped := collapseRepetitions("Thanks for watching. Thanks for watching. thanks for watching! Bye.")
+	if !looped {
+		t.Fatal("expected a repeated phrase to be reported as a loop")
+	}
+	if text != "Thanks for watching. Bye." {
+		t.Fatalf("expected the loop collapsed to one copy, got %q", text)
+	}
+
+	for _, speech := range []string{"no no, that is that", "No no no, not that one.", "Bye bye bye!"} {
+		if text, looped := collapseRepetitions(speech); looped || text != speech {
+			t.Fatalf("expected ordinary speech to be kept, got %q, %v", text, looped)
+		}
+	}
+
+	text, looped = collapseRepetitions("Go go go go go go go go.")
+	if !looped || text != "Go" {
+		t.Fatalf("expected a word repeated on and on to be collapsed, got %q, %v", text, looped)
+	}
+}
+
+func TestPromptContextCarriesRecentFinals(t *testing.T) {
+	prompt := newPromptContext([]string{" Ekko ", "", "whisper.cpp", "Ekko"})
+	if got := prompt.prompt(); got != "Ekko, whisper.cpp." {
+		t.Fatalf("expected the cleaned glossary, got %q", got)
+	}
+
+	prompt.observeFinal("We shipped the release.", false)
+	if got := prompt.prompt(); got != "Ekko, whisper.cpp. We shipped the release." {
+		t.Fatalf("expected the glossary followed by the last final, got %q", got)
+	}
+
+	prompt.observeFinal(strings.Repeat("word ", 100), false)
+	tail := strings.TrimPrefix(prompt.prompt(), "Ekko, whisper.cpp. ")
+	if len(tail) > maxPromptContext || !strings.HasPrefix(tail, "word") {
+		t.Fatalf("expected at most %d characters starting at a word, got %q", maxPromptContext, tail)
+	}
+
+	prompt.observeFinal("again again again", true)
+	if got := prompt.prompt(); got != "Ekko, whisper.cpp." {
+		t.Fatalf("expected a looped final to clear the carried context, got %q", got)
+	}
+}
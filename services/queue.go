@@ -0,0 +1,152 @@
+package services
+
+import (
+	"slices"
+	"sync"
+)
+
+// jobQueue holds a session's jobs until a worker is free. Finals and
+// annotations always go first, in the order they were queued. Of the partials,
+// only the newest of each utterance is kept, and those of an utterance are
+// discarded once its final is queued, so that workers never spend time on text
+// that is already out of date.
+type jobQueue struct {
+	mu    sync.Mutex
+	ready *sync.Cond
+	// finals are the queued finals and annotations, oldest first.
+	finals []Job
+	// partials hold at most one partial per utterance, oldest utterance first.
+	partials []Job
+	// finalizedUtterance is the highest utterance whose final was queued.
+	finalizedUtterance int64
+	// discarded counts the partials dropped before a worker took them.
+	discarded int64
+	closed    bool
+}
+
+// jobKinds selects the jobs a worker takes from a jobQueue.
+type jobKinds uint8
+
+const (
+	// finalJobs are finals and annotations.
+	finalJobs jobKinds = 1 << iota
+	partialJobs
+	allJobs = finalJobs | partialJobs
+)
+
+func newJobQueue() *jobQueue {
+	q := &jobQueue{}
+	q.ready = sync.NewCond(&q.mu)
+	return q
+}
+
+// push queues a job. It never blocks.
+func (q *jobQueue) push(job Job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if job.Chunk.Final {
+		q.finals = append(q.finals, job)
+		if job.Chunk.Annotation == "" {
+			q.finalizedUtterance = max(q.finalizedUtterance, job.Chunk.UtteranceID)
+			q.partials = q.discard(q.partials, func(partial Job) bool {
+				return partial.Chunk.UtteranceID <= job.Chunk.UtteranceID
+			})
+		}
+		q.ready.Broadcast()
+		return
+	}
+
+	if job.Chunk.UtteranceID <= q.finalizedUtterance {
+		q.discard([]Job{job}, func(Job) bool { return true })
+		return
+	}
+	q.partials = q.discard(q.partials, func(partial Job) bool {
+		return partial.Chunk.UtteranceID == job.Chunk.UtteranceID
+	})
+	q.partials = append(q.partials, job)
+	q.ready.Broadcast()
+}
+
+// discard removes the jobs matching stale, releasing their samples, and
+// returns the rest. It must be called with q.mu held.
+func (q *jobQueue) discard(jobs []Job, stale func(Job) bool) []Job {
+	return slices.DeleteFunc(jobs, func(job Job) bool {
+		if !stale(job) {
+			return false
+		}
+		job.Chunk.Release()
+		q.discarded++
+		return true
+	})
+}
+
+// pop waits for the next job of the given kinds. It returns false once the
+// queue is closed and every such job has been taken.
+func (q *jobQueue) pop(kinds jobKinds) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	takeFinals := kinds&finalJobs != 0 && len(q.finals) > 0
+	takePartials := kinds&partialJobs != 0 && len(q.partials) > 0
+	for !takeFinals && !takePartials {
+		if q.closed {
+			return Job{}, false
+		}
+		q.ready.Wait()
+		takeFinals = kinds&finalJobs != 0 && len(q.finals) > 0
+		takePartials = kinds&partialJobs != 0 && len(q.partials) > 0
+	}
+
+	if takeFinals {
+		job := q.finals[0]
+		q.finals = slices.Delete(q.finals, 0, 1)
+		return job, true
+	}
+	job := q.partials[0]
+	q.partials = slices.Delete(q.partials, 0, 1)
+	return job, true
+}
+
+// close stops pop from waiting once the queue is empty. Jobs already queued
+// are still handed out.
+func (q *jobQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.ready.Broadcast()
+}
+
+// stats reports the queued and discarded jobs.
+func (q *jobQueue) stats() SessionStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return SessionStats{
+		QueueDepth:        len(q.finals) + len(q.partials),
+		QueuedFinals:      len(q.finals),
+		DiscardedPartials: q.discarded,
+	}
+}
+
+// SessionStats describe the transcription backlog and models of a running session.
+type SessionStats struct {
+	// QueueDepth is the number of jobs waiting for a worker. Partials count at
+	// most once per utterance.
+	QueueDepth int `json:"queueDepth"`
+	// QueuedFinals is how many of them are finals or annotations.
+	QueuedFinals int `json:"queuedFinals"`
+	// DiscardedPartials counts the partials dropped unprocessed because a newer
+	// partial or the final of their utterance was queued.
+	DiscardedPartials int64 `json:"discardedPartials"`
+	// PartialModelBytes and FinalModelBytes estimate the memory taken by the
+	// models transcribing partials and finals. They are the same model unless
+	// the session picked separate ones, and zero for remote backends.
+	PartialModelBytes int64 `json:"partialModelBytes"`
+	FinalModelBytes   int64 `json:"finalModelBytes"`
+	// RealTimeFactor is the smoothed time transcription takes over the
+	// duration of the audio, shared among the workers. Above 1 the session
+	// falls behind.
+	RealTimeFactor float64 `json:"realTimeFactor"`
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/tuanta7/ekko/services/chunker"
+)
+
+func queueJob(id, utteranceID int64, final bool) Job {
+	return Job{ID: id, Chunk: chunker.AudioChunk{UtteranceID: utteranceID, Final: final}}
+}
+
+// popAll takes every queued job and returns their IDs in order.
+func popAll(queue *jobQueue) []int64 {
+	queue.close()
+	var ids []int64
+	for {
+		job, ok := queue.pop(allJobs)
+		if !ok {
+			return ids
+		}
+		ids = append(ids, job.ID)
+	}
+}
+
+func TestJobQueueFavorsFinals(t *testing.T) {
+	queue := newJobQueue()
+	queue.push(queueJob(1, 1, false))
+	queue.push(queueJob(2, 1, true))
+	queue.push(queueJob(3, 2, false))
+	queue.push(queueJob(4, 2, true))
+
+	if ids := popAll(queue); !slices.Equal(ids, []int64{2, 4}) {
+		t.Fatalf("expected only the finals in order, got %v", ids)
+	}
+}
+
+func TestJobQueueKeepsNewestPartialPerUtterance(t *testing.T) {
+	queue := newJobQueue()
+	queue.push(queueJob(1, 1, false))
+	queue.push(queueJob(2, 2, false))
+	queue.push(queueJob(3, 1, false))
+	queue.push(queueJob(4, 1, false))
+
+	stats := queue.stats()
+	if stats.QueueDepth != 2 || stats.QueuedFinals != 0 || stats.DiscardedPartials != 2 {
+		t.Fatalf("expected 2 queued and 2 discarded partials, got %+v", stats)
+	}
+	if ids := popAll(queue); !slices.Equal(ids, []int64{2, 4}) {
+		t.Fatalf("expected the newest partial of each utterance, got %v", ids)
+	}
+}
+
+func TestJobQueueDiscardsPartialsOfFinalizedUtterances(t *testing.T) {
+	queue := newJobQueue()
+	queue.push(queueJob(1, 1, false))
+	queue.push(queueJob(2, 2, false))
+	queue.push(queueJob(3, 1, true))
+	queue.push(queueJob(4, 1, false))
+
+	if stats := queue.stats(); stats.DiscardedPartials != 2 {
+		t.Fatalf("expected 2 discarded partials, got %+v", stats)
+	}
+	if ids := popAll(queue); !slices.Equal(ids, []int64{3, 2}) {
+		t.Fatalf("expected the final and the next utterance's partial, got %v", ids)
+	}
+}
+
+func TestJobQueueKeepsPartialsAcrossAnnotations(t *testing.T) {
+	queue := newJobQueue()
+	queue.push(queueJob(1, 3, false))
+	annotation := queueJob(2, 2, true)
+	annotation.Chunk.Annotation = chunker.SoundMusic
+	queue.push(annotation)
+
+	if ids := popAll(queue); !slices.Equal(ids, []int64{2, 1}) {
+		t.Fatalf("expected the annotation then the partial, got %v", ids)
+	}
+}
+
+func TestJobQueuePopsRequestedKinds(t *testing.T) {
+	queue := newJobQueue()
+	queue.push(queueJob(1, 1, true))
+	queue.push(queueJob(2, 2, false))
+
+	if job, ok := queue.pop(partialJobs); !ok || job.ID != 2 {
+		t.Fatalf("expected the partial ahead of the final, got %+v", job)
+	}
+	queue.close()
+	if job, ok := queue.pop(partialJobs); ok {
+		t.Fatalf("expected no partials left, got %+v", job)
+	}
+	if job, ok := queue.pop(finalJobs); !ok || job.ID != 1 {
+		t.Fatalf("expected the final, got %+v", job)
+	}
+}
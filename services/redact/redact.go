@@ -0,0 +1,206 @@
+// Package redact finds personal data such as card numbers, email addresses,
+// phone numbers and national IDs in text and masks, hashes or drops it.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Mode is what a Redactor puts in place of what it finds.
+type Mode string
+
+const (
+	// ModeMask replaces a match with its type, as in "[EMAIL]".
+	ModeMask Mode = "mask"
+	// ModeHash replaces a match with its type and a keyed hash, as in
+	// "[EMAIL:3f9a61c2]", so that repeated mentions can be told apart and
+	// matched without revealing them.
+	ModeHash Mode = "hash"
+	// ModeDrop removes a match.
+	ModeDrop Mode = "drop"
+)
+
+// Built-in detector names.
+const (
+	Card       = "card"
+	Email      = "email"
+	Phone      = "phone"
+	NationalID = "national-id"
+)
+
+// Detector finds one type of personal data.
+type Detector struct {
+	// Name is the type of data found, used in masks and reports.
+	Name    string
+	Pattern *regexp.Regexp
+	// Valid, when set, checks a match further, such as a card's checksum.
+	// Matches it rejects are kept.
+	Valid func(match string) bool
+}
+
+// builtins are the built-in detectors, in the order they run. Cards run first
+// so that their digits are not taken for phone numbers.
+var builtins = []Detector{
+	{Name: Card, Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), Valid: luhn},
+	// US social security and UK national insurance numbers.
+	{Name: NationalID, Pattern: regexp.MustCompile(
+		`\b\d{3}-\d{2}-\d{4}\b|\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`)},
+	{Name: Email, Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	{Name: Phone, Pattern: regexp.MustCompile(
+		`(?:\+\d{1,3}[ .-]?)?(?:\(\d{2,4}\)|\b\d{2,4})[ .-]?\d{3,4}[ .-]?\d{3,4}\b`)},
+}
+
+// Builtin returns the named built-in detector.
+func Builtin(name string) (Detector, bool) {
+	for _, detector := range builtins {
+		if detector.Name == name {
+			return detector, true
+		}
+	}
+	return Detector{}, false
+}
+
+// Builtins returns the names of the built-in detectors.
+func Builtins() []string {
+	names := make([]string, len(builtins))
+	for i, detector := range builtins {
+		names[i] = detector.Name
+	}
+	return names
+}
+
+// Pattern returns a detector of a user-defined regular expression.
+func Pattern(name, expression string) (Detector, error) {
+	pattern, err := regexp.Compile(expression)
+	if err != nil {
+		return Detector{}, fmt.Errorf("pattern %s: %w", name, err)
+	}
+	return Detector{Name: name, Pattern: pattern}, nil
+}
+
+// Redactor applies detectors to text. It is safe for concurrent use.
+type Redactor struct {
+	detectors []Detector
+	mode      Mode
+	// key keys the hashes, so that they cannot be reversed by hashing every
+	// possible card or phone number.
+	key []byte
+}
+
+// New returns a Redactor running detectors in order. Hashes are keyed with a
+// random key, so they only match within the Redactor's lifetime.
+func New(mode Mode, detectors ...Detector) (*Redactor, error) {
+	switch mode {
+	case ModeMask, ModeHash, ModeDrop:
+	default:
+		return nil, fmt.Errorf("unknown redaction mode %q", mode)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &Redactor{detectors: detectors, mode: mode, key: key}, nil
+}
+
+// Redact returns text with the data the detectors find replaced, and how many
+// matches of each type it replaced. A nil Redactor returns text unchanged.
+//
+// Every detector searches the original text and all matches are replaced in
+// one pass, so that a detector never sees another's replacement, such as the
+// digits of a hash. Where matches overlap, the earlier detector's wins.
+func (r *Redactor) Redact(text string) (string, map[string]int) {
+	if r == nil {
+		return text, nil
+	}
+
+	var found []match
+	for _, detector := range r.detectors {
+		for _, loc := range detector.Pattern.FindAllStringIndex(text, -1) {
+			candidate := match{start: loc[0], end: loc[1], name: detector.Name}
+			if candidate.start == candidate.end || overlaps(found, candidate) {
+				continue
+			}
+			if detector.Valid != nil && !detector.Valid(text[candidate.start:candidate.end]) {
+				continue
+			}
+			found = append(found, candidate)
+		}
+	}
+	if len(found) == 0 {
+		return text, nil
+	}
+	slices.SortFunc(found, func(a, b match) int { return a.start - b.start })
+
+	var redacted strings.Builder
+	counts := make(map[string]int)
+	last := 0
+	for _, m := range found {
+		redacted.WriteString(text[last:m.start])
+		redacted.WriteString(r.replacement(m.name, text[m.start:m.end]))
+		counts[m.name]++
+		last = m.end
+	}
+	redacted.WriteString(text[last:])
+
+	text = redacted.String()
+	if r.mode == ModeDrop {
+		text = strings.Join(strings.Fields(text), " ")
+	}
+	return text, counts
+}
+
+// match is where a detector found data in the original text.
+type match struct {
+	start, end int
+	name       string
+}
+
+// overlaps reports whether candidate shares any text with a match already found.
+func overlaps(found []match, candidate match) bool {
+	return slices.ContainsFunc(found, func(m match) bool {
+		return candidate.start < m.end && m.start < candidate.end
+	})
+}
+
+func (r *Redactor) replacement(name, match string) string {
+	label := strings.ToUpper(name)
+	switch r.mode {
+	case ModeHash:
+		mac := hmac.New(sha256.New, r.key)
+		mac.Write([]byte(match))
+		return "[" + label + ":" + hex.EncodeToString(mac.Sum(nil))[:8] + "]"
+	case ModeDrop:
+		return ""
+	default:
+		return "[" + label + "]"
+	}
+}
+
+// luhn reports whether the digits of a match pass the Luhn checksum card
+// numbers carry.
+func luhn(match string) bool {
+	sum, digits := 0, 0
+	for i := len(match) - 1; i >= 0; i-- {
+		c := match[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if digits%2 == 1 {
+			if digit *= 2; digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		digits++
+	}
+	return digits >= 13 && digits <= 19 && sum%10 == 0
+}
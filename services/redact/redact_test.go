@@ -0,0 +1,103 @@
+package redact
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func allBuiltins(t *testing.T) []Detector {
+	t.Helper()
+	var detectors []Detector
+	for _, name := range Builtins() {
+		detector, _ := Builtin(name)
+		detectors = append(detectors, detector)
+	}
+	return detectors
+}
+
+func TestRedactMasksBuiltinTypes(t *testing.T) {
+	redactor, err := New(ModeMask, allBuiltins(t)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, counts := redactor.Redact("Card 4111 1111 1111 1111, mail jo.doe@example.com or call +1 415 555 0100. " +
+		"SSN 123-45-6789, NI AB 12 34 56 C. Order 12-3456 stays.")
+	expected := "Card [CARD], mail [EMAIL] or call [PHONE]. SSN [NATIONAL-ID], NI [NATIONAL-ID]. Order 12-3456 stays."
+	if text != expected {
+		t.Fatalf("expected %q, got %q", expected, text)
+	}
+	if want := map[string]int{Card: 1, Email: 1, Phone: 1, NationalID: 2}; !maps.Equal(counts, want) {
+		t.Fatalf("expected counts %v, got %v", want, counts)
+	}
+}
+
+func TestLuhnChecksCardNumbers(t *testing.T) {
+	if !luhn("4111-1111-1111-1111") || !luhn("378282246310005") {
+		t.Fatal("expected valid card numbers to pass")
+	}
+	if luhn("4111 1111 1111 1112") || luhn("0000 0000 00") {
+		t.Fatal("expected a wrong checksum and a short number to fail")
+	}
+}
+
+func TestRedactHashesConsistently(t *testing.T) {
+	email, _ := Builtin(Email)
+	redactor, err := New(ModeHash, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := redactor.Redact("a@example.com")
+	second, _ := redactor.Redact("write to a@example.com")
+	other, _ := redactor.Redact("b@example.com")
+	if !strings.HasPrefix(first, "[EMAIL:") || !strings.HasSuffix(second, first) || other == first {
+		t.Fatalf("expected the same address to hash the same, got %q, %q and %q", first, second, other)
+	}
+}
+
+func TestRedactLeavesHashesOfOtherDetectorsAlone(t *testing.T) {
+	redactor, err := New(ModeHash, allBuiltins(t)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Find an address whose hash is all digits, which the phone pattern matches.
+	var address, hash string
+	for i := 0; hash == "" || strings.Trim(hash, "0123456789") != ""; i++ {
+		address = fmt.Sprintf("user%d@example.com", i)
+		hash = strings.TrimSuffix(strings.TrimPrefix(redactor.replacement(Email, address), "[EMAIL:"), "]")
+	}
+
+	text, counts := redactor.Redact("mail " + address + " or call +1 415 555 0100")
+	expected := "mail [EMAIL:" + hash + "] or call " + redactor.replacement(Phone, "+1 415 555 0100")
+	if text != expected {
+		t.Fatalf("expected %q, got %q", expected, text)
+	}
+	if want := map[string]int{Email: 1, Phone: 1}; !maps.Equal(counts, want) {
+		t.Fatalf("expected counts %v, got %v", want, counts)
+	}
+}
+
+func TestRedactDropsUserPatterns(t *testing.T) {
+	account, err := Pattern("account", `ACC-\d{6}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redactor, err := New(ModeDrop, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if text, counts := redactor.Redact("Account ACC-123456 is closed."); text != "Account is closed." || counts["account"] != 1 {
+		t.Fatalf("expected the account dropped, got %q, %v", text, counts)
+	}
+	if _, err := Pattern("broken", `(`); err == nil {
+		t.Fatal("expected an invalid pattern to fail")
+	}
+	if _, err := New("blur"); err == nil {
+		t.Fatal("expected an unknown mode to fail")
+	}
+}
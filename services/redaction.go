@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tuanta7/ekko/services/redact"
+)
+
+// Redaction configures the removal of personal data from transcripts. When
+// enabled, it applies to every event's text, raw text included, and so to
+// everything exported.
+type Redaction struct {
+	Enabled bool `json:"enabled"`
+	// Mode is what replaces the data found: "mask", the default, "hash" or
+	// "drop".
+	Mode redact.Mode `json:"mode"`
+	// Detectors names the built-in detectors to run: "card", "email", "phone"
+	// and "national-id". Empty runs all of them.
+	Detectors []string `json:"detectors,omitempty"`
+	// Patterns are user-defined detectors.
+	Patterns []RedactionPattern `json:"patterns,omitempty"`
+}
+
+// RedactionPattern is a user-defined detector: a regular expression and the
+// name its matches are masked and reported as.
+type RedactionPattern struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// RedactionReport counts what was redacted from a session's final transcript.
+type RedactionReport struct {
+	SessionID string `json:"sessionID"`
+	// Counts are the number of redactions by type, such as "card".
+	Counts map[string]int `json:"counts"`
+	Total  int            `json:"total"`
+}
+
+// redactor builds the redactor the settings describe, or nil when redaction is
+// disabled.
+func (r Redaction) redactor() (*redact.Redactor, error) {
+	if !r.Enabled {
+		return nil, nil
+	}
+
+	names := r.Detectors
+	if len(names) == 0 {
+		names = redact.Builtins()
+	}
+	var detectors []redact.Detector
+	for _, name := range names {
+		detector, ok := redact.Builtin(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q", name)
+		}
+		detectors = append(detectors, detector)
+	}
+	for _, pattern := range r.Patterns {
+		name := strings.TrimSpace(pattern.Name)
+		if name == "" {
+			return nil, errors.New("redaction pattern name is empty")
+		}
+		detector, err := redact.Pattern(name, pattern.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		detectors = append(detectors, detector)
+	}
+
+	mode := r.Mode
+	if mode == "" {
+		mode = redact.ModeMask
+	}
+	return redact.New(mode, detectors...)
+}
+
+// Redaction returns the redaction settings.
+func (t *TranscribeService) Redaction() (Redaction, error) {
+	return t.redaction.load()
+}
+
+// SaveRedaction replaces the redaction settings. Sessions started from then on
+// use them.
+func (t *TranscribeService) SaveRedaction(redaction Redaction) error {
+	if _, err := redaction.redactor(); err != nil {
+		return err
+	}
+	return t.redaction.save(redaction)
+}
+
+// RedactionReport counts what was redacted from the final transcript of a
+// running or finished session.
+func (t *TranscribeService) RedactionReport(sessionID string) (RedactionReport, error) {
+	t.mu.Lock()
+	transcript, ok := t.transcripts[sessionID]
+	t.mu.Unlock()
+	if !ok {
+		return RedactionReport{}, errors.New("transcript not found")
+	}
+
+	report := RedactionReport{SessionID: sessionID, Counts: make(map[string]int)}
+	for _, event := range transcript.finals() {
+		for name, count := range event.Redactions {
+			report.Counts[name] += count
+			report.Total += count
+		}
+	}
+	return report, nil
+}
+
+// redact removes personal data from an event's text and raw text. The timed
+// words of a redacted event are dropped, as they would give the data away.
+func (t *TranscribeSession) redact(event TranscriptEvent) TranscriptEvent {
+	if t.redactor == nil || event.Annotation != "" {
+		return event
+	}
+
+	text, counts := t.redactor.Redact(event.Text)
+	raw, rawCounts := t.redactor.Redact(event.RawText)
+	if counts == nil && rawCounts == nil {
+		return event
+	}
+
+	event.Text, event.RawText = text, raw
+	event.Redactions = counts
+	event.Words = nil
+	return event
+}
+
+// promptText returns a final's text as it is carried into the prompt of the
+// next chunks: redacted, so that removed data is not sent to the transcriber
+// again.
+func (t *TranscribeSession) promptText(text string) string {
+	text, _ = t.redactor.Redact(text)
+	return text
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+	"github.com/tuanta7/ekko/services/chunker"
+	"github.com/tuanta7/ekko/services/redact"
+)
+
+func TestRedactionSettingsBuildRedactor(t *testing.T) {
+	if redactor, err := (Redaction{}).redactor(); redactor != nil || err != nil {
+		t.Fatalf("expected no redactor while disabled, got %v, %v", redactor, err)
+	}
+	if _, err := (Redaction{Enabled: true, Detectors: []string{"passport"}}).redactor(); err == nil {
+		t.Fatal("expected an unknown detector to fail")
+	}
+	if _, err := (Redaction{Enabled: true, Patterns: []RedactionPattern{{Name: "x", Pattern: "("}}}).redactor(); err == nil {
+		t.Fatal("expected an invalid pattern to fail")
+	}
+}
+
+func TestProcessRedactsFinalsAndReports(t *testing.T) {
+	final := newFakeTranscriber(
+		whisper.Result{Segments: []whisper.Segment{{
+			Text:   "Mail jo@example.com, card 4539 1488 0343 6467.",
+			End:    time.Second,
+			Tokens: []whisper.Token{{Text: " Mail"}, {Text: " jo@example.com"}},
+		}}},
+		whisper.Result{Segments: []whisper.Segment{{Text: "Or ACC-123456 and ann@example.com."}}},
+	)
+	redactor, err := Redaction{
+		Enabled:  true,
+		Patterns: []RedactionPattern{{Name: "account", Pattern: `ACC-\d+`}},
+	}.redactor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := NewSession(func() {}, final, final, SessionOptions{}, nil)
+	session.redactor = redactor
+	service := &TranscribeService{
+		filter:      newHallucinationFilter(nil),
+		transcripts: map[string]*Transcript{session.ID: session.Transcript},
+	}
+
+	for id := range int64(2) {
+		service.process(context.Background(), session, Job{ID: id + 1, Sequence: id + 1, Chunk: chunker.AudioChunk{
+			Samples:     make([]float32, 16000),
+			UtteranceID: id + 1,
+			Final:       true,
+		}})
+	}
+
+	finals := session.Transcript.finals()
+	if finals[0].Text != "Mail [EMAIL], card [CARD]." || finals[0].Words != nil {
+		t.Fatalf("expected the address and card masked without words, got %+v", finals[0])
+	}
+	if export := session.Transcript.Render(ExportOptions{RawText: true}); strings.Contains(export, "@") {
+		t.Fatalf("expected no address in the export, got %q", export)
+	}
+	if prompt := final.options()[1].InitialPrompt; strings.Contains(prompt, "@") || !strings.Contains(prompt, "[EMAIL]") {
+		t.Fatalf("expected the next prompt to carry the redacted final, got %q", prompt)
+	}
+
+	report, err := service.RedactionReport(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 4 || report.Counts[redact.Email] != 2 || report.Counts["account"] != 1 {
+		t.Fatalf("expected 4 redactions, 2 of them addresses, got %+v", report)
+	}
+}
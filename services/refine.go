@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+)
+
+const (
+	EventRefined        = "transcribe:refined"
+	EventRefineProgress = "transcribe:refine-progress"
+)
+
+// RefineProgressEvent reports how far the refinement of a finished session has
+// come.
+type RefineProgressEvent struct {
+	SessionID string `json:"sessionID"`
+	// Refined is the number of finals processed so far, out of Total.
+	Refined int `json:"refined"`
+	Total   int `json:"total"`
+	// Done is set on the last event, when every final was processed or the
+	// refinement was cancelled.
+	Done      bool `json:"done"`
+	Cancelled bool `json:"cancelled,omitempty"`
+}
+
+// audioArchive keeps the audio of a session's finals so that they can be
+// transcribed again once the session ends. The samples are spilled to a
+// temporary file, about 230 MB per hour of speech, rather than held in memory.
+type audioArchive struct {
+	mu   sync.Mutex
+	file *os.File
+	// size is the number of bytes written to file.
+	size int64
+	// chunks locate the archived finals in file by chunk ID.
+	chunks map[int64]archiveEntry
+}
+
+// archiveEntry is where the audio of one final chunk sits in the archive file
+// and in the session.
+type archiveEntry struct {
+	offset  int64
+	samples int
+	start   time.Duration
+	overlap time.Duration
+}
+
+// archivedChunk is the audio of one final chunk and where it sits in the session.
+type archivedChunk struct {
+	samples []float32
+	start   time.Duration
+	overlap time.Duration
+}
+
+func newAudioArchive() (*audioArchive, error) {
+	file, err := os.CreateTemp("", "ekko-archive-*.f32")
+	if err != nil {
+		return nil, err
+	}
+	return &audioArchive{file: file, chunks: make(map[int64]archiveEntry)}, nil
+}
+
+// keep writes the samples of a final job's chunk, which go back to the
+// chunker's pool after inference, to the archive. A nil archive keeps nothing.
+func (a *audioArchive) keep(job Job) error {
+	if a == nil || !job.Chunk.Final || job.Chunk.Annotation != "" {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file == nil {
+		return errors.New("audio archive is closed")
+	}
+	data := make([]byte, 4*len(job.Chunk.Samples))
+	for i, sample := range job.Chunk.Samples {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(sample))
+	}
+	if _, err := a.file.WriteAt(data, a.size); err != nil {
+		return err
+	}
+	a.chunks[job.ID] = archiveEntry{
+		offset:  a.size,
+		samples: len(job.Chunk.Samples),
+		start:   job.Chunk.Start,
+		overlap: job.Chunk.Overlap,
+	}
+	a.size += int64(len(data))
+	return nil
+}
+
+// take reads the archived audio of a chunk and forgets it.
+func (a *audioArchive) take(chunkID int64) (archivedChunk, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry, ok := a.chunks[chunkID]
+	delete(a.chunks, chunkID)
+	if !ok || a.file == nil {
+		return archivedChunk{}, false
+	}
+	data := make([]byte, 4*entry.samples)
+	if _, err := a.file.ReadAt(data, entry.offset); err != nil {
+		return archivedChunk{}, false
+	}
+	samples := make([]float32, entry.samples)
+	for i := range samples {
+		samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return archivedChunk{samples: samples, start: entry.start, overlap: entry.overlap}, true
+}
+
+// close deletes the archive file, with the audio of any final not taken. A
+// nil or closed archive is left alone.
+func (a *audioArchive) close() error {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file == nil {
+		return nil
+	}
+	clear(a.chunks)
+	err := errors.Join(a.file.Close(), os.Remove(a.file.Name()))
+	a.file = nil
+	return err
+}
+
+// finals returns the transcript's final events, without annotations, in order.
+func (t *Transcript) finals() []TranscriptEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var finals []TranscriptEvent
+	for _, event := range t.events() {
+		if event.Annotation == "" {
+			finals = append(finals, event)
+		}
+	}
+	return finals
+}
+
+// startRefinement re-transcribes a finished session's finals with its refine
+// model in the background. Once the app is shutting down, the session's
+// archived audio is dropped instead. Either way the refine model is handed back
+// once done.
+func (t *TranscribeService) startRefinement(session *TranscribeSession) {
+	// Checked under t.mu, so that ServiceShutdown, which cancels t.ctx before
+	// taking t.mu, either sees the refinement to wait for or keeps it from
+	// starting.
+	t.mu.Lock()
+	if t.ctx.Err() != nil {
+		t.mu.Unlock()
+		_ = session.archive.close()
+		t.releaseModels(session.refiner)
+		return
+	}
+	ctx, cancel := context.WithCancel(t.ctx)
+	t.refinements[session.ID] = cancel
+	t.refining.Add(1)
+	t.mu.Unlock()
+
+	go func() {
+		defer func() {
+			cancel()
+			t.mu.Lock()
+			delete(t.refinements, session.ID)
+			t.mu.Unlock()
+			t.releaseModels(session.refiner)
+			t.refining.Done()
+		}()
+		t.refine(ctx, session)
+	}()
+}
+
+// refine transcribes the archived audio of each final again with the refine
+// model and replaces the final's text, emitting a refined event for each. The
+// chunk boundaries are the recorded ones, so timestamps do not change. A final
+// whose refinement fails or comes out empty keeps its live text, and so does a
+// final split at speaker turns when the refine model cannot detect them. The
+// archive is deleted once done.
+func (t *TranscribeService) refine(ctx context.Context, session *TranscribeSession) {
+	defer session.archive.close()
+
+	finals := session.Transcript.finals()
+	progress := RefineProgressEvent{SessionID: session.ID, Total: len(finals)}
+	t.emit(EventRefineProgress, progress)
+
+	prompt := session.prompt.restart()
+	var turns *speakerTurns
+	if session.refiner.Capabilities().SpeakerTurns {
+		turns = newSpeakerTurns()
+	}
+	previousText := ""
+	for len(finals) > 0 {
+		// The parts of a final split at speaker turns share its chunk.
+		parts := 1
+		for parts < len(finals) && finals[parts].ChunkID == finals[0].ChunkID {
+			parts++
+		}
+		final := finals[:parts]
+		finals = finals[parts:]
+
+		text := joinText(final)
+		chunk, ok := session.archive.take(final[0].ChunkID)
+		if ok && (parts == 1 || turns != nil) {
+			if refined, err := t.refineFinal(ctx, session, turns, prompt, previousText, final, chunk); err == nil {
+				text = refined
+			} else if errors.Is(err, context.Canceled) {
+				progress.Done, progress.Cancelled = true, true
+				t.emit(EventRefineProgress, progress)
+				return
+			}
+		}
+		previousText = text
+
+		progress.Refined += parts
+		t.emit(EventRefineProgress, progress)
+	}
+
+	progress.Done = true
+	t.emit(EventRefineProgress, progress)
+}
+
+// refineFinal transcribes one final, given as its parts, again and emits its
+// refined events. With turns, the refined final is split at the refine
+// model's speaker turns, labelled by voice when the session diarizes;
+// otherwise it keeps the live final's speaker. It returns the final's new
+// text.
+func (t *TranscribeService) refineFinal(
+	ctx context.Context,
+	session *TranscribeSession,
+	turns *speakerTurns,
+	prompt *promptContext,
+	previousText string,
+	final []TranscriptEvent,
+	chunk archivedChunk,
+) (string, error) {
+	options := whisper.TranscribeOptions{
+		TokenTimestamps: true,
+		Language:        session.language.current(),
+		Translate:       session.Options.Translate,
+		InitialPrompt:   prompt.prompt(),
+		Decode:          session.Options.decoding(true),
+	}
+	result, err := transcribeSamples(ctx, session.refiner, chunk.samples, options, true)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			t.emitError(session.ID, err)
+		}
+		return "", err
+	}
+
+	segments := t.filter.apply(session.ID, session.redactor, result.Segments)
+	segments = trimOverlap(previousText, chunk.overlap, segments)
+	text, looped := whisper.CombineSegments(segments), false
+	if options.InitialPrompt != "" {
+		text, looped = collapseRepetitions(text)
+	}
+	prompt.observeFinal(session.promptText(text), looped)
+	if text == "" {
+		return "", errors.New("refined transcript is empty")
+	}
+
+	refined := final[0]
+	refined.Text = text
+	refined.Language = result.Language
+	refined.LanguageProbability = result.LanguageProbability
+	refined.EndMs = final[len(final)-1].EndMs
+
+	parts := []speakerPart{{speaker: refined.Speaker, segments: segments}}
+	if turns != nil && !looped {
+		parts = turns.split(segments)
+		session.diarizer.label(parts, chunk.samples)
+	}
+	var events []TranscriptEvent
+	for _, event := range speakerEvents(refined, chunk.start, parts, looped) {
+		if event = session.redact(session.rewrite(event)); event.Text != "" {
+			events = append(events, event)
+		}
+	}
+	session.Transcript.replace(refined.UtteranceID, events)
+	for _, event := range events {
+		t.emit(EventRefined, event)
+	}
+	return text, nil
+}
+
+// joinText joins the text of a final's parts.
+func joinText(parts []TranscriptEvent) string {
+	texts := make([]string, len(parts))
+	for i, part := range parts {
+		texts[i] = part.Text
+	}
+	return strings.Join(texts, " ")
+}
+
+// CancelRefinement stops the refinement of a finished session. Finals refined
+// so far keep their new text.
+func (t *TranscribeService) CancelRefinement(sessionID string) error {
+	t.mu.Lock()
+	cancel, ok := t.refinements[sessionID]
+	t.mu.Unlock()
+	if !ok {
+		return errors.New("no refinement running for the session")
+	}
+
+	cancel()
+	return nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+	"github.com/tuanta7/ekko/services/chunker"
+)
+
+// refineSession runs one final through a session that is refined with refiner.
+func refineSession(t *testing.T, service *TranscribeService, refiner Transcriber) *TranscribeSession {
+	t.Helper()
+	live := newFakeTranscriber(whisper.Result{Segments: []whisper.Segment{{Text: "Ship it on fry day."}}})
+	session := NewSession(func() {}, live, live, SessionOptions{}, nil)
+	if err := session.refineWith(refiner); err != nil {
+		t.Fatal(err)
+	}
+
+	service.process(context.Background(), session, Job{ID: 7, Sequence: 1, Chunk: chunker.AudioChunk{
+		Samples:     make([]float32, 16000),
+		UtteranceID: 3,
+		Revision:    2,
+		Final:       true,
+		Start:       4 * time.Second,
+		End:         5 * time.Second,
+	}})
+	return session
+}
+
+func TestRefineReplacesFinalsKeepingTimestamps(t *testing.T) {
+	service := &TranscribeService{filter: newHallucinationFilter(nil)}
+	refiner := newFakeTranscriber(whisper.Result{Segments: []whisper.Segment{{
+		Text:   "Ship it on Friday.",
+		End:    time.Second,
+		Tokens: []whisper.Token{{Text: " Friday", Start: 200 * time.Millisecond, End: 600 * time.Millisecond}},
+	}}})
+	session := refineSession(t, service, refiner)
+
+	service.refine(context.Background(), session)
+
+	if calls := refiner.options(); len(calls) != 1 || !calls[0].TokenTimestamps {
+		t.Fatalf("expected one final decode on the refine model, got %+v", calls)
+	}
+	finals := session.Transcript.finals()
+	if len(finals) != 1 {
+		t.Fatalf("expected one final, got %d", len(finals))
+	}
+	final := finals[0]
+	if final.Text != "Ship it on Friday." || final.ChunkID != 7 || final.StartMs != 4000 || final.EndMs != 5000 {
+		t.Fatalf("expected the refined text at the recorded boundaries, got %+v", final)
+	}
+	if len(final.Words) != 1 || final.Words[0].StartMs != 4200 {
+		t.Fatalf("expected refined words in session time, got %+v", final.Words)
+	}
+	if _, ok := session.archive.take(7); ok {
+		t.Fatal("expected the archived audio to be released")
+	}
+}
+
+func TestRefineStopsOnCancel(t *testing.T) {
+	service := &TranscribeService{filter: newHallucinationFilter(nil)}
+	refiner := newFakeTranscriber(whisper.Result{Segments: []whisper.Segment{{Text: "Ship it on Friday."}}})
+	session := refineSession(t, service, refiner)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	service.refine(ctx, session)
+
+	if text := session.Transcript.finals()[0].Text; text != "Ship it on fry day." {
+		t.Fatalf("expected the live text to stay, got %q", text)
+	}
+}
+
+func TestShutdownWaitsForRefinementBeforeClosingModels(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	refiner := newFakeTranscriber()
+	refiner.hang = true
+	service := &TranscribeService{
+		ctx:         ctx,
+		cancel:      cancel,
+		filter:      newHallucinationFilter(nil),
+		sessions:    make(map[string]*TranscribeSession),
+		refinements: make(map[string]context.CancelFunc),
+		models:      map[string]*sharedModel{"large": {Transcriber: refiner, name: "large", users: 1}},
+	}
+	session := refineSession(t, service, refiner)
+	archive := session.archive.file.Name()
+
+	service.startRefinement(session)
+	if err := service.ServiceShutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if len(service.refinements) != 0 || !refiner.closed {
+		t.Fatalf("expected the refinement to end before the model closed, got %d running", len(service.refinements))
+	}
+	if _, err := os.Stat(archive); !os.IsNotExist(err) {
+		t.Fatalf("expected the archive to be deleted, got %v", err)
+	}
+
+	session = refineSession(t, service, refiner)
+	archive = session.archive.file.Name()
+	service.startRefinement(session)
+	if len(service.refinements) != 0 {
+		t.Fatal("expected no refinement to start after shutdown")
+	}
+	if _, err := os.Stat(archive); !os.IsNotExist(err) {
+		t.Fatalf("expected the skipped refinement's archive to be deleted, got %v", err)
+	}
+}
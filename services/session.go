@@ -8,26 +8,117 @@ import (
 
 	"github.com/tuanta7/ekko/services/adapter/ffmpeg"
 	"github.com/tuanta7/ekko/services/chunker"
-)
-
-const (
-	DefaultJobBufferSize = 4
+	"github.com/tuanta7/ekko/services/redact"
+	"github.com/tuanta7/ekko/services/textproc"
 )
 
 type TranscribeSession struct {
 	ID     string
 	Cancel context.CancelFunc
 	Done   chan struct{}
+	// Transcript collects the session's final events for export.
+	Transcript *Transcript
+	// Options are the settings the session was started with.
+	Options SessionOptions
+
+	// transcriber is the backend the session transcribes finals with.
+	transcriber Transcriber
+	// partialTranscriber is the backend the session transcribes partials with.
+	// It is transcriber unless the session picked a separate partial model.
+	partialTranscriber Transcriber
+	// language is the requested, detected or locked spoken language.
+	language *sessionLanguage
+	// prompt carries the glossary and recent finals into each chunk's inference.
+	prompt *promptContext
+	// turns labels speakers when the final model detects speaker turns. It is
+	// nil otherwise.
+	turns *speakerTurns
+	// text rewrites transcript text before it is emitted.
+	text textproc.Chain
+	// redactor removes personal data from transcript text after text
+	// processing. It is nil when redaction is disabled.
+	redactor *redact.Redactor
+	// diarizer labels speakers by their voice when the session diarizes. It is
+	// nil otherwise, and takes precedence over turns.
+	diarizer *speakerDiarizer
+
+	// refiner transcribes the session's finals again once it ends, and archive
+	// keeps their audio until then. Both are nil when the session is not
+	// refined; archive is closed once refinement ends or is skipped.
+	refiner Transcriber
+	archive *audioArchive
+
+	// load sheds work while transcription falls behind real time.
+	load *loadAdapter
+	// queue holds the jobs waiting for the session's workers.
+	queue *jobQueue
+	// order delivers the results of the session's workers.
+	order *resultOrder
+	// lastFinalText is the previous final transcript, used to trim words repeated
+	// from overlap audio. Only finals delivered through order touch it.
+	lastFinalText string
 }
 
-func NewSession(cancel context.CancelFunc) *TranscribeSession {
+// NewSession creates a session that transcribes partials with partial and
+// finals with final, which may be the same, and prompts them with the given
+// glossary terms.
+func NewSession(cancel context.CancelFunc, partial, final Transcriber, options SessionOptions, terms []string) *TranscribeSession {
+	var turns *speakerTurns
+	if final.Capabilities().SpeakerTurns {
+		turns = newSpeakerTurns()
+	}
+	var diarizer *speakerDiarizer
+	if options.Diarize {
+		diarizer = newSpeakerDiarizer(options.MaxSpeakers)
+	}
+
 	return &TranscribeSession{
-		ID:     fmt.Sprintf("%d", time.Now().UnixNano()),
-		Cancel: cancel,
-		Done:   make(chan struct{}),
+		ID:                 fmt.Sprintf("%d", time.Now().UnixNano()),
+		Cancel:             cancel,
+		Done:               make(chan struct{}),
+		Transcript:         &Transcript{},
+		Options:            options,
+		transcriber:        final,
+		partialTranscriber: partial,
+		language:           newSessionLanguage(options.Language),
+		prompt:             newPromptContext(terms),
+		turns:              turns,
+		diarizer:           diarizer,
+		load:               newLoadAdapter(),
+		queue:              newJobQueue(),
+		order:              newResultOrder(),
 	}
 }
 
+// refineWith makes the session keep the audio of its finals and transcribe
+// them again with refiner once it ends.
+func (t *TranscribeSession) refineWith(refiner Transcriber) error {
+	archive, err := newAudioArchive()
+	if err != nil {
+		return fmt.Errorf("refinement audio: %w", err)
+	}
+	t.refiner = refiner
+	t.archive = archive
+	return nil
+}
+
+// transcriberFor returns the backend that transcribes a job's chunk.
+func (t *TranscribeSession) transcriberFor(job Job) Transcriber {
+	if job.Chunk.Final {
+		return t.transcriber
+	}
+	return t.partialTranscriber
+}
+
+// speaker returns the speaker of the last final, which partials are labelled
+// with until their own final is in.
+func (t *TranscribeSession) speaker() int {
+	if t.diarizer != nil {
+		return t.diarizer.current()
+	}
+	return t.turns.current()
+}
+
 func (t *TranscribeSession) Shutdown() {
 	t.Cancel()
 	<-t.Done // Wait for the session to finish
@@ -40,43 +131,61 @@ func (t *TranscribeService) runSession(
 	recorderErrs <-chan error,
 ) {
 	defer func() {
+		session.Transcript.end(time.Now())
 		t.mu.Lock()
 		delete(t.sessions, session.ID)
+		t.pruneTranscripts(time.Now())
 		t.mu.Unlock()
 		// Notify listeners that recording and transcription have stopped for this session.
 		t.emitState(session.ID, EventRecordingStopped, "Recording stopped")
+
+		// Done is closed last, so that ServiceShutdown, which waits for it, finds
+		// the models handed back or their refinement counted.
+		t.releaseModels(session.partialTranscriber, session.transcriber)
+		if session.refiner != nil {
+			t.startRefinement(session)
+		}
 		close(session.Done)
 	}()
 
-	jobQueue := make(chan Job, DefaultJobBufferSize)
-
+	// A backend that transcribes several chunks at once gets as many workers,
+	// so that a long final does not hold back the partials queued behind it.
+	// With a separate partial model, each model gets its own workers.
 	var workers sync.WaitGroup
-	workers.Add(1)
-	go func() {
-		defer workers.Done()
-		for job := range jobQueue {
-			t.process(session.ID, job)
+	work := func(kinds jobKinds, transcriber Transcriber) {
+		for range max(1, transcriber.Capabilities().Concurrency) {
+			workers.Add(1)
+			go t.work(ctx, session, kinds, &workers)
 		}
-	}()
+	}
+	if session.partialTranscriber == session.transcriber {
+		work(allJobs, session.transcriber)
+	} else {
+		work(finalJobs, session.transcriber)
+		work(partialJobs, session.partialTranscriber)
+	}
 	defer func() {
-		close(jobQueue)
+		session.queue.close()
 		workers.Wait()
 	}()
 
 	audioChunker := chunker.NewAudioChunker()
+	partialInterval := audioChunker.Config.PartialInterval()
 
-	var chunkID int64
+	var counter jobCounter
 	for {
 		select {
 		case frame, ok := <-frames:
-			if !ok { 
+			if !ok {
 				// If the channel is close, flush the remaining chunks
-				t.enqueueJob(context.Background(), jobQueue, audioChunker.Flush(), &chunkID)
+				enqueueJobs(session.queue, audioChunker.Flush(), &counter)
 				return
 			}
 
-			// Add the frame to the chunker and enqueue any new chunks
-			t.enqueueJob(ctx, jobQueue, audioChunker.AddFrame(frame), &chunkID)
+			// Add the frame to the chunker and enqueue any new chunks, with
+			// fewer partials while transcription falls behind.
+			session.load.tune(&audioChunker.Config, partialInterval)
+			enqueueJobs(session.queue, session.load.shedPartials(audioChunker.AddFrame(frame)), &counter)
 
 		case err, ok := <-recorderErrs:
 			if !ok {
@@ -89,37 +198,51 @@ func (t *TranscribeService) runSession(
 			}
 
 		case <-ctx.Done():
-			t.enqueueJob(context.Background(), jobQueue, audioChunker.Flush(), &chunkID)
+			enqueueJobs(session.queue, audioChunker.Flush(), &counter)
 			return
 		}
 	}
 }
 
-func (t *TranscribeService) enqueueJob(
-	ctx context.Context,
-	queue chan<- Job,
-	chunks []chunker.AudioChunk,
-	chunkID *int64, // use pointer to persist increment across repeated calls
-) {
+// work processes the session's jobs of the given kinds until its queue is
+// closed and drained.
+func (t *TranscribeService) work(ctx context.Context, session *TranscribeSession, kinds jobKinds, workers *sync.WaitGroup) {
+	defer workers.Done()
+	for {
+		job, ok := session.queue.pop(kinds)
+		if !ok {
+			return
+		}
+		// Stopping the session aborts its partials, but finals, the one
+		// flushed on stop included, run until the app shuts down.
+		jobCtx := ctx
+		if job.Chunk.Final {
+			jobCtx = t.ctx
+		}
+		t.process(jobCtx, session, job)
+	}
+}
+
+// jobCounter numbers a session's jobs.
+type jobCounter struct {
+	// chunkID is the ID of the last queued job.
+	chunkID int64
+	// sequence is the Sequence of the last queued final.
+	sequence int64
+}
+
+// enqueueJobs numbers chunks and queues them for the session's workers.
+func enqueueJobs(queue *jobQueue, chunks []chunker.AudioChunk, counter *jobCounter) {
 	for _, chunk := range chunks {
-		*chunkID = *chunkID + 1
+		counter.chunkID++
 		job := Job{
-			ID:    *chunkID,
+			ID:    counter.chunkID,
 			Chunk: chunk,
 		}
-
 		if chunk.Final {
-			select {
-			case queue <- job:
-			case <-ctx.Done():
-				return
-			}
-			continue
-		}
-
-		select {
-		case queue <- job:
-		default:
+			counter.sequence++
+			job.Sequence = counter.sequence
 		}
+		queue.push(job)
 	}
 }
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// settingsStore keeps one settings value in a file, JSON unless created
+// otherwise.
+type settingsStore[T any] struct {
+	mu   sync.Mutex
+	path string
+	// fallback is the value held while the file does not exist.
+	fallback T
+	// encode and decode convert the value to and from the file's contents.
+	encode func(T) ([]byte, error)
+	decode func([]byte) (T, error)
+}
+
+// newSettingsStore stores settings at path as JSON. The file is created on the
+// first save.
+func newSettingsStore[T any](path string) *settingsStore[T] {
+	return &settingsStore[T]{
+		path: path,
+		encode: func(settings T) ([]byte, error) {
+			return json.MarshalIndent(settings, "", "  ")
+		},
+		decode: func(data []byte) (T, error) {
+			var settings T
+			err := json.Unmarshal(data, &settings)
+			return settings, err
+		},
+	}
+}
+
+// newLinesStore stores a list at path as one item per line, so that users can
+// edit it by hand. fallback is the list until the file is created.
+func newLinesStore(path string, fallback []string) *settingsStore[[]string] {
+	return &settingsStore[[]string]{
+		path:     path,
+		fallback: fallback,
+		encode: func(lines []string) ([]byte, error) {
+			return []byte(strings.Join(lines, "\n") + "\n"), nil
+		},
+		decode: func(data []byte) ([]string, error) {
+			return strings.Split(string(data), "\n"), nil
+		},
+	}
+}
+
+// load reads the settings; a missing file holds the fallback.
+func (s *settingsStore[T]) load() (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s.fallback, nil
+	}
+	if err != nil {
+		return s.fallback, err
+	}
+
+	settings, err := s.decode(data)
+	if err != nil {
+		return settings, fmt.Errorf("read %s: %w", s.path, err)
+	}
+	return settings, nil
+}
+
+// save replaces the settings.
+func (s *settingsStore[T]) save(settings T) error {
+	data, err := s.encode(settings)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic replaces the file at path with data, creating its directory
+// as needed. The data goes to a sibling file that is then renamed over path,
+// so that a crash never leaves half a file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
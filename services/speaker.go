@@ -0,0 +1,107 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+)
+
+// speakerTurns labels a session's finals with alternating speakers at the
+// turns a tinydiarize model marks. A tinydiarize model only tells that the
+// speaker changes, not who speaks, so two speakers take turns as 1 and 2. A
+// nil speakerTurns labels nothing.
+type speakerTurns struct {
+	mu sync.Mutex
+	// speaker is the one speaking at the end of the last final.
+	speaker int
+}
+
+// speakerPart is a run of segments spoken by one speaker. Speaker is zero when
+// speakers are not labelled.
+type speakerPart struct {
+	speaker  int
+	segments []whisper.Segment
+}
+
+func newSpeakerTurns() *speakerTurns {
+	return &speakerTurns{speaker: 1}
+}
+
+// current returns the speaker of the last final.
+func (s *speakerTurns) current() int {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.speaker
+}
+
+// split cuts a final's segments into parts after each speaker turn. It must be
+// called for finals in order, as every turn passes the word to the other
+// speaker for the finals that follow.
+func (s *speakerTurns) split(segments []whisper.Segment) []speakerPart {
+	if s == nil {
+		return []speakerPart{{segments: segments}}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts := []speakerPart{{speaker: s.speaker}}
+	for _, segment := range segments {
+		part := &parts[len(parts)-1]
+		part.segments = append(part.segments, segment)
+		if segment.SpeakerTurnNext {
+			s.speaker = 3 - s.speaker
+			parts = append(parts, speakerPart{speaker: s.speaker})
+		}
+	}
+	if len(parts) > 1 && len(parts[len(parts)-1].segments) == 0 {
+		// The chunk ended on a turn; the next final starts with the new speaker.
+		parts = parts[:len(parts)-1]
+	}
+	return parts
+}
+
+// speakerEvents turns a final event into one event per speaker part. A single
+// part keeps the chunk's boundaries; otherwise each part spans its segments,
+// with the first and last part reaching to the chunk's edges. Parts are
+// numbered from 0 in order. Words are left out when the text was collapsed
+// from a loop, as they no longer match it; a looped final is never split, so
+// the parts of a split one hold no loop.
+func speakerEvents(final TranscriptEvent, chunkStart time.Duration, parts []speakerPart, looped bool) []TranscriptEvent {
+	if len(parts) == 1 {
+		final.Speaker = parts[0].speaker
+		final.Words = nil
+		if !looped {
+			final.Words = transcriptWords(chunkStart, parts[0].segments)
+		}
+		return []TranscriptEvent{final}
+	}
+
+	var events []TranscriptEvent
+	for i, part := range parts {
+		text := whisper.CombineSegments(part.segments)
+		if text == "" {
+			continue
+		}
+
+		event := final
+		event.Part = len(events)
+		event.Speaker = part.speaker
+		event.Text = text
+		event.Words = transcriptWords(chunkStart, part.segments)
+		if i > 0 {
+			event.StartMs = (chunkStart + part.segments[0].Start).Milliseconds()
+		}
+		if i < len(parts)-1 {
+			event.EndMs = (chunkStart + part.segments[len(part.segments)-1].End).Milliseconds()
+		}
+		events = append(events, event)
+	}
+	return events
+}
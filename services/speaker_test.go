@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+	"github.com/tuanta7/ekko/services/chunker"
+)
+
+func TestSpeakerTurnsAlternateAcrossFinals(t *testing.T) {
+	turns := newSpeakerTurns()
+
+	parts := turns.split([]whisper.Segment{{Text: "How are you?", SpeakerTurnNext: true}, {Text: "Fine."}})
+	if len(parts) != 2 || parts[0].speaker != 1 || parts[1].speaker != 2 {
+		t.Fatalf("expected speaker 1 then 2, got %+v", parts)
+	}
+
+	parts = turns.split([]whisper.Segment{{Text: "Thanks.", SpeakerTurnNext: true}})
+	if len(parts) != 1 || parts[0].speaker != 2 {
+		t.Fatalf("expected a single part by speaker 2, got %+v", parts)
+	}
+	if speaker := turns.current(); speaker != 1 {
+		t.Fatalf("expected speaker 1 to take over after a trailing turn, got %d", speaker)
+	}
+
+	var unlabelled *speakerTurns
+	if parts := unlabelled.split([]whisper.Segment{{Text: "Hi.", SpeakerTurnNext: true}}); len(parts) != 1 || parts[0].speaker != 0 {
+		t.Fatalf("expected one unlabelled part without turn detection, got %+v", parts)
+	}
+}
+
+func TestProcessSplitsFinalsAtSpeakerTurns(t *testing.T) {
+	final := newFakeTranscriber(whisper.Result{Segments: []whisper.Segment{
+		{Text: "Are you ready?", Start: 0, End: 1200 * time.Millisecond, SpeakerTurnNext: true},
+		{Text: "Almost.", Start: 1500 * time.Millisecond, End: 2 * time.Second},
+	}})
+	final.capabilities.SpeakerTurns = true
+	service := &TranscribeService{filter: newHallucinationFilter(nil)}
+	session := NewSession(func() {}, final, final, SessionOptions{}, nil)
+
+	service.process(context.Background(), session, Job{ID: 1, Sequence: 1, Chunk: chunker.AudioChunk{
+		Samples:     make([]float32, 32000),
+		UtteranceID: 4,
+		Final:       true,
+		Start:       10 * time.Second,
+		End:         12 * time.Second,
+	}})
+
+	finals := session.Transcript.finals()
+	if len(finals) != 2 {
+		t.Fatalf("expected the final split in two, got %+v", finals)
+	}
+	first, second := finals[0], finals[1]
+	if first.Part != 0 || first.Speaker != 1 || first.StartMs != 10_000 || first.EndMs != 11_200 {
+		t.Fatalf("expected speaker 1 up to the turn, got %+v", first)
+	}
+	if second.Part != 1 || second.Speaker != 2 || second.StartMs != 11_500 || second.EndMs != 12_000 {
+		t.Fatalf("expected speaker 2 from the turn to the chunk end, got %+v", second)
+	}
+	if second.Text != "Almost." || second.UtteranceID != 4 {
+		t.Fatalf("expected the second part of utterance 4, got %+v", second)
+	}
+}
@@ -0,0 +1,321 @@
+package textproc
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Numbers writes spelled-out numbers as digits, the inverse of the text
+// normalization speech recognizers are trained on. Cardinals from ten up,
+// decimals and percentages become digits, as in "twenty five percent" to
+// "25%", while "one" to "nine" stay words, as style guides spell them. Years
+// spoken in pairs, as in "nineteen ninety nine", become "1999". Dates spoken
+// as a month and an ordinal day, optionally with a year, become "March 3" and
+// "March 3, 2024". English only.
+type Numbers struct{}
+
+// wordKind classifies the words a spoken number is made of.
+type wordKind int
+
+const (
+	noWord wordKind = iota
+	zeroWord
+	unitWord
+	teenWord
+	tensWord
+	hundredWord
+	scaleWord
+	andWord
+)
+
+var numberWords = map[string]int{
+	"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7, "eight": 8,
+	"nine": 9, "ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14, "fifteen": 15,
+	"sixteen": 16, "seventeen": 17, "eighteen": 18, "nineteen": 19, "twenty": 20, "thirty": 30,
+	"forty": 40, "fifty": 50, "sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+	"hundred": 100, "thousand": 1_000, "million": 1_000_000, "billion": 1_000_000_000,
+}
+
+var ordinalWords = map[string]int{
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "sixth": 6, "seventh": 7, "eighth": 8,
+	"ninth": 9, "tenth": 10, "eleventh": 11, "twelfth": 12, "thirteenth": 13, "fourteenth": 14,
+	"fifteenth": 15, "sixteenth": 16, "seventeenth": 17, "eighteenth": 18, "nineteenth": 19,
+	"twentieth": 20, "thirtieth": 30,
+}
+
+var months = map[string]string{
+	"january": "January", "february": "February", "march": "March", "april": "April", "may": "May",
+	"june": "June", "july": "July", "august": "August", "september": "September", "october": "October",
+	"november": "November", "december": "December",
+}
+
+func kindOf(word string) (wordKind, int) {
+	if word == "and" {
+		return andWord, 0
+	}
+	value, ok := numberWords[word]
+	switch {
+	case !ok:
+		return noWord, 0
+	case value == 0:
+		return zeroWord, 0
+	case value < 10:
+		return unitWord, value
+	case value < 20:
+		return teenWord, value
+	case value < 100:
+		return tensWord, value
+	case value == 100:
+		return hundredWord, value
+	default:
+		return scaleWord, value
+	}
+}
+
+// token is one spoken word of a field, with the punctuation around the field.
+type token struct {
+	word        string
+	lead, trail string
+	// capital reports whether the word was written with a capital.
+	capital bool
+	// field is the index of the field the word came from; hyphenated words
+	// give several tokens of one field.
+	field int
+}
+
+func (Numbers) Apply(text string) string {
+	fields := strings.Fields(text)
+	var tokens []token
+	for i, field := range fields {
+		lead, core, trail := word(field)
+		parts := strings.Split(strings.ToLower(core), "-")
+		for j, part := range parts {
+			t := token{word: part, field: i, capital: part != "" && core[0] >= 'A' && core[0] <= 'Z'}
+			if j == 0 {
+				t.lead = lead
+			}
+			if j == len(parts)-1 {
+				t.trail = trail
+			}
+			tokens = append(tokens, t)
+		}
+	}
+
+	var out []string
+	for i := 0; i < len(tokens); {
+		written, next, ok := readDate(tokens, i)
+		if !ok {
+			written, next, ok = readPairedYear(tokens, i)
+		}
+		if !ok {
+			written, next, ok = readNumber(tokens, i)
+		}
+		// A rewrite must cover whole fields, so "twenty-something" stays as is.
+		if ok && (i == 0 || tokens[i-1].field != tokens[i].field) &&
+			(next == len(tokens) || tokens[next].field != tokens[next-1].field) {
+			out = append(out, tokens[i].lead+written+tokens[next-1].trail)
+			i = next
+			continue
+		}
+
+		field := tokens[i].field
+		out = append(out, fields[field])
+		for i < len(tokens) && tokens[i].field == field {
+			i++
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// readNumber reads a number from tokens[i:], returning it written with digits
+// and the index of the token after it. Plain numbers under ten are not read.
+func readNumber(tokens []token, i int) (string, int, bool) {
+	value, next := readCardinal(tokens, i)
+	if next == i {
+		return "", i, false
+	}
+	written := strconv.Itoa(value)
+	digits := value >= 10
+
+	// "three point one four"
+	if next+1 < len(tokens) && tokens[next].word == "point" && tokens[next-1].trail == "" {
+		decimals := ""
+		for j := next + 1; j < len(tokens); j++ {
+			kind, digit := kindOf(tokens[j].word)
+			if kind != zeroWord && kind != unitWord {
+				break
+			}
+			decimals += strconv.Itoa(digit)
+			if tokens[j].trail != "" {
+				break
+			}
+		}
+		if decimals != "" {
+			written += "." + decimals
+			next += 2 + len(decimals) - 1
+			digits = true
+		}
+	}
+	if next < len(tokens) && tokens[next].word == "percent" && tokens[next-1].trail == "" {
+		written += "%"
+		next++
+		digits = true
+	}
+	return written, next, digits
+}
+
+// readCardinal reads the longest cardinal number from tokens[i:]. It returns
+// next == i when there is none. Words are only joined when no punctuation
+// separates them.
+func readCardinal(tokens []token, i int) (value, next int) {
+	total, current := 0, 0
+	last := noWord
+	smallestScale := 0
+	next = i
+	for j := i; j < len(tokens); j++ {
+		if j > i && tokens[j-1].trail != "" {
+			break
+		}
+		if j > i && tokens[j].lead != "" {
+			break
+		}
+
+		kind, value := kindOf(tokens[j].word)
+		valid := false
+		switch kind {
+		case zeroWord:
+			valid = last == noWord
+		case unitWord:
+			valid = last == noWord || last == tensWord || last == hundredWord || last == scaleWord || last == andWord
+		case teenWord, tensWord:
+			valid = last == noWord || last == hundredWord || last == scaleWord || last == andWord
+		case hundredWord:
+			valid = (last == unitWord || last == teenWord || last == tensWord) && current < 100
+		case scaleWord:
+			valid = (last == unitWord || last == teenWord || last == tensWord || last == hundredWord) &&
+				(smallestScale == 0 || value < smallestScale)
+		case andWord:
+			valid = (last == hundredWord || last == scaleWord) && j+1 < len(tokens)
+		}
+		if !valid {
+			break
+		}
+
+		switch kind {
+		case unitWord, teenWord, tensWord:
+			current += value
+		case hundredWord:
+			current *= 100
+		case scaleWord:
+			total += current * value
+			current = 0
+			smallestScale = value
+		}
+		last = kind
+		if kind != andWord {
+			next = j + 1
+		}
+		if kind == zeroWord {
+			break
+		}
+	}
+	return total + current, next
+}
+
+// readDate reads a month followed by an ordinal day and an optional year from
+// tokens[i:].
+func readDate(tokens []token, i int) (string, int, bool) {
+	// Months are capitalized, which tells "May" from "may".
+	month, ok := months[tokens[i].word]
+	if !ok || !tokens[i].capital || tokens[i].trail != "" {
+		return "", i, false
+	}
+	day, next := readOrdinal(tokens, i+1)
+	if next == i+1 || day > 31 {
+		return "", i, false
+	}
+	written := month + " " + strconv.Itoa(day)
+
+	if tokens[next-1].trail == "" || tokens[next-1].trail == "," {
+		if year, after := readYear(tokens, next); after > next {
+			return written + ", " + strconv.Itoa(year), after, true
+		}
+	}
+	return written, next, true
+}
+
+// readOrdinal reads an ordinal such as "third" or "twenty first" from
+// tokens[i:].
+func readOrdinal(tokens []token, i int) (value, next int) {
+	if i >= len(tokens) || tokens[i].lead != "" {
+		return 0, i
+	}
+	if value, ok := ordinalWords[tokens[i].word]; ok {
+		return value, i + 1
+	}
+	kind, tens := kindOf(tokens[i].word)
+	if kind != tensWord || tokens[i].trail != "" || i+1 >= len(tokens) {
+		return 0, i
+	}
+	if unit, ok := ordinalWords[tokens[i+1].word]; ok && unit < 10 {
+		return tens + unit, i + 2
+	}
+	return 0, i
+}
+
+// readPairedYear reads a year spoken in pairs from tokens[i:]. Pairs such as
+// "nineteen" and "ninety nine" do not combine into one cardinal, so a year is
+// only read where it goes on past the cardinal that starts it.
+func readPairedYear(tokens []token, i int) (string, int, bool) {
+	_, cardinal := readCardinal(tokens, i)
+	year, next := readYear(tokens, i)
+	if next <= cardinal || year < 1100 || year >= 2100 {
+		return "", i, false
+	}
+	return strconv.Itoa(year), next, true
+}
+
+// readYear reads a year spoken in pairs, such as "nineteen ninety nine" or
+// "twenty twenty four", or in full, such as "two thousand and five", from
+// tokens[i:].
+func readYear(tokens []token, i int) (value, next int) {
+	if full, after := readCardinal(tokens, i); full >= 1000 && full < 3000 {
+		return full, after
+	}
+
+	century, after := readPair(tokens, i)
+	if after == i || century < 10 || tokens[after-1].trail != "" {
+		return 0, i
+	}
+	year, end := readPair(tokens, after)
+	if end == after {
+		return 0, i
+	}
+	return century*100 + year, end
+}
+
+// readPair reads two digits of a year: a teen, a tens with an optional unit,
+// or "oh" and a unit.
+func readPair(tokens []token, i int) (value, next int) {
+	if i >= len(tokens) || tokens[i].lead != "" {
+		return 0, i
+	}
+	kind, first := kindOf(tokens[i].word)
+	if tokens[i].word == "oh" {
+		kind, first = zeroWord, 0
+	}
+	switch kind {
+	case teenWord:
+		return first, i + 1
+	case tensWord, zeroWord:
+		if tokens[i].trail == "" && i+1 < len(tokens) {
+			if unitKind, unit := kindOf(tokens[i+1].word); unitKind == unitWord {
+				return first + unit, i + 2
+			}
+		}
+		if kind == tensWord {
+			return first, i + 1
+		}
+	}
+	return 0, i
+}
@@ -0,0 +1,33 @@
+package textproc
+
+import "testing"
+
+func TestNumbersWritesDigits(t *testing.T) {
+	cases := map[string]string{
+		"We sold twenty five units.":                       "We sold 25 units.",
+		"It costs one hundred and five dollars":            "It costs 105 dollars",
+		"two thousand three hundred people came":           "2300 people came",
+		"Growth was three point five percent this year.":   "Growth was 3.5% this year.",
+		"about forty-two, maybe forty three":               "about 42, maybe 43",
+		"I have one idea and two questions.":               "I have one idea and two questions.",
+		"five percent of them":                             "5% of them",
+		"Ship it on March third.":                          "Ship it on March 3.",
+		"Born on July twenty first, nineteen ninety nine.": "Born on July 21, 1999.",
+		"Due December thirty first twenty twenty four":     "Due December 31, 2024",
+		"It started June fifth two thousand and five":      "It started June 5, 2005",
+		"You may first want to check":                      "You may first want to check",
+		"one million two hundred thousand":                 "1200000",
+		"zero":                                             "zero",
+		"the twenty-something crowd":                       "the twenty-something crowd",
+		"nineteen ninety nine was a good year":             "1999 was a good year",
+		"it was twenty twenty four":                        "it was 2024",
+		"Back in nineteen oh five.":                        "Back in 1905.",
+		"twenty, twenty four":                              "20, 24",
+		"one two three":                                    "one two three",
+	}
+	for input, expected := range cases {
+		if got := (Numbers{}).Apply(input); got != expected {
+			t.Fatalf("expected %q for %q, got %q", expected, input, got)
+		}
+	}
+}
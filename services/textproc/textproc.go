@@ -0,0 +1,131 @@
+// Package textproc rewrites transcript text after recognition: user
+// replacements, numbers written as digits, masked profanity, dropped fillers
+// and fixed casing. Each rewrite is a Step, and a Chain applies several in
+// order.
+package textproc
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Step is one rewrite of transcript text. Steps must be safe for concurrent
+// use.
+type Step interface {
+	Apply(text string) string
+}
+
+// Chain applies its steps in order.
+type Chain []Step
+
+// Apply runs text through every step of the chain.
+func (c Chain) Apply(text string) string {
+	for _, step := range c {
+		text = step.Apply(text)
+	}
+	return text
+}
+
+// Replacement rewrites every whole-word occurrence of Find, in any case, as
+// Replace.
+type Replacement struct {
+	Find    string `json:"find"`
+	Replace string `json:"replace"`
+}
+
+// Replacements is a user find-and-replace dictionary, applied in order.
+type Replacements struct {
+	patterns []*regexp.Regexp
+	replaces []string
+}
+
+// NewReplacements compiles a dictionary. Entries with an empty Find are
+// skipped.
+func NewReplacements(replacements []Replacement) *Replacements {
+	r := &Replacements{}
+	for _, replacement := range replacements {
+		find := strings.TrimSpace(replacement.Find)
+		if find == "" {
+			continue
+		}
+		// Word boundaries are spelled out because \b only knows ASCII letters.
+		pattern := regexp.MustCompile(`(?i)(^|[^\pL\pN])` + regexp.QuoteMeta(find) + `($|[^\pL\pN])`)
+		r.patterns = append(r.patterns, pattern)
+		r.replaces = append(r.replaces, "${1}"+strings.ReplaceAll(replacement.Replace, "$", "$$")+"${2}")
+	}
+	return r
+}
+
+func (r *Replacements) Apply(text string) string {
+	for i, pattern := range r.patterns {
+		text = pattern.ReplaceAllString(text, r.replaces[i])
+	}
+	return text
+}
+
+// Casing capitalizes the start of every sentence and the pronoun "I". A
+// sentence ends at a period, question mark or exclamation mark followed by
+// whitespace, so that "ekko.app" and initialisms such as "e.g." are left
+// alone.
+type Casing struct{}
+
+// pronounI matches a lowercase "i" standing alone or in a contraction such as
+// "i'm".
+var pronounI = regexp.MustCompile(`(^|[^\pL\pN'])i($|[^\pL\pN]|'[a-z]+)`)
+
+func (Casing) Apply(text string) string {
+	// Every match consumes the character after the "i", so adjacent pronouns
+	// need a second pass.
+	for range 2 {
+		text = pronounI.ReplaceAllString(text, "${1}I${2}")
+	}
+
+	var builder strings.Builder
+	// ended is set after sentence punctuation, which only starts a sentence
+	// once whitespace follows.
+	sentenceStart, ended := true, false
+	wordStart := 0
+	for i, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			sentenceStart = sentenceStart || ended
+			ended = false
+			wordStart = i + utf8.RuneLen(r)
+		case sentenceStart && unicode.IsLetter(r):
+			r = unicode.ToUpper(r)
+			sentenceStart = false
+		case r == '?' || r == '!':
+			ended = true
+		case r == '.':
+			// The second period of a word such as "e.g." ends no sentence.
+			ended = !strings.ContainsRune(text[wordStart:i], '.')
+		case strings.ContainsRune(`"')]”’`, r):
+			// Closing quotes and brackets keep a sentence ended.
+		default:
+			if unicode.IsDigit(r) {
+				sentenceStart = false
+			}
+			ended = false
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// word splits a whitespace-separated field into its leading punctuation, its
+// word and its trailing punctuation.
+func word(field string) (lead, core, trail string) {
+	start := strings.IndexFunc(field, isWordRune)
+	if start < 0 {
+		return field, "", ""
+	}
+	end := strings.LastIndexFunc(field, isWordRune)
+	_, size := utf8.DecodeRuneInString(field[end:])
+	return field[:start], field[start : end+size], field[end+size:]
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\''
+}
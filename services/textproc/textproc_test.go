@@ -0,0 +1,64 @@
+package textproc
+
+import "testing"
+
+func TestReplacementsMatchWholeWords(t *testing.T) {
+	replacements := NewReplacements([]Replacement{
+		{Find: "cube control", Replace: "kubectl"},
+		{Find: "ekko", Replace: "Ekko"},
+		{Find: " ", Replace: "ignored"},
+	})
+
+	got := replacements.Apply("Cube control talks to ekko, not ekkos.")
+	if expected := "kubectl talks to Ekko, not ekkos."; got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestCasingFixesSentencesAndI(t *testing.T) {
+	got := Casing{}.Apply("well, i think so. i'm sure i i did! ok? yes")
+	if expected := "Well, I think so. I'm sure I I did! Ok? Yes"; got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	got = Casing{}.Apply(`see ekko.app, e.g. this one. he said "stop." then left`)
+	if expected := `See ekko.app, e.g. this one. He said "stop." Then left`; got != expected {
+		t.Fatalf("expected only sentence ends to capitalize, got %q", got)
+	}
+}
+
+func TestProfanityMasksWordsAndForms(t *testing.T) {
+	got := NewProfanity(nil).Apply("Oh shit, the fucking build. Scunthorpe passes.")
+	if expected := "Oh s***, the f****** build. Scunthorpe passes."; got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if got := NewProfanity([]string{"darn"}).Apply("Darn, shit."); got != "D***, shit." {
+		t.Fatalf("expected only the given words to be masked, got %q", got)
+	}
+}
+
+func TestFillersKeepPunctuation(t *testing.T) {
+	cases := map[string]string{
+		"Um, so we, uh, ship it.": "so we, ship it.",
+		"I think so, uh.":         "I think so.",
+		"Hmm.":                    "",
+		"The umbrella stays.":     "The umbrella stays.",
+	}
+	for input, expected := range cases {
+		if got := (Fillers{}).Apply(input); got != expected {
+			t.Fatalf("expected %q for %q, got %q", expected, input, got)
+		}
+	}
+}
+
+func TestChainAppliesStepsInOrder(t *testing.T) {
+	chain := Chain{
+		NewReplacements([]Replacement{{Find: "twenty five", Replace: "two dozen"}}),
+		Numbers{},
+		Fillers{},
+		Casing{},
+	}
+	if got := chain.Apply("um, twenty five or thirty five?"); got != "Two dozen or 35?" {
+		t.Fatalf("expected the chain in order, got %q", got)
+	}
+}
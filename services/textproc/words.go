@@ -0,0 +1,87 @@
+package textproc
+
+import (
+	"strings"
+)
+
+// DefaultProfanity are the words Profanity masks when given none.
+var DefaultProfanity = []string{
+	"arse", "arsehole", "asshole", "bastard", "bitch", "bollocks", "bullshit",
+	"cock", "crap", "cunt", "damn", "dick", "fuck", "motherfucker", "piss",
+	"prick", "shit", "slut", "twat", "wanker", "whore",
+}
+
+// profanitySuffixes are the endings under which a listed word is still masked,
+// as in "fucking" or "bitches".
+var profanitySuffixes = []string{"", "s", "es", "ed", "er", "ers", "ing", "in'", "y"}
+
+// Profanity masks listed words, keeping their first letter: "shit" becomes
+// "s***".
+type Profanity struct {
+	words map[string]bool
+}
+
+// NewProfanity masks the given words, or DefaultProfanity when there are none.
+func NewProfanity(words []string) *Profanity {
+	if len(words) == 0 {
+		words = DefaultProfanity
+	}
+
+	p := &Profanity{words: make(map[string]bool)}
+	for _, word := range words {
+		p.words[strings.ToLower(strings.TrimSpace(word))] = true
+	}
+	return p
+}
+
+func (p *Profanity) Apply(text string) string {
+	fields := strings.Fields(text)
+	for i, field := range fields {
+		lead, core, trail := word(field)
+		if p.profane(strings.ToLower(core)) {
+			runes := []rune(core)
+			fields[i] = lead + string(runes[0]) + strings.Repeat("*", len(runes)-1) + trail
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// profane reports whether a lowercase word is a listed word, possibly with a
+// suffix.
+func (p *Profanity) profane(word string) bool {
+	for _, suffix := range profanitySuffixes {
+		if stem, ok := strings.CutSuffix(word, suffix); ok && p.words[stem] {
+			return true
+		}
+	}
+	return false
+}
+
+// fillers are hesitation sounds whisper writes out.
+var fillers = map[string]bool{
+	"ah": true, "eh": true, "er": true, "erm": true, "hm": true, "hmm": true, "mhm": true,
+	"mm": true, "uh": true, "uhm": true, "um": true, "umm": true,
+}
+
+// Fillers drops hesitation sounds such as "um" and "uh", keeping the sentence
+// punctuation around them.
+type Fillers struct{}
+
+func (Fillers) Apply(text string) string {
+	var kept []string
+	for _, field := range strings.Fields(text) {
+		_, core, trail := word(field)
+		if !fillers[strings.ToLower(core)] {
+			kept = append(kept, field)
+			continue
+		}
+
+		// A filler ending a sentence hands its full stop to the word before.
+		end := strings.TrimLeft(trail, ",;")
+		if len(kept) > 0 && end != "" && strings.Trim(end, ".?!") == "" {
+			last := &kept[len(kept)-1]
+			*last = strings.TrimRight(*last, ",;") + end
+		}
+	}
+	return strings.Join(kept, " ")
+}
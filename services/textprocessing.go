@@ -0,0 +1,68 @@
+package services
+
+import "github.com/tuanta7/ekko/services/textproc"
+
+// TextProcessing configures how transcript text is rewritten before it is
+// emitted. The steps run in field order. The zero value rewrites nothing.
+type TextProcessing struct {
+	// Replacements is a find-and-replace dictionary of whole words or
+	// phrases, matched in any case.
+	Replacements []textproc.Replacement `json:"replacements"`
+	// Numbers writes spelled-out numbers, percentages and dates with digits.
+	Numbers bool `json:"numbers"`
+	// Profanity masks swear words, keeping their first letter.
+	Profanity bool `json:"profanity"`
+	// ProfaneWords replace the built-in list of words Profanity masks.
+	ProfaneWords []string `json:"profaneWords,omitempty"`
+	// Fillers drops hesitation sounds such as "um" and "uh".
+	Fillers bool `json:"fillers"`
+	// Casing capitalizes sentences and the pronoun "I".
+	Casing bool `json:"casing"`
+}
+
+// chain builds the steps the settings enable.
+func (p TextProcessing) chain() textproc.Chain {
+	var chain textproc.Chain
+	if len(p.Replacements) > 0 {
+		chain = append(chain, textproc.NewReplacements(p.Replacements))
+	}
+	if p.Numbers {
+		chain = append(chain, textproc.Numbers{})
+	}
+	if p.Profanity {
+		chain = append(chain, textproc.NewProfanity(p.ProfaneWords))
+	}
+	if p.Fillers {
+		chain = append(chain, textproc.Fillers{})
+	}
+	if p.Casing {
+		chain = append(chain, textproc.Casing{})
+	}
+	return chain
+}
+
+// TextProcessing returns the text processing settings.
+func (t *TranscribeService) TextProcessing() (TextProcessing, error) {
+	return t.textProcessing.load()
+}
+
+// SaveTextProcessing replaces the text processing settings. Sessions started
+// from then on use them.
+func (t *TranscribeService) SaveTextProcessing(processing TextProcessing) error {
+	return t.textProcessing.save(processing)
+}
+
+// rewrite runs an event's text through the session's text processing. The
+// recognized text is kept in RawText when it changes.
+func (t *TranscribeSession) rewrite(event TranscriptEvent) TranscriptEvent {
+	if len(t.text) == 0 || event.Annotation != "" {
+		return event
+	}
+
+	text := t.text.Apply(event.Text)
+	if text != event.Text {
+		event.RawText = event.Text
+		event.Text = text
+	}
+	return event
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+	"github.com/tuanta7/ekko/services/chunker"
+	"github.com/tuanta7/ekko/services/textproc"
+)
+
+func TestSettingsStoreSavesTextProcessing(t *testing.T) {
+	store := newSettingsStore[TextProcessing](filepath.Join(t.TempDir(), "ekko", "text-processing.json"))
+	if processing, err := store.load(); err != nil || len(processing.chain()) != 0 {
+		t.Fatalf("expected no rewrites before the first save, got %+v, %v", processing, err)
+	}
+
+	saved := TextProcessing{Replacements: []textproc.Replacement{{Find: "ekko", Replace: "Ekko"}}, Fillers: true}
+	if err := store.save(saved); err != nil {
+		t.Fatal(err)
+	}
+	processing, err := store.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(processing.Replacements) != 1 || !processing.Fillers || processing.Numbers {
+		t.Fatalf("expected the saved settings, got %+v", processing)
+	}
+	if steps := len(processing.chain()); steps != 2 {
+		t.Fatalf("expected 2 steps, got %d", steps)
+	}
+}
+
+func TestProcessRewritesTextKeepingRaw(t *testing.T) {
+	final := newFakeTranscriber(whisper.Result{Segments: []whisper.Segment{{Text: "um, we need twenty five servers."}}})
+	service := &TranscribeService{filter: newHallucinationFilter(nil)}
+	session := NewSession(func() {}, final, final, SessionOptions{}, nil)
+	session.text = TextProcessing{Numbers: true, Fillers: true, Casing: true}.chain()
+
+	service.process(context.Background(), session, Job{ID: 1, Sequence: 1, Chunk: chunker.AudioChunk{
+		Samples:     make([]float32, 16000),
+		UtteranceID: 1,
+		Final:       true,
+	}})
+
+	finals := session.Transcript.finals()
+	if len(finals) != 1 {
+		t.Fatalf("expected one final, got %+v", finals)
+	}
+	if finals[0].Text != "We need 25 servers." || finals[0].RawText != "um, we need twenty five servers." {
+		t.Fatalf("expected the rewritten text with the raw text kept, got %+v", finals[0])
+	}
+	if got := session.Transcript.Render(ExportOptions{RawText: true}); got != "[0:00] um, we need twenty five servers.\n" {
+		t.Fatalf("expected the raw export to show the recognized text, got %q", got)
+	}
+}
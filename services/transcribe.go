@@ -3,24 +3,56 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/tuanta7/ekko/services/adapter/ffmpeg"
-	"github.com/tuanta7/ekko/services/adapter/whisper"
 	"github.com/wailsapp/wails/v3/pkg/application"
 )
 
 type TranscribeService struct {
 	mu  sync.Mutex
 	ctx context.Context
-	app *application.App
+	// cancel ends ctx, aborting every session's inference.
+	cancel context.CancelFunc
+	app    *application.App
 
-	scriber  *whisper.Scriber
 	recorder *ffmpeg.Recorder
 
+	// backends creates the transcription backends sessions can select.
+	backends map[string]TranscriberFactory
+	// transcribers are the backends created so far, by name.
+	transcribers map[string]Transcriber
+	// models are the whisper models sessions picked by name, loaded while a
+	// session or refinement uses them.
+	models map[string]*sharedModel
+	// loads are the backends and models being loaded, which t.mu is not held
+	// for; see startLoad.
+	loads map[string]*pendingLoad
+	// glossaries holds the user's saved glossaries.
+	glossaries *glossaryStore
+	// filter drops hallucinated segments before they reach a transcript.
+	filter *hallucinationFilter
+	// textProcessing holds the settings that rewrite transcript text.
+	textProcessing *settingsStore[TextProcessing]
+	// redaction holds the settings that remove personal data from transcripts.
+	redaction *settingsStore[Redaction]
+	// watchdog aborts inference that takes far longer than its audio.
+	watchdog *inferenceWatchdog
+
 	sessions map[string]*TranscribeSession
+	// transcripts keeps the transcripts of running sessions, and of recently
+	// finished ones so that they can still be exported; see pruneTranscripts.
+	transcripts map[string]*Transcript
+	// refinements cancel the refinements of finished sessions, by session ID.
+	refinements map[string]context.CancelFunc
+	// refining counts the refinements running, which ServiceShutdown waits for
+	// before closing the models they use.
+	refining sync.WaitGroup
 }
 
 var (
@@ -33,13 +65,59 @@ func (t *TranscribeService) ServiceStartup(ctx context.Context, options applicat
 	defer t.mu.Unlock()
 
 	t.app = application.Get()
-	t.ctx = ctx
+	t.ctx, t.cancel = context.WithCancel(ctx)
 	t.recorder = ffmpeg.NewRecorder()
 	t.sessions = make(map[string]*TranscribeSession)
+	t.transcripts = make(map[string]*Transcript)
+	t.refinements = make(map[string]context.CancelFunc)
+	t.transcribers = make(map[string]Transcriber)
+	t.models = make(map[string]*sharedModel)
+	if t.backends == nil {
+		t.backends = defaultBackends()
+	}
+
+	glossaryPath, err := configPath("glossaries.json")
+	if err != nil {
+		return err
+	}
+	t.glossaries = newGlossaryStore(glossaryPath)
+
+	blocklistPath, err := configPath("phantom-phrases.txt")
+	if err != nil {
+		return err
+	}
+	phrases, err := newPhraseBlocklist(blocklistPath)
+	if err != nil {
+		return err
+	}
+	t.filter = newHallucinationFilter(phrases)
+
+	textProcessingPath, err := configPath("text-processing.json")
+	if err != nil {
+		return err
+	}
+	t.textProcessing = newSettingsStore[TextProcessing](textProcessingPath)
+
+	redactionPath, err := configPath("redaction.json")
+	if err != nil {
+		return err
+	}
+	t.redaction = newSettingsStore[Redaction](redactionPath)
+
+	dir, err := debugDir()
+	if err != nil {
+		return err
+	}
+	t.watchdog = newInferenceWatchdog(dir)
 	return nil
 }
 
 func (t *TranscribeService) ServiceShutdown() error {
+	// Abort in-flight inference so that sessions end without waiting for it.
+	if t.cancel != nil {
+		t.cancel()
+	}
+
 	t.mu.Lock()
 	sessions := make([]*TranscribeSession, 0, len(t.sessions))
 	for _, sess := range t.sessions {
@@ -51,14 +129,35 @@ func (t *TranscribeService) ServiceShutdown() error {
 		session.Shutdown()
 	}
 
+	// Sessions that ended before now may still be refined; t.ctx is done, so
+	// no refinement starts after this.
+	t.mu.Lock()
+	for _, cancel := range t.refinements {
+		cancel()
+	}
+	t.mu.Unlock()
+	t.refining.Wait()
+
+	// Backends and models still loading are closed along with the others.
+	t.mu.Lock()
+	loads := slices.Collect(maps.Values(t.loads))
+	t.mu.Unlock()
+	for _, pending := range loads {
+		_ = pending.wait()
+	}
+
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	if t.scriber != nil {
-		err := t.scriber.Close()
-		t.scriber = nil
-		return err
+	var errs []error
+	for name, transcriber := range t.transcribers {
+		errs = append(errs, transcriber.Close())
+		delete(t.transcribers, name)
 	}
-	return nil
+	for name, shared := range t.models {
+		errs = append(errs, shared.Close())
+		delete(t.models, name)
+	}
+	return errors.Join(errs...)
 }
 
 func (t *TranscribeService) ListSources() ([]string, error) {
@@ -68,24 +167,9 @@ func (t *TranscribeService) ListSources() ([]string, error) {
 	return t.recorder.ListSources(ctx)
 }
 
-func (t *TranscribeService) initScriber() error {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-
-	if t.scriber != nil {
-		return nil
-	}
-
-	scriber, err := whisper.NewScriber()
-	if err != nil {
-		return err
-	}
-
-	t.scriber = scriber
-	return nil
-}
-
-func (t *TranscribeService) Start(source string) (string, error) {
+// Start records from source, or the first available source when it is empty,
+// and transcribes it with the given options. It returns the new session ID.
+func (t *TranscribeService) Start(source string, options SessionOptions) (string, error) {
 	source = strings.TrimSpace(source)
 	if source == "" {
 		sources, err := t.ListSources()
@@ -105,9 +189,58 @@ func (t *TranscribeService) Start(source string) (string, error) {
 	}
 	t.mu.Unlock()
 
-	if err := t.initScriber(); err != nil {
+	// The models are handed back when the session ends, or right away when it
+	// fails to start.
+	var held []Transcriber
+	started := false
+	defer func() {
+		if !started {
+			t.releaseModels(held...)
+		}
+	}()
+
+	partial, err := t.modelTranscriber(options.Backend, options.PartialModel)
+	if err != nil {
+		return "", err
+	}
+	held = append(held, partial)
+	final, err := t.modelTranscriber(options.Backend, options.FinalModel)
+	if err != nil {
+		return "", err
+	}
+	held = append(held, final)
+	if err := options.validate(partial.Capabilities()); err != nil {
+		return "", fmt.Errorf("partial model: %w", err)
+	}
+	if err := options.validate(final.Capabilities()); err != nil {
+		return "", err
+	}
+	var refiner Transcriber
+	if options.RefineModel != "" {
+		if refiner, err = t.modelTranscriber(options.Backend, options.RefineModel); err != nil {
+			return "", err
+		}
+		held = append(held, refiner)
+		if err := options.validate(refiner.Capabilities()); err != nil {
+			return "", fmt.Errorf("refine model: %w", err)
+		}
+	}
+	terms, err := t.sessionTerms(options)
+	if err != nil {
+		return "", err
+	}
+	processing, err := t.textProcessing.load()
+	if err != nil {
+		return "", err
+	}
+	redaction, err := t.redaction.load()
+	if err != nil {
 		return "", err
 	}
+	redactor, err := redaction.redactor()
+	if err != nil {
+		return "", fmt.Errorf("redaction: %w", err)
+	}
 
 	ctx, cancel := context.WithCancel(t.ctx)
 	frames, recorderErrs, err := t.recorder.Stream(ctx, source)
@@ -116,11 +249,22 @@ func (t *TranscribeService) Start(source string) (string, error) {
 		return "", err
 	}
 
-	session := NewSession(cancel)
+	session := NewSession(cancel, partial, final, options, terms)
+	if refiner != nil {
+		if err := session.refineWith(refiner); err != nil {
+			cancel()
+			return "", err
+		}
+	}
+	session.text = processing.chain()
+	session.redactor = redactor
 
 	t.mu.Lock()
 	t.sessions[session.ID] = session
+	t.transcripts[session.ID] = session.Transcript
+	t.pruneTranscripts(time.Now())
 	t.mu.Unlock()
+	started = true
 
 	// Notify listeners that audio recording has started for this session.
 	t.emitState(session.ID, EventRecording, "Recording started")
@@ -140,3 +284,33 @@ func (t *TranscribeService) Stop(sessionID string) error {
 	session.Cancel()
 	return nil
 }
+
+// Stats reports the transcription backlog of a running session.
+func (t *TranscribeService) Stats(sessionID string) (SessionStats, error) {
+	t.mu.Lock()
+	session, ok := t.sessions[sessionID]
+	t.mu.Unlock()
+	if !ok {
+		return SessionStats{}, errors.New("transcription session not found")
+	}
+
+	stats := session.queue.stats()
+	stats.PartialModelBytes = memoryBytes(session.partialTranscriber)
+	stats.FinalModelBytes = memoryBytes(session.transcriber)
+	stats.RealTimeFactor = session.load.realTimeFactor()
+	return stats, nil
+}
+
+// sessionTerms returns the terms of the session's saved glossary followed by
+// its own terms.
+func (t *TranscribeService) sessionTerms(options SessionOptions) ([]string, error) {
+	if options.Glossary == "" {
+		return options.Terms, nil
+	}
+
+	glossary, err := t.glossaries.get(options.Glossary)
+	if err != nil {
+		return nil, err
+	}
+	return append(glossary.Terms, options.Terms...), nil
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+
+	"github.com/tuanta7/ekko/services/adapter/openai"
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+)
+
+const (
+	// DefaultBackend is the transcription backend of sessions that do not pick
+	// one: whisper.cpp in process.
+	DefaultBackend = "whisper"
+	// OpenAIBackend sends chunks to an OpenAI-compatible server configured with
+	// EKKO_OPENAI_URL.
+	OpenAIBackend = "openai"
+)
+
+// Transcriber turns audio chunks into text. Implementations must be safe for
+// concurrent use.
+type Transcriber interface {
+	// Transcribe transcribes 16 kHz mono samples. It calls speech.Started
+	// once inference begins, after any wait for the backend, and returns ctx's
+	// error promptly once ctx is done.
+	Transcribe(ctx context.Context, samples []float32, options whisper.TranscribeOptions) (whisper.Result, error)
+	// Capabilities reports what the backend supports.
+	Capabilities() whisper.Capabilities
+	// Close waits for in-flight transcriptions and frees the backend.
+	Close() error
+}
+
+var (
+	_ Transcriber = (*whisper.Scriber)(nil)
+	_ Transcriber = (*openai.Client)(nil)
+)
+
+// TranscriberFactory creates a backend the first time a session uses it.
+type TranscriberFactory func() (Transcriber, error)
+
+// defaultBackends returns the backends available to sessions. The OpenAI
+// backend is only offered when a server is configured.
+func defaultBackends() map[string]TranscriberFactory {
+	backends := map[string]TranscriberFactory{
+		DefaultBackend: func() (Transcriber, error) {
+			return whisper.NewScriber(whisper.ScriberOptions{})
+		},
+	}
+	if config := openai.ConfigFromEnv(); config.URL != "" {
+		backends[OpenAIBackend] = func() (Transcriber, error) {
+			return openai.NewClient(config)
+		}
+	}
+	return backends
+}
+
+// Backends returns the names of the backends a session can be started with.
+func (t *TranscribeService) Backends() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return slices.Sorted(maps.Keys(t.backends))
+}
+
+// setBackend replaces the factory of the named backend and closes the backend
+// if it was created, so that the next session creates it anew. It fails while
+// a session is running. The backend is closed after t.mu is released, since
+// freeing a model can take a while.
+func (t *TranscribeService) setBackend(name string, factory TranscriberFactory) error {
+	t.mu.Lock()
+	if _, loading := t.loads[backendLoad+name]; len(t.sessions) > 0 || loading {
+		t.mu.Unlock()
+		return errors.New("cannot switch backends while a transcription session is running")
+	}
+	t.backends[name] = factory
+	transcriber, ok := t.transcribers[name]
+	delete(t.transcribers, name)
+	t.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return transcriber.Close()
+}
+
+// sharedModel is a whisper scriber loaded for a session's partial, final or
+// refine model, shared by the sessions and refinements using that model.
+type sharedModel struct {
+	Transcriber
+	name string
+	// users counts the sessions and refinements holding the model.
+	users int
+}
+
+// modelTranscriber returns the transcriber of a session's partial, final or
+// refine chunks: the named backend, or a whisper scriber for model when one is
+// given. Sessions with the same model share its scriber; each hands it back
+// with releaseModels once done, and the last one to do so closes it. Models
+// load without holding t.mu, and sessions after a model being loaded wait for
+// it.
+func (t *TranscribeService) modelTranscriber(backend, model string) (Transcriber, error) {
+	if model == "" {
+		return t.transcriber(backend)
+	}
+	if backend != "" && backend != DefaultBackend {
+		return nil, fmt.Errorf("the %s backend does not take a model", backend)
+	}
+
+	for {
+		t.mu.Lock()
+		if shared, ok := t.models[model]; ok {
+			shared.users++
+			t.mu.Unlock()
+			return shared, nil
+		}
+		pending, first := t.startLoad(modelLoad + model)
+		t.mu.Unlock()
+		if !first {
+			// Another session is loading the model; look again once it is
+			// done.
+			if err := pending.wait(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var shared *sharedModel
+		scriber, err := loadModel(model)
+		t.mu.Lock()
+		if err == nil {
+			shared = &sharedModel{Transcriber: scriber, name: model, users: 1}
+			if !pending.stale {
+				t.models[model] = shared
+			}
+		}
+		t.finishLoad(modelLoad+model, pending, err)
+		t.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		return shared, nil
+	}
+}
+
+// loadModel loads a whisper scriber for the named model.
+func loadModel(model string) (Transcriber, error) {
+	path, err := whisper.FindModel(whisper.ModelDirs(), model)
+	if err != nil {
+		return nil, err
+	}
+	scriber, err := whisper.NewScriber(whisper.ScriberOptions{ModelPath: path})
+	if err != nil {
+		return nil, fmt.Errorf("model %s: %w", model, err)
+	}
+	return scriber, nil
+}
+
+// Prefixes of the keys of t.loads.
+const (
+	backendLoad = "backend:"
+	modelLoad   = "model:"
+)
+
+// pendingLoad is a backend or model being loaded. Loading a model takes
+// seconds, so t.mu is not held for it; callers after the same one wait for it
+// instead of loading it again.
+type pendingLoad struct {
+	done chan struct{}
+	err  error
+	// stale is set when the model is evicted while it loads, so that it is
+	// only used by the session that loaded it.
+	stale bool
+}
+
+// wait waits for the load to finish and returns its error.
+func (p *pendingLoad) wait() error {
+	<-p.done
+	return p.err
+}
+
+// startLoad registers a load of key and reports whether the caller is the
+// first to want it. Otherwise it returns the load in progress. t.mu must be
+// held.
+func (t *TranscribeService) startLoad(key string) (*pendingLoad, bool) {
+	if pending, ok := t.loads[key]; ok {
+		return pending, false
+	}
+	if t.loads == nil {
+		t.loads = make(map[string]*pendingLoad)
+	}
+	pending := &pendingLoad{done: make(chan struct{})}
+	t.loads[key] = pending
+	return pending, true
+}
+
+// finishLoad ends a load started with startLoad and wakes the callers waiting
+// for it. t.mu must be held.
+func (t *TranscribeService) finishLoad(key string, pending *pendingLoad, err error) {
+	delete(t.loads, key)
+	pending.err = err
+	close(pending.done)
+}
+
+// releaseModels hands back transcribers returned by modelTranscriber, closing
+// the models nothing holds any more. Backends are left loaded.
+func (t *TranscribeService) releaseModels(transcribers ...Transcriber) {
+	var unused []*sharedModel
+	t.mu.Lock()
+	for _, transcriber := range transcribers {
+		shared, ok := transcriber.(*sharedModel)
+		if !ok {
+			continue
+		}
+		if shared.users--; shared.users == 0 {
+			if t.models[shared.name] == shared {
+				delete(t.models, shared.name)
+			}
+			unused = append(unused, shared)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, shared := range unused {
+		if err := shared.Close(); err != nil {
+			log.Printf("close model %s: %v", shared.name, err)
+		}
+	}
+}
+
+// evictModel makes the next session load the named model from disk again, as
+// it was installed or switched to. Sessions and refinements already using the
+// previous copy keep it until they release it.
+func (t *TranscribeService) evictModel(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.models, name)
+	if pending, ok := t.loads[modelLoad+name]; ok {
+		pending.stale = true
+	}
+}
+
+// memoryReporter is implemented by backends that hold models in memory.
+type memoryReporter interface {
+	// MemoryBytes estimates the memory taken by the backend's models.
+	MemoryBytes() int64
+}
+
+// memoryBytes returns the memory a backend's models take, or zero for
+// backends that run elsewhere.
+func memoryBytes(transcriber Transcriber) int64 {
+	if shared, ok := transcriber.(*sharedModel); ok {
+		transcriber = shared.Transcriber
+	}
+	if reporter, ok := transcriber.(memoryReporter); ok {
+		return reporter.MemoryBytes()
+	}
+	return 0
+}
+
+// transcriber returns the named backend, creating it on first use without
+// holding t.mu. Backends stay loaded until the service shuts down.
+func (t *TranscribeService) transcriber(name string) (Transcriber, error) {
+	if name == "" {
+		name = DefaultBackend
+	}
+
+	for {
+		t.mu.Lock()
+		if transcriber, ok := t.transcribers[name]; ok {
+			t.mu.Unlock()
+			return transcriber, nil
+		}
+		factory, ok := t.backends[name]
+		if !ok {
+			t.mu.Unlock()
+			return nil, fmt.Errorf("unknown transcription backend %q", name)
+		}
+		pending, first := t.startLoad(backendLoad + name)
+		t.mu.Unlock()
+		if !first {
+			if err := pending.wait(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		transcriber, err := factory()
+		if err != nil {
+			err = fmt.Errorf("%s backend: %w", name, err)
+		}
+		t.mu.Lock()
+		if err == nil {
+			t.transcribers[name] = transcriber
+		}
+		t.finishLoad(backendLoad+name, pending, err)
+		t.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		return transcriber, nil
+	}
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/speech"
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+)
+
+// fakeTranscriber returns canned results and records how it was called.
+type fakeTranscriber struct {
+	mu sync.Mutex
+	// results are returned in order; the last one repeats.
+	results      []whisper.Result
+	capabilities whisper.Capabilities
+	calls        []whisper.TranscribeOptions
+	closed       bool
+	// hang blocks every call until its context is done, like inference stuck
+	// in a loop.
+	hang bool
+	// queue delays the start of every call's inference, like waiting for a
+	// free whisper context.
+	queue time.Duration
+}
+
+var _ Transcriber = (*fakeTranscriber)(nil)
+
+func newFakeTranscriber(results ...whisper.Result) *fakeTranscriber {
+	return &fakeTranscriber{
+		results:      results,
+		capabilities: whisper.Capabilities{Languages: []string{"en"}, WordTimestamps: true, Concurrency: 1},
+	}
+}
+
+func (f *fakeTranscriber) Transcribe(ctx context.Context, _ []float32, options whisper.TranscribeOptions) (whisper.Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	time.Sleep(f.queue)
+	speech.Started(ctx)
+	if f.hang {
+		<-ctx.Done()
+	}
+	if err := ctx.Err(); err != nil {
+		return whisper.Result{}, err
+	}
+	f.calls = append(f.calls, options)
+	if len(f.results) == 0 {
+		return whisper.Result{}, nil
+	}
+	result := f.results[0]
+	if len(f.results) > 1 {
+		f.results = f.results[1:]
+	}
+	return result, nil
+}
+
+func (f *fakeTranscriber) Capabilities() whisper.Capabilities {
+	return f.capabilities
+}
+
+func (f *fakeTranscriber) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	return nil
+}
+
+// options returns the options of every call so far.
+func (f *fakeTranscriber) options() []whisper.TranscribeOptions {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]whisper.TranscribeOptions(nil), f.calls...)
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+	"github.com/tuanta7/ekko/services/chunker"
+)
+
+func TestTranscriberCreatesBackendsOnce(t *testing.T) {
+	fake := newFakeTranscriber()
+	created := 0
+	service := &TranscribeService{
+		backends: map[string]TranscriberFactory{
+			DefaultBackend: func() (Transcriber, error) {
+				created++
+				return fake, nil
+			},
+		},
+		transcribers: make(map[string]Transcriber),
+	}
+
+	for range 2 {
+		transcriber, err := service.transcriber("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if transcriber != fake {
+			t.Fatalf("expected the default backend, got %v", transcriber)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("expected the backend to be created once, got %d", created)
+	}
+	if _, err := service.transcriber("missing"); err == nil {
+		t.Fatal("expected an unknown backend to be rejected")
+	}
+
+	if err := service.ServiceShutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if !fake.closed {
+		t.Fatal("expected shutdown to close the backend")
+	}
+}
+
+func TestTranscriberLoadsBackendsOutsideTheLock(t *testing.T) {
+	fake := newFakeTranscriber()
+	loading, release := make(chan struct{}), make(chan struct{})
+	var created atomic.Int32
+	service := &TranscribeService{
+		backends: map[string]TranscriberFactory{
+			DefaultBackend: func() (Transcriber, error) {
+				if created.Add(1) == 1 {
+					close(loading)
+				}
+				<-release
+				return fake, nil
+			},
+		},
+		transcribers: make(map[string]Transcriber),
+	}
+
+	results := make(chan Transcriber, 2)
+	for range 2 {
+		go func() {
+			transcriber, _ := service.transcriber("")
+			results <- transcriber
+		}()
+	}
+	<-loading
+	// The service stays usable while the backend loads.
+	if backends := service.Backends(); len(backends) != 1 {
+		t.Fatalf("expected the default backend, got %v", backends)
+	}
+	close(release)
+
+	for range 2 {
+		if transcriber := <-results; transcriber != fake {
+			t.Fatalf("expected both callers to get the loaded backend, got %v", transcriber)
+		}
+	}
+	if created.Load() != 1 {
+		t.Fatalf("expected the backend to be created once, got %d", created.Load())
+	}
+}
+
+func TestProcessTranscribesFinalsWithSessionBackend(t *testing.T) {
+	fake := newFakeTranscriber(whisper.Result{
+		Language: "en",
+		Segments: []whisper.Segment{{
+			Text:   "Ship it on Friday.",
+			End:    time.Second,
+			Tokens: []whisper.Token{{Text: " Ship", Probability: 0.9}, {Text: " it", Probability: 0.9}},
+		}},
+	})
+	service := &TranscribeService{filter: newHallucinationFilter(nil)}
+	session := NewSession(func() {}, fake, fake, SessionOptions{}, []string{"Friday"})
+
+	service.process(context.Background(), session, Job{ID: 1, Sequence: 1, Chunk: chunker.AudioChunk{
+		Samples:     make([]float32, 16000),
+		UtteranceID: 1,
+		Revision:    1,
+		Final:       true,
+		End:         time.Second,
+	}})
+
+	calls := fake.options()
+	if len(calls) != 1 {
+		t.Fatalf("expected one transcription, got %d", len(calls))
+	}
+	if !calls[0].TokenTimestamps || calls[0].Decode != whisper.DefaultFinalDecodeOptions() {
+		t.Fatalf("expected a final decode with token timestamps, got %+v", calls[0])
+	}
+	if calls[0].InitialPrompt != "Friday." {
+		t.Fatalf("expected the glossary as prompt, got %q", calls[0].InitialPrompt)
+	}
+
+	if text := session.Transcript.Render(ExportOptions{}); text != "[0:00] Ship it on Friday.\n" {
+		t.Fatalf("expected the final in the transcript, got %q", text)
+	}
+}
+
+func TestProcessKeepsRepeatedWordsOfPromptedFinals(t *testing.T) {
+	fake := newFakeTranscriber(whisper.Result{Segments: []whisper.Segment{{
+		Text:   "No no no, not on Friday.",
+		End:    time.Second,
+		Tokens: []whisper.Token{{Text: " No"}, {Text: " no"}, {Text: " no,"}, {Text: " not"}},
+	}}})
+	service := &TranscribeService{filter: newHallucinationFilter(nil)}
+	session := NewSession(func() {}, fake, fake, SessionOptions{}, []string{"Friday"})
+
+	service.process(context.Background(), session, Job{ID: 1, Sequence: 1, Chunk: chunker.AudioChunk{
+		Samples:     make([]float32, 16000),
+		UtteranceID: 1,
+		Final:       true,
+		End:         time.Second,
+	}})
+
+	if calls := fake.options(); len(calls) != 1 {
+		t.Fatalf("expected no retry without the prompt, got %d transcriptions", len(calls))
+	}
+	final := session.Transcript.finals()[0]
+	if final.Text != "No no no, not on Friday." || len(final.Words) == 0 {
+		t.Fatalf("expected the repeated words kept with their timings, got %+v", final)
+	}
+	if prompt := session.prompt.prompt(); !strings.Contains(prompt, "No no no") {
+		t.Fatalf("expected the final carried into the prompt, got %q", prompt)
+	}
+}
+
+func TestProcessSkipsPartialsOfStoppedSessions(t *testing.T) {
+	fake := newFakeTranscriber(whisper.Result{Segments: []whisper.Segment{{Text: "Hello"}}})
+	service := &TranscribeService{filter: newHallucinationFilter(nil)}
+	session := NewSession(func() {}, fake, fake, SessionOptions{}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	service.process(ctx, session, Job{ID: 1, Chunk: chunker.AudioChunk{
+		Samples:     make([]float32, 16000),
+		UtteranceID: 1,
+		Revision:    1,
+	}})
+	if calls := fake.options(); len(calls) != 0 {
+		t.Fatalf("expected no transcription, got %d", len(calls))
+	}
+
+	// A final aborted by shutdown is dropped quietly and does not hold back
+	// the finals after it.
+	service.process(ctx, session, Job{ID: 2, Sequence: 1, Chunk: chunker.AudioChunk{
+		Samples:     make([]float32, 16000),
+		UtteranceID: 1,
+		Revision:    2,
+		Final:       true,
+	}})
+	service.process(context.Background(), session, Job{ID: 3, Sequence: 2, Chunk: chunker.AudioChunk{
+		Samples:     make([]float32, 16000),
+		UtteranceID: 2,
+		Revision:    1,
+		Final:       true,
+	}})
+	if text := session.Transcript.Render(ExportOptions{}); text != "[0:00] Hello\n" {
+		t.Fatalf("expected only the second final in the transcript, got %q", text)
+	}
+}
+
+func TestProcessRoutesChunksByModel(t *testing.T) {
+	partial := newFakeTranscriber(whisper.Result{Segments: []whisper.Segment{{Text: "Hello"}}})
+	final := newFakeTranscriber(whisper.Result{Segments: []whisper.Segment{{Text: "Hello there."}}})
+	service := &TranscribeService{
+		filter: newHallucinationFilter(nil),
+		models: map[string]*sharedModel{
+			"tiny.en":  {Transcriber: partial, name: "tiny.en", users: 1},
+			"small.en": {Transcriber: final, name: "small.en", users: 1},
+		},
+	}
+
+	partialTranscriber, err := service.modelTranscriber("", "tiny.en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	finalTranscriber, err := service.modelTranscriber(DefaultBackend, "small.en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.modelTranscriber(OpenAIBackend, "small.en"); err == nil {
+		t.Fatal("expected a remote backend to reject a model")
+	}
+
+	session := NewSession(func() {}, partialTranscriber, finalTranscriber, SessionOptions{}, nil)
+	service.process(context.Background(), session, Job{ID: 1, Chunk: chunker.AudioChunk{
+		Samples:     make([]float32, 16000),
+		UtteranceID: 1,
+		Revision:    1,
+	}})
+	service.process(context.Background(), session, Job{ID: 2, Sequence: 1, Chunk: chunker.AudioChunk{
+		Samples:     make([]float32, 16000),
+		UtteranceID: 1,
+		Revision:    2,
+		Final:       true,
+	}})
+
+	if calls := partial.options(); len(calls) != 1 || calls[0].TokenTimestamps {
+		t.Fatalf("expected the partial on the partial model, got %+v", calls)
+	}
+	if calls := final.options(); len(calls) != 1 || !calls[0].TokenTimestamps {
+		t.Fatalf("expected the final on the final model, got %+v", calls)
+	}
+}
+
+func TestModelsCloseOnceReleasedOrEvicted(t *testing.T) {
+	model := newFakeTranscriber()
+	service := &TranscribeService{models: map[string]*sharedModel{
+		"small.en": {Transcriber: model, name: "small.en", users: 1},
+	}}
+
+	held, err := service.modelTranscriber("", "small.en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.releaseModels(held)
+	if model.closed || len(service.models) != 1 {
+		t.Fatal("expected the model to stay loaded while a session holds it")
+	}
+
+	service.evictModel("small.en")
+	if len(service.models) != 0 || model.closed {
+		t.Fatal("expected an evicted model to be loaded anew but kept for its session")
+	}
+	service.releaseModels(held)
+	if !model.closed {
+		t.Fatal("expected the model to close once no session holds it")
+	}
+}
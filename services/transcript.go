@@ -0,0 +1,254 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tuanta7/ekko/services/chunker"
+)
+
+// ExportOptions controls how a session transcript is rendered by Export.
+type ExportOptions struct {
+	// IncludeAnnotations keeps music, noise and silence markers in the output.
+	IncludeAnnotations bool `json:"includeAnnotations"`
+	// RawText renders the text as recognized, before text processing, for
+	// auditing the rewrites.
+	RawText bool `json:"rawText"`
+}
+
+const (
+	// maxTranscripts is the number of finished sessions whose transcripts are
+	// kept for exporting.
+	maxTranscripts = 20
+	// transcriptRetention is how long the transcript of a finished session is
+	// kept for exporting.
+	transcriptRetention = 24 * time.Hour
+)
+
+// Transcript collects the final events of one session in utterance order.
+type Transcript struct {
+	mu sync.Mutex
+	// utterances hold the events of each utterance, in order, and byID finds
+	// them by utterance ID.
+	utterances []*utterance
+	byID       map[int64]*utterance
+	// names are the names given to speakers by their number.
+	names map[int]string
+	// ended is when the session stopped, or zero while it runs.
+	ended time.Time
+}
+
+// utterance is the final of one utterance, as one event per speaker part.
+type utterance struct {
+	events []TranscriptEvent
+}
+
+// add records a final event, replacing an earlier final of the same utterance
+// and part.
+func (t *Transcript) add(event TranscriptEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u := t.utterance(event.UtteranceID)
+	for i := range u.events {
+		if u.events[i].Part == event.Part {
+			u.events[i] = event
+			return
+		}
+	}
+	u.events = append(u.events, event)
+}
+
+// replace swaps every part of an utterance's final for events, which are parts
+// of the same utterance.
+func (t *Transcript) replace(utteranceID int64, events []TranscriptEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.utterance(utteranceID).events = slices.Clone(events)
+}
+
+// utterance returns the utterance with the given ID, adding it after the
+// others when it is new. t.mu must be held.
+func (t *Transcript) utterance(id int64) *utterance {
+	if u, ok := t.byID[id]; ok {
+		return u
+	}
+	if t.byID == nil {
+		t.byID = make(map[int64]*utterance)
+	}
+	u := &utterance{}
+	t.byID[id] = u
+	t.utterances = append(t.utterances, u)
+	return u
+}
+
+// events returns every event in order. t.mu must be held.
+func (t *Transcript) events() []TranscriptEvent {
+	var events []TranscriptEvent
+	for _, u := range t.utterances {
+		events = append(events, u.events...)
+	}
+	return events
+}
+
+// end records when the session stopped.
+func (t *Transcript) end(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.ended = at
+}
+
+// endedAt returns when the session stopped, or zero while it runs.
+func (t *Transcript) endedAt() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.ended
+}
+
+// Render formats the transcript as one timestamped line per utterance, or per
+// part when speakers are labelled. A change of speaker opens a new paragraph
+// that names the speaker.
+func (t *Transcript) Render(options ExportOptions) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var builder strings.Builder
+	speaker := 0
+	for _, event := range t.events() {
+		if event.Annotation != "" && !options.IncludeAnnotations {
+			continue
+		}
+
+		text := event.Text
+		if options.RawText && event.RawText != "" {
+			text = event.RawText
+		}
+		if event.Speaker != 0 && event.Speaker != speaker {
+			if builder.Len() > 0 {
+				builder.WriteString("\n")
+			}
+			text = t.speakerName(event.Speaker) + ": " + text
+			speaker = event.Speaker
+		}
+		fmt.Fprintf(&builder, "[%s] %s\n", formatOffset(time.Duration(event.StartMs)*time.Millisecond), text)
+	}
+	return builder.String()
+}
+
+// rename names a speaker; an empty name goes back to the numbered label.
+func (t *Transcript) rename(speaker int, name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if name == "" {
+		delete(t.names, speaker)
+		return
+	}
+	if t.names == nil {
+		t.names = make(map[int]string)
+	}
+	t.names[speaker] = name
+}
+
+// speakerName returns the name given to a speaker, or "Speaker N". t.mu must
+// be held.
+func (t *Transcript) speakerName(speaker int) string {
+	if name, ok := t.names[speaker]; ok {
+		return name
+	}
+	return fmt.Sprintf("Speaker %d", speaker)
+}
+
+// Export renders the final transcript of a running session, or of one of the
+// last maxTranscripts sessions that stopped within transcriptRetention.
+func (t *TranscribeService) Export(sessionID string, options ExportOptions) (string, error) {
+	t.mu.Lock()
+	transcript, ok := t.transcripts[sessionID]
+	t.mu.Unlock()
+	if !ok {
+		return "", errors.New("transcript not found")
+	}
+
+	return transcript.Render(options), nil
+}
+
+// RenameSpeaker names a speaker of a running or finished session, such as
+// "Alice" for Speaker 1. Exports use the name from then on; an empty name
+// restores the numbered label.
+func (t *TranscribeService) RenameSpeaker(sessionID string, speaker int, name string) error {
+	if speaker < 1 {
+		return errors.New("speakers are numbered from 1")
+	}
+	name = strings.TrimSpace(name)
+	if strings.ContainsAny(name, "\r\n") {
+		return errors.New("speaker names cannot span lines")
+	}
+
+	t.mu.Lock()
+	transcript, ok := t.transcripts[sessionID]
+	t.mu.Unlock()
+	if !ok {
+		return errors.New("transcript not found")
+	}
+
+	transcript.rename(speaker, name)
+	return nil
+}
+
+// pruneTranscripts forgets the transcripts of finished sessions that stopped
+// more than transcriptRetention before now, and of all but the
+// maxTranscripts that stopped last. Running sessions and sessions being
+// refined keep theirs. t.mu must be held.
+func (t *TranscribeService) pruneTranscripts(now time.Time) {
+	type finished struct {
+		id    string
+		ended time.Time
+	}
+	var kept []finished
+	for id, transcript := range t.transcripts {
+		if _, running := t.sessions[id]; running {
+			continue
+		}
+		if _, refining := t.refinements[id]; refining {
+			continue
+		}
+		ended := transcript.endedAt()
+		if now.Sub(ended) > transcriptRetention {
+			delete(t.transcripts, id)
+			continue
+		}
+		kept = append(kept, finished{id: id, ended: ended})
+	}
+	if len(kept) <= maxTranscripts {
+		return
+	}
+
+	slices.SortFunc(kept, func(a, b finished) int { return b.ended.Compare(a.ended) })
+	for _, transcript := range kept[maxTranscripts:] {
+		delete(t.transcripts, transcript.id)
+	}
+}
+
+// annotationText labels a non-speech stretch, e.g. "[music 00:42]" or "[silence 3m]".
+func annotationText(class chunker.SoundClass, duration time.Duration) string {
+	if duration < time.Minute {
+		return fmt.Sprintf("[%s 00:%02d]", class, int(duration/time.Second))
+	}
+	return fmt.Sprintf("[%s %dm]", class, int(duration/time.Minute))
+}
+
+// formatOffset formats a session offset as m:ss, or h:mm:ss past the first hour.
+func formatOffset(offset time.Duration) string {
+	seconds := int(offset / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
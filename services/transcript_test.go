@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tuanta7/ekko/services/chunker"
+)
+
+func TestTranscriptRenderTogglesAnnotations(t *testing.T) {
+	transcript := &Transcript{}
+	transcript.add(TranscriptEvent{UtteranceID: 1, Text: "hello there", StartMs: 1200})
+	transcript.add(TranscriptEvent{UtteranceID: 2, Text: "[music 00:42]", Annotation: "music", StartMs: 5000})
+	transcript.add(TranscriptEvent{UtteranceID: 3, Text: "welcome back", StartMs: 3_725_000})
+	transcript.add(TranscriptEvent{UtteranceID: 1, Text: "hello there everyone", StartMs: 1200})
+
+	if got, expected := transcript.Render(ExportOptions{}), "[0:01] hello there everyone\n[1:02:05] welcome back\n"; got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	expected := "[0:01] hello there everyone\n[0:05] [music 00:42]\n[1:02:05] welcome back\n"
+	if got := transcript.Render(ExportOptions{IncludeAnnotations: true}); got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestAnnotationTextUsesMinutesForLongStretches(t *testing.T) {
+	if got := annotationText(chunker.SoundMusic, 42*time.Second); got != "[music 00:42]" {
+		t.Fatalf("expected [music 00:42], got %s", got)
+	}
+	if got := annotationText(chunker.SoundSilence, 3*time.Minute+20*time.Second); got != "[silence 3m]" {
+		t.Fatalf("expected [silence 3m], got %s", got)
+	}
+}
+
+func TestTranscriptRenderStartsParagraphsAtSpeakerChanges(t *testing.T) {
+	transcript := &Transcript{}
+	transcript.add(TranscriptEvent{UtteranceID: 1, Text: "Are you ready?", Speaker: 1})
+	transcript.add(TranscriptEvent{UtteranceID: 1, Part: 1, Text: "Almost.", Speaker: 2, StartMs: 1500})
+	transcript.add(TranscriptEvent{UtteranceID: 2, Text: "Give me a minute.", Speaker: 2, StartMs: 3000})
+	transcript.add(TranscriptEvent{UtteranceID: 3, Text: "Sure.", Speaker: 1, StartMs: 5000})
+
+	expected := "[0:00] Speaker 1: Are you ready?\n\n[0:01] Speaker 2: Almost.\n[0:03] Give me a minute.\n\n[0:05] Speaker 1: Sure.\n"
+	if got := transcript.Render(ExportOptions{}); got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	transcript.replace(1, []TranscriptEvent{{UtteranceID: 1, Text: "Are you ready? Almost.", Speaker: 1}})
+	if finals := transcript.finals(); len(finals) != 3 || finals[0].Text != "Are you ready? Almost." {
+		t.Fatalf("expected the refined utterance in place of both parts, got %+v", finals)
+	}
+}
+
+func TestPruneTranscriptsKeepsRecentAndRunningSessions(t *testing.T) {
+	now := time.Now()
+	service := &TranscribeService{
+		sessions:    map[string]*TranscribeSession{"running": {}},
+		refinements: map[string]context.CancelFunc{"refining": func() {}},
+		transcripts: map[string]*Transcript{
+			"running":  {},
+			"refining": {ended: now.Add(-2 * transcriptRetention)},
+			"stale":    {ended: now.Add(-2 * transcriptRetention)},
+		},
+	}
+	for i := range maxTranscripts + 1 {
+		service.transcripts[fmt.Sprint(i)] = &Transcript{ended: now.Add(-time.Duration(i) * time.Minute)}
+	}
+
+	service.pruneTranscripts(now)
+
+	for _, id := range []string{"running", "refining", "0", fmt.Sprint(maxTranscripts - 1)} {
+		if _, ok := service.transcripts[id]; !ok {
+			t.Fatalf("expected the transcript of %s to be kept", id)
+		}
+	}
+	for _, id := range []string{"stale", fmt.Sprint(maxTranscripts)} {
+		if _, ok := service.transcripts[id]; ok {
+			t.Fatalf("expected the transcript of %s to be dropped", id)
+		}
+	}
+}
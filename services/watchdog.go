@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/ffmpeg"
+	"github.com/tuanta7/ekko/services/adapter/speech"
+)
+
+const (
+	// DefaultInferenceTimeoutFactor is how many times its duration the
+	// inference of a chunk may take, on top of inferenceGrace, before the
+	// watchdog aborts it. EKKO_INFERENCE_TIMEOUT_FACTOR overrides it.
+	DefaultInferenceTimeoutFactor = 10
+	// inferenceGrace covers the fixed cost of inference, which dominates on
+	// short chunks.
+	inferenceGrace = 10 * time.Second
+)
+
+// ErrInferenceTimeout reports a chunk whose inference the watchdog aborted.
+var ErrInferenceTimeout = errors.New("inference timed out")
+
+// inferenceWatchdog aborts inference that runs far longer than its audio,
+// such as whisper stuck in a repetition loop on a noisy chunk, so that one
+// chunk cannot hold up a session's worker. The audio of every aborted chunk is
+// saved so that it can be reproduced. A nil inferenceWatchdog never aborts.
+type inferenceWatchdog struct {
+	// factor and grace set the timeout of a chunk: factor times its duration,
+	// plus grace.
+	factor float64
+	grace  time.Duration
+	// dir receives the audio of aborted chunks.
+	dir string
+}
+
+func newInferenceWatchdog(dir string) *inferenceWatchdog {
+	return &inferenceWatchdog{
+		factor: float64(thresholdFromEnv("EKKO_INFERENCE_TIMEOUT_FACTOR", DefaultInferenceTimeoutFactor)),
+		grace:  inferenceGrace,
+		dir:    dir,
+	}
+}
+
+// debugDir returns where debugging artifacts are saved: EKKO_DEBUG_DIR, or
+// an ekko/debug directory in the user's cache directory.
+func debugDir() (string, error) {
+	if dir := os.Getenv("EKKO_DEBUG_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ekko", "debug"), nil
+}
+
+// timeout returns how long the inference of audio may take.
+func (w *inferenceWatchdog) timeout(audio time.Duration) time.Duration {
+	return w.grace + time.Duration(w.factor*float64(audio))
+}
+
+// watch returns a context for the inference of samples that is done once it
+// runs past its timeout, as well as when ctx is. The clock starts when the
+// transcriber reports that inference begins, so that waiting for a free
+// whisper context does not count.
+func (w *inferenceWatchdog) watch(ctx context.Context, samples []float32) (context.Context, context.CancelFunc) {
+	if w == nil {
+		return context.WithCancel(ctx)
+	}
+
+	watched, cancel := context.WithCancelCause(ctx)
+	timeout := w.timeout(samplesDuration(samples))
+	var once sync.Once
+	start := func() {
+		once.Do(func() {
+			timer := time.AfterFunc(timeout, func() { cancel(ErrInferenceTimeout) })
+			context.AfterFunc(watched, func() { timer.Stop() })
+		})
+	}
+	return speech.WithStart(watched, start), func() { cancel(context.Canceled) }
+}
+
+// check returns err, the result of inferring job's chunk under watched. When
+// the watchdog aborted it, the error names the chunk instead, and where its
+// audio was saved. The audio of sessions that redact is not saved, since it
+// holds what redaction removes from their text. It must be called before the
+// chunk's samples are released.
+func (w *inferenceWatchdog) check(watched context.Context, session *TranscribeSession, job Job, err error) error {
+	if w == nil || err == nil || !errors.Is(context.Cause(watched), ErrInferenceTimeout) {
+		return err
+	}
+
+	chunk := fmt.Sprintf("chunk %d at %s-%s", job.ID, formatOffset(job.Chunk.Start), formatOffset(job.Chunk.End))
+	timeout := w.timeout(samplesDuration(job.Chunk.Samples)).Round(time.Second)
+	if session.redactor != nil {
+		return fmt.Errorf("%s: %w after %s; its audio is not saved while redaction is on", chunk, ErrInferenceTimeout, timeout)
+	}
+	path := filepath.Join(w.dir, fmt.Sprintf("%s-chunk-%d.wav", session.ID, job.ID))
+	if err := writeWAV(path, job.Chunk.Samples); err != nil {
+		return fmt.Errorf("%s: %w after %s; saving its audio: %w", chunk, ErrInferenceTimeout, timeout, err)
+	}
+	return fmt.Errorf("%s: %w after %s; its audio is saved to %s", chunk, ErrInferenceTimeout, timeout, path)
+}
+
+// writeWAV saves 16 kHz mono samples as a 32-bit float WAV file, which keeps
+// them exactly as transcribed.
+func writeWAV(path string, samples []float32) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, ffmpeg.EncodeWAV(samples, ffmpeg.DefaultSampleRate, ffmpeg.WAVFloat32), 0o644)
+}
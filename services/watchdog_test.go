@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuanta7/ekko/services/chunker"
+)
+
+func TestWatchdogAbortsStuckInferenceAndSavesChunk(t *testing.T) {
+	transcriber := newFakeTranscriber()
+	transcriber.hang = true
+	session := NewSession(func() {}, transcriber, transcriber, SessionOptions{}, nil)
+	dir := t.TempDir()
+	service := &TranscribeService{watchdog: &inferenceWatchdog{grace: 20 * time.Millisecond, dir: dir}}
+
+	samples := make([]float32, 1600)
+	samples[1] = 0.5
+	_, _, err := service.transcribe(context.Background(), session, Job{ID: 7, Sequence: 3, Chunk: chunker.AudioChunk{
+		Samples: samples,
+		Final:   true,
+		Start:   42 * time.Second,
+		End:     50 * time.Second,
+	}})
+	if !errors.Is(err, ErrInferenceTimeout) {
+		t.Fatalf("expected an inference timeout, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "chunk 7 at 0:42-0:50") {
+		t.Fatalf("expected the error to name the chunk, got %q", err)
+	}
+
+	path := filepath.Join(dir, session.ID+"-chunk-7.wav")
+	if !strings.HasSuffix(err.Error(), path) {
+		t.Fatalf("expected the error to point at %s, got %q", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 44+4*len(samples) || string(data[:4]) != "RIFF" {
+		t.Fatalf("expected a float WAV of %d samples, got %d bytes", len(samples), len(data))
+	}
+}
+
+func TestWatchdogLeavesCancellationAlone(t *testing.T) {
+	transcriber := newFakeTranscriber()
+	transcriber.hang = true
+	session := NewSession(func() {}, transcriber, transcriber, SessionOptions{}, nil)
+	dir := t.TempDir()
+	service := &TranscribeService{watchdog: &inferenceWatchdog{factor: 10, grace: time.Minute, dir: dir}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, _, err := service.transcribe(ctx, session, Job{ID: 1, Chunk: chunker.AudioChunk{Samples: make([]float32, 160)}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancellation, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected no audio to be saved, got %d files", len(entries))
+	}
+}
+
+func TestWatchdogTimesInferenceOnly(t *testing.T) {
+	transcriber := newFakeTranscriber()
+	transcriber.queue = 100 * time.Millisecond
+	session := NewSession(func() {}, transcriber, transcriber, SessionOptions{}, nil)
+	service := &TranscribeService{watchdog: &inferenceWatchdog{grace: 50 * time.Millisecond, dir: t.TempDir()}}
+
+	// Waiting for a context takes longer than the timeout, inference does not.
+	_, _, err := service.transcribe(context.Background(), session, Job{ID: 1, Chunk: chunker.AudioChunk{Samples: make([]float32, 160)}})
+	if err != nil {
+		t.Fatalf("expected the wait not to count, got %v", err)
+	}
+}
+
+func TestWatchdogSavesNoAudioOfRedactedSessions(t *testing.T) {
+	transcriber := newFakeTranscriber()
+	transcriber.hang = true
+	redactor, err := Redaction{Enabled: true}.redactor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := NewSession(func() {}, transcriber, transcriber, SessionOptions{}, nil)
+	session.redactor = redactor
+	dir := t.TempDir()
+	service := &TranscribeService{watchdog: &inferenceWatchdog{grace: 20 * time.Millisecond, dir: dir}}
+
+	_, _, err = service.transcribe(context.Background(), session, Job{ID: 1, Chunk: chunker.AudioChunk{Samples: make([]float32, 160)}})
+	if !errors.Is(err, ErrInferenceTimeout) || !strings.Contains(err.Error(), "not saved") {
+		t.Fatalf("expected an inference timeout without saved audio, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected no audio to be saved, got %d files", len(entries))
+	}
+}
@@ -1,44 +1,224 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
 	"github.com/tuanta7/ekko/services/adapter/whisper"
 	"github.com/tuanta7/ekko/services/chunker"
 )
 
 type Job struct {
-	ID    int64
-	Chunk chunker.AudioChunk
+	ID int64
+	// Sequence numbers finals and annotations from 1 in queue order, so that
+	// their results are delivered in that order. It is zero for partials.
+	Sequence int64
+	Chunk    chunker.AudioChunk
 }
 
-// process transcribes one queued audio chunk and emits its transcript and session state events.
-func (t *TranscribeService) process(sessionID string, job Job) {
+// process transcribes one queued audio chunk and emits its transcript and
+// session state events. Several workers may process a session's jobs at once;
+// session.order serializes what happens after inference. Inference is aborted
+// once ctx is done.
+func (t *TranscribeService) process(ctx context.Context, session *TranscribeSession, job Job) {
+	if job.Chunk.Annotation != "" {
+		session.order.final(job.Sequence, job.Chunk.UtteranceID, func() { t.annotate(session, job) })
+		return
+	}
+	if !job.Chunk.Final && ctx.Err() != nil {
+		// The session stopped while the partial was queued.
+		job.Chunk.Release()
+		return
+	}
+
 	// Notify listeners that transcription is in progress for this session.
-	t.emitState(sessionID, EventTranscribing, "")
+	t.emitState(session.ID, EventTranscribing, "")
 
-	segments, err := t.scriber.Transcribe(job.Chunk.Samples, whisper.TranscribeOptions{
+	// Diarization listens to the final's audio after inference, by which time
+	// the chunk's buffer is back with the chunker.
+	var audio []float32
+	if job.Chunk.Final && session.diarizer != nil {
+		audio = slices.Clone(job.Chunk.Samples)
+	}
+
+	result, prompted, err := t.transcribe(ctx, session, job)
+	deliver := func() { t.deliver(session, job, audio, result, prompted, err) }
+	if job.Chunk.Final {
+		session.order.final(job.Sequence, job.Chunk.UtteranceID, deliver)
+		return
+	}
+	session.order.partial(job.Chunk.UtteranceID, deliver)
+}
+
+// transcribe runs inference on a job's chunk and releases its samples. It
+// measures how fast the session keeps up and reports when it adapts to that,
+// and aborts inference that the watchdog finds stuck. It also reports whether
+// the chunk was decoded with a prompt.
+func (t *TranscribeService) transcribe(
+	ctx context.Context,
+	session *TranscribeSession,
+	job Job,
+) (whisper.Result, bool, error) {
+	// The samples are not needed past inference; hand the buffer back to the chunker.
+	defer job.Chunk.Release()
+
+	options := whisper.TranscribeOptions{
 		TokenTimestamps: job.Chunk.Final,
-	})
+		Language:        session.language.current(),
+		Translate:       session.Options.Translate,
+		InitialPrompt:   session.prompt.prompt(),
+		Decode:          session.load.decoding(session.Options.decoding(job.Chunk.Final), job.Chunk.Final),
+	}
+	// Keep the final's audio for refinement before it goes back to the pool.
+	if err := session.archive.keep(job); err != nil {
+		t.emitError(session.ID, fmt.Errorf("keeping audio for refinement: %w", err))
+	}
+
+	transcriber := session.transcriberFor(job)
+	watched, cancel := t.watchdog.watch(ctx, job.Chunk.Samples)
+	defer cancel()
+	started := time.Now()
+	result, err := transcribeSamples(watched, transcriber, job.Chunk.Samples, options, job.Chunk.Final)
+	err = t.watchdog.check(watched, session, job, err)
+	if err == nil {
+		elapsed, audio := time.Since(started), samplesDuration(job.Chunk.Samples)
+		if message := session.load.observe(elapsed, audio, transcriber.Capabilities().Concurrency); message != "" {
+			t.emitAdaptation(session.ID, message)
+		}
+	}
+	return result, options.InitialPrompt != "", err
+}
+
+// transcribeSamples runs inference with transcriber. A prompt can pull whisper
+// into repeating it or itself, so a final's looping output is retried once
+// without one. Partials are replaced soon enough to be left as they are.
+func transcribeSamples(
+	ctx context.Context,
+	transcriber Transcriber,
+	samples []float32,
+	options whisper.TranscribeOptions,
+	final bool,
+) (whisper.Result, error) {
+	result, err := transcriber.Transcribe(ctx, samples, options)
+	if err == nil && final && options.InitialPrompt != "" {
+		if _, looped := collapseRepetitions(whisper.CombineSegments(result.Segments)); looped {
+			options.InitialPrompt = ""
+			result, err = transcriber.Transcribe(ctx, samples, options)
+		}
+	}
+	return result, err
+}
+
+// deliver turns a job's transcription into events. Finals are delivered one
+// at a time in queue order; audio is a copy of a final's samples when the
+// session diarizes. Loops are only looked for in finals decoded with a
+// prompt, which is what pulls whisper into them.
+func (t *TranscribeService) deliver(
+	session *TranscribeSession,
+	job Job,
+	audio []float32,
+	result whisper.Result,
+	prompted bool,
+	err error,
+) {
+	sessionID := session.ID
+	if errors.Is(err, context.Canceled) {
+		// Aborted by stopping the session or the app; nothing went wrong.
+		return
+	}
 	if err != nil {
 		t.emitError(sessionID, err)
 		return
 	}
 
-	text := whisper.CombineSegments(segments)
+	if session.language.observe(result, job.Chunk.Final) {
+		t.emitState(sessionID, EventTranscribing, fmt.Sprintf("Language locked to %s", result.Language))
+	}
+
+	segments := t.filter.apply(sessionID, session.redactor, result.Segments)
+	if job.Chunk.Final {
+		segments = trimOverlap(session.lastFinalText, job.Chunk.Overlap, segments)
+	}
+
+	text, looped := whisper.CombineSegments(segments), false
+	if job.Chunk.Final && prompted {
+		text, looped = collapseRepetitions(text)
+	}
+	if job.Chunk.Final {
+		session.lastFinalText = text
+		session.prompt.observeFinal(session.promptText(text), looped)
+	}
 	if text == "" {
 		return
 	}
 
-	t.emitTranscript(TranscriptEvent{
-		SessionID: sessionID,
-		ChunkID:   job.ID,
-		Text:      text,
-		Final:     job.Chunk.Final,
-		StartMs:   job.Chunk.Start.Milliseconds(),
-		EndMs:     job.Chunk.End.Milliseconds(),
-	})
+	event := transcriptEvent(sessionID, job, text)
+	event.Language = result.Language
+	event.LanguageProbability = result.LanguageProbability
+	if !job.Chunk.Final {
+		event.Speaker = session.speaker()
+		if event = session.redact(session.rewrite(event)); event.Text != "" {
+			t.emitTranscript(event)
+		}
+		return
+	}
 
-	if job.Chunk.Final {
-		// Notify listeners that the session has returned to recording after the final chunk.
-		t.emitState(sessionID, EventRecording, "")
+	// A collapsed loop no longer matches its segments, so it is not split.
+	parts := []speakerPart{{speaker: session.turns.current(), segments: segments}}
+	if !looped {
+		parts = session.turns.split(segments)
+	}
+	session.diarizer.label(parts, audio)
+	for _, event := range speakerEvents(event, job.Chunk.Start, parts, looped) {
+		if event = session.redact(session.rewrite(event)); event.Text == "" {
+			continue
+		}
+		t.emitTranscript(event)
+		session.Transcript.add(event)
+	}
+	// Notify listeners that the session has returned to recording after the final chunk.
+	t.emitState(sessionID, EventRecording, "")
+}
+
+// annotate emits a music, noise or silence marker in place of a transcript.
+// Annotation chunks carry no audio, so the transcriber is not involved.
+func (t *TranscribeService) annotate(session *TranscribeSession, job Job) {
+	event := transcriptEvent(session.ID, job, annotationText(job.Chunk.Annotation, job.Chunk.End-job.Chunk.Start))
+	event.Annotation = string(job.Chunk.Annotation)
+
+	t.emitTranscript(event)
+	session.Transcript.add(event)
+}
+
+// transcriptWords converts the words of chunk-relative segments to session time.
+func transcriptWords(chunkStart time.Duration, segments []whisper.Segment) []TranscriptWord {
+	var words []TranscriptWord
+	for _, segment := range segments {
+		for _, word := range segment.Words() {
+			words = append(words, TranscriptWord{
+				Text:        word.Text,
+				StartMs:     (chunkStart + word.Start).Milliseconds(),
+				EndMs:       (chunkStart + word.End).Milliseconds(),
+				Probability: word.Probability,
+			})
+		}
+	}
+	return words
+}
+
+// transcriptEvent builds the event describing a job's chunk with the given text.
+func transcriptEvent(sessionID string, job Job, text string) TranscriptEvent {
+	return TranscriptEvent{
+		SessionID:   sessionID,
+		ChunkID:     job.ID,
+		UtteranceID: job.Chunk.UtteranceID,
+		Revision:    job.Chunk.Revision,
+		Text:        text,
+		Final:       job.Chunk.Final,
+		StartMs:     job.Chunk.Start.Milliseconds(),
+		EndMs:       job.Chunk.End.Milliseconds(),
 	}
 }
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tuanta7/ekko/services/adapter/whisper"
+)
+
+func TestTranscriptWordsUseSessionTime(t *testing.T) {
+	segments := []whisper.Segment{
+		{Tokens: []whisper.Token{{Text: " good", Start: 100 * time.Millisecond, End: 300 * time.Millisecond, Probability: 0.9}}},
+		{Tokens: []whisper.Token{{Text: " morning", Start: 400 * time.Millisecond, End: 800 * time.Millisecond, Probability: 0.4}}},
+	}
+
+	words := transcriptWords(10*time.Second, segments)
+	if len(words) != 2 {
+		t.Fatalf("expected 2 words, got %d", len(words))
+	}
+	if words[0].Text != "good" || words[0].StartMs != 10_100 || words[0].EndMs != 10_300 {
+		t.Fatalf("expected good at 10100-10300ms, got %+v", words[0])
+	}
+	if words[1].Text != "morning" || words[1].StartMs != 10_400 || words[1].Probability != 0.4 {
+		t.Fatalf("expected morning at 10400ms with probability 0.4, got %+v", words[1])
+	}
+}